@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/apex/log"
+	"github.com/jckuester/terradozer/internal"
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/state"
+)
+
+// destroy asks the user to confirm deleting the given resources (unless in force mode) and destroys them.
+// The results are added to the given report. Afterwards, destroyed resources are removed from their states
+// if requested (see writeStates). Returns the exit code.
+func destroy(ctx context.Context, resources []resource.DestroyableResource, confirmation internal.Confirmation,
+	found *stateResources, result *report, o options) int {
+	internal.SetPhase("confirm")
+
+	confirmed, err := internal.UserConfirmedDeletion(ctx, os.Stdin, o.force, confirmation)
+	if err != nil {
+		printError("Error:️ %s\n", err)
+
+		return 1
+	}
+
+	if !confirmed {
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr)
+			internal.LogTitle("interrupted: no resources have been deleted")
+
+			return 1
+		}
+
+		return 0
+	}
+
+	internal.SetPhase("destroy")
+
+	internal.LogTitle("Starting to delete resources")
+
+	destroyReport := resource.DestroyResources(ctx, resources, o.parallel,
+		resource.WithProtection(o.protection), resource.WithRetryPolicy(o.retryPolicy))
+
+	result.addDestroyResults(destroyReport)
+
+	if destroyReport.Cancelled {
+		internal.LogTitle(fmt.Sprintf("interrupted: resources that have not been deleted: %d",
+			len(destroyReport.Failed())))
+
+		for _, r := range destroyReport.Failed() {
+			internal.LogResource(r.Resource).Warn(internal.Pad(r.Resource.Type()))
+		}
+	}
+
+	internal.LogTitle(fmt.Sprintf("total number of deleted resources: %d", len(destroyReport.Destroyed())))
+
+	if len(destroyReport.Protected()) > 0 {
+		internal.LogTitle(fmt.Sprintf("total number of protected resources (not deleted): %d",
+			len(destroyReport.Protected())))
+	}
+
+	if len(found.ignored) > 0 {
+		internal.LogTitle(fmt.Sprintf("total number of ignored resources of providers without version "+
+			"(not deleted): %d", len(found.ignored)))
+	}
+
+	if o.updateState {
+		err := writeStates(found.states, destroyReport)
+		if err != nil {
+			printError("\nError:️ failed to update Terraform state: %s\n", err)
+
+			return 1
+		}
+	}
+
+	if destroyReport.Cancelled {
+		return 1
+	}
+
+	return 0
+}
+
+// writeStates removes all destroyed resources from the given states and writes the states
+// with removed resources back to where they have been read from.
+func writeStates(tfstates []*state.State, destroyReport *resource.DestroyReport) error {
+	var destroyedResources []resource.DestroyableResource
+
+	for _, r := range destroyReport.Destroyed() {
+		destroyedResources = append(destroyedResources, r.Resource)
+	}
+
+	internal.SetPhase("update-state")
+
+	internal.LogTitle("updating state")
+
+	for _, tfstate := range tfstates {
+		numOfRemovedResources := tfstate.RemoveResources(destroyedResources)
+		if numOfRemovedResources == 0 {
+			log.WithField("file", tfstate.Location()).Info(internal.Pad("state unchanged"))
+
+			continue
+		}
+
+		backup, err := tfstate.Write()
+		if err != nil {
+			return err
+		}
+
+		log.WithFields(log.Fields{
+			"file":    tfstate.Location(),
+			"backup":  backup,
+			"removed": numOfRemovedResources,
+		}).Info(internal.Pad("updated state"))
+	}
+
+	return nil
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jckuester/terradozer/internal"
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/state"
+)
+
+// options are the settings of a run given via flags or the config file.
+type options struct {
+	accountGuard         internal.AccountGuard
+	configFile           string
+	confirm              string
+	dependencyLockFile   string
+	dryRun               bool
+	filter               state.Filter
+	force                bool
+	interactive          bool
+	lock                 bool
+	lockTable            string
+	lockTimeout          time.Duration
+	logDebug             bool
+	logFormat            string
+	output               string
+	out                  string
+	parallel             int
+	pluginDirs           []string
+	pluginMirror         string
+	protection           resource.Protection
+	providerProfiles     internal.KeyValueFlag
+	providerRegions      internal.KeyValueFlag
+	providerSettingsFile string
+	providerVersions     internal.KeyValueFlag
+	recursive            bool
+	retryPolicy          resource.RetryPolicy
+	timeout              string
+	typeTimeouts         internal.DurationMapFlag
+	updateState          bool
+	version              bool
+
+	// confirmMode and timeoutDuration are parsed from confirm and timeout (see validate).
+	confirmMode     internal.ConfirmMode
+	timeoutDuration time.Duration
+}
+
+// newFlagSet returns the flags of all options, which are parsed into the given options.
+func newFlagSet(o *options) *flag.FlagSet {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+
+	flags.Usage = func() {
+		printHelp(flags)
+	}
+
+	flags.StringVar(&o.timeout, "timeout", "30s", "Amount of time to wait for a destroy of a resource to finish")
+	flags.Var(&o.typeTimeouts, "type-timeout",
+		"Amount of time to wait for a destroy of resources of a type to finish, given as `type=duration` "+
+			"(e.g., 'aws_db_instance=40m'); overrides -timeout and the timeouts block of a resource in the state")
+	flags.Var((*internal.StringSliceFlag)(&o.accountGuard.AllowedAccountIDs), "allowed-account-ids",
+		"Only destroy resources if all AWS providers are configured for one of the given account `IDs`")
+	flags.Var((*internal.StringSliceFlag)(&o.accountGuard.ForbiddenAccountIDs), "forbidden-account-ids",
+		"Never destroy resources if any AWS provider is configured for one of the given account `IDs`")
+	flags.StringVar(&o.configFile, "config", "",
+		"Read settings from a config `file` (e.g., terradozer.hcl); flags given on the command line take precedence")
+	flags.StringVar(&o.confirm, "confirm", string(internal.ConfirmYes),
+		"What to type to confirm deletion: yes, account-id (of the AWS account), state (location of the state file), "+
+			"or count (number of resources to be deleted)")
+	flags.StringVar(&o.dependencyLockFile, "dependency-lock-file", "",
+		"Launch providers in the versions selected in a Terraform dependency lock `file` (.terraform.lock.hcl)")
+	flags.BoolVar(&o.dryRun, "dry-run", false, "Show what would be destroyed")
+	flags.BoolVar(&o.force, "force", false, "Destroy without asking for confirmation")
+	flags.BoolVar(&o.interactive, "interactive", false,
+		"Select the resources to delete from the list of resources found (requires a terminal)")
+	flags.BoolVar(&o.logDebug, "debug", false, "Enable debug logging")
+	flags.StringVar(&o.logFormat, "log-format", internal.LogFormatText,
+		"Log `format` (text or json); json writes one JSON object per event to stderr")
+	flags.BoolVar(&o.lock, "lock", true, "Lock the state file (like Terraform does) while destroying its resources")
+	flags.StringVar(&o.lockTable, "lock-table", "",
+		"Name of the DynamoDB `table` to lock state files in S3 with (i.e., dynamodb_table of the S3 backend)")
+	flags.DurationVar(&o.lockTimeout, "lock-timeout", 0, "Amount of time to retry acquiring the lock of a state file")
+	flags.StringVar(&o.output, "output", outputText,
+		"Output `format` of the results (text or json); json writes a report of all resources to stdout")
+	flags.StringVar(&o.out, "out", "",
+		"Write a plan of the resources that would be deleted to a `file` (plan command only)")
+	flags.IntVar(&o.parallel, "parallel", 10, "Limit the number of concurrent destroy operations")
+	flags.Var((*internal.StringSliceFlag)(&o.pluginDirs), "plugin-dir",
+		"Look up provider plugins in a `dir` (with plugin binaries or a Terraform filesystem mirror) "+
+			"instead of downloading them from the registry")
+	flags.StringVar(&o.pluginMirror, "plugin-mirror", "",
+		"Download provider plugins from a Terraform network mirror at `url` instead of the registry")
+	flags.StringVar(&o.providerSettingsFile, "providers", "",
+		"JSON `file` mapping provider configurations (e.g., 'aws.us_east_1') to their region and profile")
+	flags.Var(&o.providerRegions, "provider-region",
+		"Region of a provider configuration as `provider=region` (e.g., 'aws.us_east_1=us-east-1')")
+	flags.Var(&o.providerProfiles, "provider-profile",
+		"AWS profile of a provider configuration as `provider=profile` (e.g., 'aws.prod=prod')")
+	flags.Var(&o.providerVersions, "provider-version",
+		"Exact version of a provider by name or source address as `provider=version` (e.g., 'aws=3.74.0' or "+
+			"'registry.example.com/acme/widgets=0.1.0'); overrides the dependency lock file")
+	flags.IntVar(&o.retryPolicy.MaxAttempts, "max-attempts", 0,
+		"Maximum number of attempts to destroy a resource that failed with a retryable error (0 means no limit); "+
+			"enables retries with backoff (default then: 5)")
+	flags.DurationVar(&o.retryPolicy.BackoffBase, "retry-backoff", 0,
+		"Delay before retrying to destroy a resource the first time, which is doubled with each further retry; "+
+			"enables retries with backoff (default then: 2s)")
+	flags.DurationVar(&o.retryPolicy.BackoffCap, "retry-backoff-cap", 0,
+		"Maximum delay between two attempts to destroy a resource (0 means no limit); "+
+			"enables retries with backoff (default then: 30s)")
+	flags.Float64Var(&o.retryPolicy.Jitter, "retry-jitter", 0,
+		"Fraction (between 0 and 1) by which each retry delay is randomly reduced; "+
+			"enables retries with backoff (default then: 0.2)")
+	flags.DurationVar(&o.retryPolicy.MaxDuration, "max-retry-duration", 0,
+		"Maximum amount of time to retry destroying a resource (0 means no limit); "+
+			"enables retries with backoff (default then: 5m)")
+	flags.BoolVar(&o.recursive, "recursive", false,
+		"Destroy resources of all state files (*.tfstate) found under a given directory or S3 prefix")
+	flags.BoolVar(&o.updateState, "update-state", false,
+		"Remove destroyed resources from the state file and write it back (keeps a timestamped backup of the original)")
+	flags.BoolVar(&o.version, "version", false, "Show application version")
+	flags.Var((*internal.StringSliceFlag)(&o.filter.IncludeAddresses), "include",
+		"Only destroy resources whose address matches a glob `pattern` (e.g., 'module.app.aws_instance.*')")
+	flags.Var((*internal.StringSliceFlag)(&o.filter.ExcludeAddresses), "exclude",
+		"Do not destroy resources whose address matches a glob `pattern`")
+	flags.Var((*internal.StringSliceFlag)(&o.filter.IncludeTypes), "include-type",
+		"Only destroy resources whose type matches a glob `pattern` (e.g., 'aws_instance')")
+	flags.Var((*internal.StringSliceFlag)(&o.filter.ExcludeTypes), "exclude-type",
+		"Do not destroy resources whose type matches a glob `pattern`")
+	flags.Var((*internal.StringSliceFlag)(&o.filter.IncludeModules), "include-module",
+		"Only destroy resources of modules (incl. nested ones) whose path matches a glob `pattern` (e.g., 'module.app')")
+	flags.Var((*internal.StringSliceFlag)(&o.filter.ExcludeModules), "exclude-module",
+		"Do not destroy resources of modules (incl. nested ones) whose path matches a glob `pattern`")
+
+	flags.Var((*internal.StringSliceFlag)(&o.protection.Types), "protect-type",
+		"Never destroy resources whose type matches a glob `pattern` (e.g., 'aws_kms_key')")
+	flags.Var((*internal.StringSliceFlag)(&o.protection.Addresses), "protect",
+		"Never destroy resources whose address matches a glob `pattern`")
+	flags.Var((*internal.StringSliceFlag)(&o.protection.IDs), "protect-id",
+		"Never destroy resources whose ID matches a glob `pattern`")
+	flags.Var((*internal.KeyValueFlag)(&o.protection.Tags), "protect-tag",
+		"Never destroy resources with a tag whose value matches a glob pattern, given as `key=pattern`")
+	return flags
+}
+
+// validate checks that the given options can be used together with the given command (see parseCommand)
+// and parses the options derived from others. In plan mode, dry run is enabled.
+//
+// Errors are usage errors, i.e., to be shown together with the help.
+func (o *options) validate(command string, flags *flag.FlagSet) error {
+	if command == commandPlan {
+		if o.out == "" {
+			return fmt.Errorf("plan command requires the -out flag")
+		}
+
+		if o.force || o.interactive || o.updateState {
+			return fmt.Errorf("plan command cannot be used together with -force, -interactive, or -update-state")
+		}
+
+		o.dryRun = true
+	} else if o.out != "" {
+		return fmt.Errorf("-out flag can only be used with the plan command")
+	}
+
+	if command == commandApply && o.recursive {
+		return fmt.Errorf("-recursive flag cannot be used with the apply command")
+	}
+
+	if o.force && o.dryRun {
+		return fmt.Errorf("-force and -dry-run flag cannot be used together")
+	}
+
+	if o.interactive && (o.force || o.dryRun) {
+		return fmt.Errorf("-interactive flag cannot be used together with -force or -dry-run")
+	}
+
+	if o.updateState && o.dryRun {
+		return fmt.Errorf("-update-state and -dry-run flag cannot be used together")
+	}
+
+	if o.output != outputText && o.output != outputJSON {
+		return fmt.Errorf("unknown output format: %s (expected text or json)", o.output)
+	}
+
+	var err error
+
+	o.confirmMode, err = internal.ParseConfirmMode(o.confirm)
+	if err != nil {
+		return err
+	}
+
+	o.retryPolicy = retryPolicyOf(flags, o.retryPolicy)
+
+	if !o.retryPolicy.IsEmpty() {
+		err = o.retryPolicy.Validate()
+		if err != nil {
+			return fmt.Errorf("invalid retry policy: %s", err)
+		}
+	}
+
+	o.timeoutDuration, err = time.ParseDuration(o.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to parse timeout flag: %s", err)
+	}
+
+	return nil
+}
+
+// validateLocations checks that the given locations of state files can be used with the options.
+//
+// Errors are usage errors, i.e., to be shown together with the help.
+func (o *options) validateLocations(locations []string) error {
+	if len(locations) == 0 {
+		return fmt.Errorf("path to Terraform state file expected")
+	}
+
+	if o.updateState {
+		for _, location := range locations {
+			if state.IsS3VersionLocation(location) {
+				return fmt.Errorf("-update-state flag cannot be used with a specific version of a state file "+
+					"in S3: %s", location)
+			}
+		}
+	}
+
+	return nil
+}
+
+// retryPolicyOf returns the given retry policy with the defaults (see resource.DefaultRetryPolicy) for all settings
+// whose flag hasn't been set, if any flag of the retry policy has been set (also via the config file).
+// Otherwise, the given (empty) policy is returned, i.e., failed destroys are retried by trial and error.
+func retryPolicyOf(flags *flag.FlagSet, p resource.RetryPolicy) resource.RetryPolicy {
+	isSet := map[string]bool{}
+
+	flags.Visit(func(f *flag.Flag) {
+		isSet[f.Name] = true
+	})
+
+	if !isSet["max-attempts"] && !isSet["retry-backoff"] && !isSet["retry-backoff-cap"] &&
+		!isSet["retry-jitter"] && !isSet["max-retry-duration"] {
+		return p
+	}
+
+	defaults := resource.DefaultRetryPolicy()
+
+	if !isSet["max-attempts"] {
+		p.MaxAttempts = defaults.MaxAttempts
+	}
+
+	if !isSet["retry-backoff"] {
+		p.BackoffBase = defaults.BackoffBase
+	}
+
+	if !isSet["retry-backoff-cap"] {
+		p.BackoffCap = defaults.BackoffCap
+	}
+
+	if !isSet["retry-jitter"] {
+		p.Jitter = defaults.Jitter
+	}
+
+	if !isSet["max-retry-duration"] {
+		p.MaxDuration = defaults.MaxDuration
+	}
+
+	return p
+}
@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// AWSAccount is an AWS account and region that providers are configured for.
+type AWSAccount struct {
+	ID     string
+	Region string
+	// Providers are the addresses of the provider configurations using the account and region.
+	Providers []string
+}
+
+// String returns the ID and region of the account.
+func (a AWSAccount) String() string {
+	return fmt.Sprintf("%s (region: %s)", a.ID, a.Region)
+}
+
+// AccountGuard restricts the AWS accounts that resources can be destroyed in.
+type AccountGuard struct {
+	// AllowedAccountIDs are the only accounts allowed (if any are given).
+	AllowedAccountIDs []string
+	// ForbiddenAccountIDs are accounts that are never allowed.
+	ForbiddenAccountIDs []string
+}
+
+// IsEmpty returns true if the guard allows all accounts.
+func (g AccountGuard) IsEmpty() bool {
+	return len(g.AllowedAccountIDs) == 0 && len(g.ForbiddenAccountIDs) == 0
+}
+
+// Check returns an error if any of the given accounts isn't allowed.
+func (g AccountGuard) Check(accounts []AWSAccount) error {
+	for _, a := range accounts {
+		if contains(g.ForbiddenAccountIDs, a.ID) {
+			return fmt.Errorf("AWS account %s is forbidden (providers: %s)", a.ID, strings.Join(a.Providers, ", "))
+		}
+
+		if len(g.AllowedAccountIDs) > 0 && !contains(g.AllowedAccountIDs, a.ID) {
+			return fmt.Errorf("AWS account %s is not allowed (providers: %s)", a.ID, strings.Join(a.Providers, ", "))
+		}
+	}
+
+	return nil
+}
+
+// LookupAWSAccountID returns the ID of the AWS account of the caller identity of the given STS client.
+func LookupAWSAccountID(client stsiface.STSAPI) (string, error) {
+	output, err := client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get caller identity: %s", err)
+	}
+
+	return aws.StringValue(output.Account), nil
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package internal_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/jckuester/terradozer/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountGuard_Check(t *testing.T) {
+	accounts := []internal.AWSAccount{
+		{ID: "111111111111", Region: "us-east-1", Providers: []string{"aws"}},
+		{ID: "222222222222", Region: "eu-west-1", Providers: []string{"aws.eu", "module.app.provider.aws"}},
+	}
+
+	tests := []struct {
+		name           string
+		guard          internal.AccountGuard
+		expectedErrMsg string
+	}{
+		{
+			name: "no restrictions",
+		},
+		{
+			name:  "all accounts allowed",
+			guard: internal.AccountGuard{AllowedAccountIDs: []string{"111111111111", "222222222222"}},
+		},
+		{
+			name:  "account not allowed",
+			guard: internal.AccountGuard{AllowedAccountIDs: []string{"111111111111"}},
+			expectedErrMsg: "AWS account 222222222222 is not allowed " +
+				"(providers: aws.eu, module.app.provider.aws)",
+		},
+		{
+			name:           "account forbidden",
+			guard:          internal.AccountGuard{ForbiddenAccountIDs: []string{"111111111111"}},
+			expectedErrMsg: "AWS account 111111111111 is forbidden (providers: aws)",
+		},
+		{
+			name: "forbidden takes precedence",
+			guard: internal.AccountGuard{
+				AllowedAccountIDs:   []string{"111111111111", "222222222222"},
+				ForbiddenAccountIDs: []string{"111111111111"},
+			},
+			expectedErrMsg: "AWS account 111111111111 is forbidden (providers: aws)",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.guard.Check(accounts)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+type fakeSTS struct {
+	stsiface.STSAPI
+	accountID string
+	err       error
+}
+
+func (f fakeSTS) GetCallerIdentity(*sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &sts.GetCallerIdentityOutput{Account: aws.String(f.accountID)}, nil
+}
+
+func TestLookupAWSAccountID(t *testing.T) {
+	actualAccountID, err := internal.LookupAWSAccountID(fakeSTS{accountID: "111111111111"})
+	require.NoError(t, err)
+	assert.Equal(t, "111111111111", actualAccountID)
+
+	_, err = internal.LookupAWSAccountID(fakeSTS{err: fmt.Errorf("expired token")})
+	assert.EqualError(t, err, "failed to get caller identity: expired token")
+}
@@ -0,0 +1,347 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsimple"
+)
+
+// Config is the content of a config file (e.g., terradozer.hcl) to check the configuration of terradozer runs into git.
+// Each setting corresponds to the flag of the same name (with dashes instead of underscores).
+type Config struct {
+	// States are the locations of state files (or directories and S3 prefixes in recursive mode)
+	// to destroy the resources of.
+	States      []string `hcl:"states,optional"`
+	Recursive   *bool    `hcl:"recursive,optional"`
+	Timeout     *string  `hcl:"timeout,optional"`
+	Parallel    *int     `hcl:"parallel,optional"`
+	LockTable   *string  `hcl:"lock_table,optional"`
+	LockTimeout *string  `hcl:"lock_timeout,optional"`
+	LogFormat   *string  `hcl:"log_format,optional"`
+	// TypeTimeouts are the timeouts per resource type (e.g., aws_db_instance = "40m").
+	TypeTimeouts map[string]string `hcl:"type_timeouts,optional"`
+	// MaxAttempts, RetryBackoff, RetryBackoffCap, RetryJitter, and MaxRetryDuration configure how
+	// resources are retried (see resource.RetryPolicy).
+	MaxAttempts      *int     `hcl:"max_attempts,optional"`
+	RetryBackoff     *string  `hcl:"retry_backoff,optional"`
+	RetryBackoffCap  *string  `hcl:"retry_backoff_cap,optional"`
+	RetryJitter      *float64 `hcl:"retry_jitter,optional"`
+	MaxRetryDuration *string  `hcl:"max_retry_duration,optional"`
+	// PluginDir and PluginMirror are where provider plugins are looked up instead of the registry (see plugin.Source).
+	PluginDir    []string `hcl:"plugin_dir,optional"`
+	PluginMirror *string  `hcl:"plugin_mirror,optional"`
+	// ProviderVersions are the exact versions of providers by name or source address (e.g., aws = "3.74.0"),
+	// which take precedence over the ones selected in the DependencyLockFile (e.g., .terraform.lock.hcl).
+	ProviderVersions   map[string]string `hcl:"provider_versions,optional"`
+	DependencyLockFile *string           `hcl:"dependency_lock_file,optional"`
+	// Confirm is what to type to confirm deletion (see ConfirmMode).
+	Confirm     *string `hcl:"confirm,optional"`
+	Interactive *bool   `hcl:"interactive,optional"`
+	Output      *string `hcl:"output,optional"`
+	Lock        *bool   `hcl:"lock,optional"`
+	UpdateState *bool   `hcl:"update_state,optional"`
+	// ProvidersFile is the JSON file mapping provider configurations to their region and profile
+	// (see -providers); the provider blocks below take precedence.
+	ProvidersFile *string `hcl:"providers,optional"`
+
+	AllowedAccountIDs   []string `hcl:"allowed_account_ids,optional"`
+	ForbiddenAccountIDs []string `hcl:"forbidden_account_ids,optional"`
+
+	Include       []string `hcl:"include,optional"`
+	Exclude       []string `hcl:"exclude,optional"`
+	IncludeType   []string `hcl:"include_type,optional"`
+	ExcludeType   []string `hcl:"exclude_type,optional"`
+	IncludeModule []string `hcl:"include_module,optional"`
+	ExcludeModule []string `hcl:"exclude_module,optional"`
+
+	Providers []ProviderConfig `hcl:"provider,block"`
+	Protect   *ProtectConfig   `hcl:"protect,block"`
+}
+
+// ProviderConfig is the region and profile of a provider configuration (e.g., "aws.us_east_1").
+type ProviderConfig struct {
+	Address string  `hcl:"address,label"`
+	Region  *string `hcl:"region,optional"`
+	Profile *string `hcl:"profile,optional"`
+}
+
+// ProtectConfig are the rules for resources that must never be destroyed (see resource.Protection).
+type ProtectConfig struct {
+	Types     []string          `hcl:"types,optional"`
+	Addresses []string          `hcl:"addresses,optional"`
+	IDs       []string          `hcl:"ids,optional"`
+	Tags      map[string]string `hcl:"tags,optional"`
+}
+
+// ReadConfig reads a config file in HCL (or JSON, if the file name ends with .json).
+//
+// Relative paths in the config file (of states, plugin dirs, the dependency lock file, and the providers file)
+// are relative to the directory of the config file, not to the current working directory.
+func ReadConfig(path string) (*Config, error) {
+	var config Config
+
+	err := hclsimple.DecodeFile(path, nil, &config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %s", err)
+	}
+
+	config.resolvePaths(filepath.Dir(path))
+
+	return &config, nil
+}
+
+// resolvePaths makes all relative paths in the config relative to the given directory.
+func (c *Config) resolvePaths(dir string) {
+	for i := range c.States {
+		c.States[i] = resolvePath(dir, c.States[i])
+	}
+
+	for i := range c.PluginDir {
+		c.PluginDir[i] = resolvePath(dir, c.PluginDir[i])
+	}
+
+	for _, path := range []*string{c.DependencyLockFile, c.ProvidersFile} {
+		if path != nil {
+			*path = resolvePath(dir, *path)
+		}
+	}
+}
+
+// resolvePath returns the given path relative to the given directory, unless the path is absolute,
+// relative to the home directory (e.g., ~/.terradozer), or a URL (e.g., s3://bucket/key).
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) || strings.HasPrefix(path, "~") || strings.Contains(path, "://") {
+		return path
+	}
+
+	result := filepath.Join(dir, path)
+
+	// keep marking a directory (e.g., in recursive mode)
+	if strings.HasSuffix(path, "/") {
+		result += "/"
+	}
+
+	return result
+}
+
+// Apply sets all flags that haven't been set on the command line to the values of the config,
+// i.e., flags given on the command line override the values of the config. Exceptions are the
+// flags mapping provider configurations to their region or profile and providers to their version,
+// which are merged per provider (configuration),
+// and the rules of protected resources and forbidden accounts, which are added to the ones given on the command line
+// (i.e., protection can't be weakened on the command line).
+func (c *Config) Apply(flags *flag.FlagSet) error {
+	setOnCommandLine := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		setOnCommandLine[f.Name] = true
+	})
+
+	for _, s := range c.flagValues() {
+		if setOnCommandLine[s.name] && !s.additive {
+			continue
+		}
+
+		for _, v := range s.values {
+			err := flags.Set(s.name, v)
+			if err != nil {
+				return fmt.Errorf("invalid value for %s in config file (%s): %s",
+					strings.ReplaceAll(s.name, "-", "_"), v, err)
+			}
+		}
+	}
+
+	return c.applyProviders(flags)
+}
+
+type flagValues struct {
+	name   string
+	values []string
+	// additive is true if the values are added to the ones given on the command line.
+	additive bool
+}
+
+// flagValues returns the values of all settings in the config by flag name.
+func (c *Config) flagValues() []flagValues {
+	result := []flagValues{
+		{name: "include", values: c.Include},
+		{name: "exclude", values: c.Exclude},
+		{name: "include-type", values: c.IncludeType},
+		{name: "exclude-type", values: c.ExcludeType},
+		{name: "include-module", values: c.IncludeModule},
+		{name: "exclude-module", values: c.ExcludeModule},
+		{name: "allowed-account-ids", values: c.AllowedAccountIDs},
+		{name: "forbidden-account-ids", values: c.ForbiddenAccountIDs, additive: true},
+		{name: "plugin-dir", values: c.PluginDir},
+	}
+
+	if len(c.TypeTimeouts) > 0 {
+		var timeouts []string
+		for k, v := range c.TypeTimeouts {
+			timeouts = append(timeouts, k+"="+v)
+		}
+
+		sort.Strings(timeouts)
+
+		result = append(result, flagValues{name: "type-timeout", values: timeouts})
+	}
+
+	if c.Protect != nil {
+		var tags []string
+		for k, v := range c.Protect.Tags {
+			tags = append(tags, k+"="+v)
+		}
+
+		sort.Strings(tags)
+
+		result = append(result,
+			flagValues{name: "protect-type", values: c.Protect.Types, additive: true},
+			flagValues{name: "protect", values: c.Protect.Addresses, additive: true},
+			flagValues{name: "protect-id", values: c.Protect.IDs, additive: true},
+			flagValues{name: "protect-tag", values: tags, additive: true})
+	}
+
+	if c.Recursive != nil {
+		result = append(result, flagValues{name: "recursive", values: []string{strconv.FormatBool(*c.Recursive)}})
+	}
+
+	if c.Timeout != nil {
+		result = append(result, flagValues{name: "timeout", values: []string{*c.Timeout}})
+	}
+
+	if c.Parallel != nil {
+		result = append(result, flagValues{name: "parallel", values: []string{strconv.Itoa(*c.Parallel)}})
+	}
+
+	if c.LockTable != nil {
+		result = append(result, flagValues{name: "lock-table", values: []string{*c.LockTable}})
+	}
+
+	if c.MaxAttempts != nil {
+		result = append(result, flagValues{name: "max-attempts", values: []string{strconv.Itoa(*c.MaxAttempts)}})
+	}
+
+	if c.RetryBackoff != nil {
+		result = append(result, flagValues{name: "retry-backoff", values: []string{*c.RetryBackoff}})
+	}
+
+	if c.RetryBackoffCap != nil {
+		result = append(result, flagValues{name: "retry-backoff-cap", values: []string{*c.RetryBackoffCap}})
+	}
+
+	if c.RetryJitter != nil {
+		result = append(result, flagValues{name: "retry-jitter",
+			values: []string{strconv.FormatFloat(*c.RetryJitter, 'g', -1, 64)}})
+	}
+
+	if c.MaxRetryDuration != nil {
+		result = append(result, flagValues{name: "max-retry-duration", values: []string{*c.MaxRetryDuration}})
+	}
+
+	if c.PluginMirror != nil {
+		result = append(result, flagValues{name: "plugin-mirror", values: []string{*c.PluginMirror}})
+	}
+
+	if c.DependencyLockFile != nil {
+		result = append(result, flagValues{name: "dependency-lock-file", values: []string{*c.DependencyLockFile}})
+	}
+
+	if c.LogFormat != nil {
+		result = append(result, flagValues{name: "log-format", values: []string{*c.LogFormat}})
+	}
+
+	if c.Confirm != nil {
+		result = append(result, flagValues{name: "confirm", values: []string{*c.Confirm}})
+	}
+
+	if c.LockTimeout != nil {
+		result = append(result, flagValues{name: "lock-timeout", values: []string{*c.LockTimeout}})
+	}
+
+	if c.Interactive != nil {
+		result = append(result, flagValues{name: "interactive", values: []string{strconv.FormatBool(*c.Interactive)}})
+	}
+
+	if c.Output != nil {
+		result = append(result, flagValues{name: "output", values: []string{*c.Output}})
+	}
+
+	if c.Lock != nil {
+		result = append(result, flagValues{name: "lock", values: []string{strconv.FormatBool(*c.Lock)}})
+	}
+
+	if c.UpdateState != nil {
+		result = append(result, flagValues{name: "update-state", values: []string{strconv.FormatBool(*c.UpdateState)}})
+	}
+
+	if c.ProvidersFile != nil {
+		result = append(result, flagValues{name: "providers", values: []string{*c.ProvidersFile}})
+	}
+
+	return result
+}
+
+// applyProviders adds the region and profile of each provider configuration and the version of each provider
+// to the respective flags, unless already given for the provider (configuration) on the command line.
+func (c *Config) applyProviders(flags *flag.FlagSet) error {
+	var names []string
+	for name := range c.ProviderVersions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flags.Lookup("provider-version") == nil || isKeySet(flags, "provider-version", name) {
+			continue
+		}
+
+		err := flags.Set("provider-version", name+"="+c.ProviderVersions[name])
+		if err != nil {
+			return fmt.Errorf("invalid version of provider %q in config file: %s", name, err)
+		}
+	}
+
+	for _, p := range c.Providers {
+		for name, value := range map[string]*string{
+			"provider-region":  p.Region,
+			"provider-profile": p.Profile,
+		} {
+			if value == nil {
+				continue
+			}
+
+			if flags.Lookup(name) == nil || isKeySet(flags, name, p.Address) {
+				continue
+			}
+
+			err := flags.Set(name, p.Address+"="+*value)
+			if err != nil {
+				return fmt.Errorf("invalid provider %q in config file: %s", p.Address, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// isKeySet returns true if the given key has been set for the key-value flag of the given name.
+func isKeySet(flags *flag.FlagSet, name, key string) bool {
+	f := flags.Lookup(name)
+	if f == nil {
+		return false
+	}
+
+	kv, ok := f.Value.(*KeyValueFlag)
+	if !ok {
+		return false
+	}
+
+	_, ok = (*kv)[key]
+
+	return ok
+}
@@ -0,0 +1,396 @@
+package internal_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jckuester/terradozer/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `
+states    = ["s3://bucket/preview/", "live/dev/"]
+recursive = true
+timeout   = "5m"
+parallel  = 20
+
+include_type = ["aws_instance", "aws_vpc"]
+exclude      = ["module.shared.*"]
+
+provider "aws" {
+  region = "eu-west-1"
+}
+
+provider "aws.us_east_1" {
+  region  = "us-east-1"
+  profile = "prod"
+}
+`
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		fileName       string
+		content        string
+		expectedStates []string
+		expectedErrMsg string
+	}{
+		{
+			name:           "HCL",
+			fileName:       "terradozer.hcl",
+			content:        testConfig,
+			expectedStates: []string{"s3://bucket/preview/", filepath.Join(dir, "live/dev") + "/"},
+		},
+		{
+			name:           "JSON",
+			fileName:       "terradozer.json",
+			content:        `{"states": ["terraform.tfstate"], "parallel": 5}`,
+			expectedStates: []string{filepath.Join(dir, "terraform.tfstate")},
+		},
+		{
+			name:           "unknown setting",
+			fileName:       "terradozer.hcl",
+			content:        `force = true`,
+			expectedErrMsg: `Unsupported argument; An argument named "force" is not expected here.`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.fileName)
+
+			err := ioutil.WriteFile(path, []byte(tc.content), 0600)
+			require.NoError(t, err)
+
+			config, err := internal.ReadConfig(path)
+			if tc.expectedErrMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErrMsg)
+
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStates, config.States)
+		})
+	}
+}
+
+func TestReadConfig_RelativePaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "terradozer.hcl")
+
+	err := ioutil.WriteFile(path, []byte(`
+states               = ["live/dev/", "../prod/terraform.tfstate", "/abs/terraform.tfstate", "s3://bucket/key"]
+plugin_dir           = ["./plugins", "~/.terraform.d/plugins"]
+dependency_lock_file = ".terraform.lock.hcl"
+providers            = "providers.json"
+`), 0600)
+	require.NoError(t, err)
+
+	config, err := internal.ReadConfig(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.Join(dir, "live/dev") + "/",
+		filepath.Join(filepath.Dir(dir), "prod/terraform.tfstate"),
+		"/abs/terraform.tfstate",
+		"s3://bucket/key",
+	}, config.States)
+	assert.Equal(t, []string{filepath.Join(dir, "plugins"), "~/.terraform.d/plugins"}, config.PluginDir)
+	assert.Equal(t, filepath.Join(dir, ".terraform.lock.hcl"), *config.DependencyLockFile)
+	assert.Equal(t, filepath.Join(dir, "providers.json"), *config.ProvidersFile)
+}
+
+func TestConfig_Apply(t *testing.T) {
+	tests := []struct {
+		name                     string
+		args                     []string
+		expectedRecursive        bool
+		expectedTimeout          string
+		expectedParallel         int
+		expectedIncludeTypes     []string
+		expectedExcludes         []string
+		expectedProviderRegions  internal.KeyValueFlag
+		expectedProviderProfiles internal.KeyValueFlag
+	}{
+		{
+			name:                 "no flags on command line",
+			expectedRecursive:    true,
+			expectedTimeout:      "5m",
+			expectedParallel:     20,
+			expectedIncludeTypes: []string{"aws_instance", "aws_vpc"},
+			expectedExcludes:     []string{"module.shared.*"},
+			expectedProviderRegions: internal.KeyValueFlag{
+				"aws":           "eu-west-1",
+				"aws.us_east_1": "us-east-1",
+			},
+			expectedProviderProfiles: internal.KeyValueFlag{"aws.us_east_1": "prod"},
+		},
+		{
+			name: "flags on command line take precedence",
+			args: []string{"-recursive=false", "-timeout", "1m", "-include-type", "aws_subnet",
+				"-provider-region", "aws.us_east_1=us-east-2"},
+			expectedTimeout:      "1m",
+			expectedParallel:     20,
+			expectedIncludeTypes: []string{"aws_subnet"},
+			expectedExcludes:     []string{"module.shared.*"},
+			expectedProviderRegions: internal.KeyValueFlag{
+				"aws":           "eu-west-1",
+				"aws.us_east_1": "us-east-2",
+			},
+			expectedProviderProfiles: internal.KeyValueFlag{"aws.us_east_1": "prod"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "terradozer.hcl")
+
+			err := ioutil.WriteFile(path, []byte(testConfig), 0600)
+			require.NoError(t, err)
+
+			var recursive bool
+			var timeout string
+			var parallel int
+			var lockTimeout time.Duration
+			var includeTypes, excludes internal.StringSliceFlag
+			var providerRegions, providerProfiles internal.KeyValueFlag
+
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.BoolVar(&recursive, "recursive", false, "")
+			flags.StringVar(&timeout, "timeout", "30s", "")
+			flags.IntVar(&parallel, "parallel", 10, "")
+			flags.DurationVar(&lockTimeout, "lock-timeout", 0, "")
+			flags.Var(&includeTypes, "include-type", "")
+			flags.Var(&excludes, "exclude", "")
+			flags.Var(&providerRegions, "provider-region", "")
+			flags.Var(&providerProfiles, "provider-profile", "")
+
+			err = flags.Parse(tc.args)
+			require.NoError(t, err)
+
+			config, err := internal.ReadConfig(path)
+			require.NoError(t, err)
+
+			err = config.Apply(flags)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedRecursive, recursive)
+			assert.Equal(t, tc.expectedTimeout, timeout)
+			assert.Equal(t, tc.expectedParallel, parallel)
+			assert.Equal(t, tc.expectedIncludeTypes, []string(includeTypes))
+			assert.Equal(t, tc.expectedExcludes, []string(excludes))
+			assert.Equal(t, tc.expectedProviderRegions, providerRegions)
+			assert.Equal(t, tc.expectedProviderProfiles, providerProfiles)
+		})
+	}
+}
+
+func TestConfig_Apply_Protect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terradozer.hcl")
+
+	err := ioutil.WriteFile(path, []byte(`
+protect {
+  types = ["aws_kms_key"]
+  tags  = { Protected = "true" }
+}
+`), 0600)
+	require.NoError(t, err)
+
+	var protectTypes internal.StringSliceFlag
+	var protectTags internal.KeyValueFlag
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&protectTypes, "protect-type", "")
+	flags.Var(&protectTags, "protect-tag", "")
+	flags.Var(&internal.StringSliceFlag{}, "protect", "")
+	flags.Var(&internal.StringSliceFlag{}, "protect-id", "")
+
+	err = flags.Parse([]string{"-protect-type", "aws_route53_zone"})
+	require.NoError(t, err)
+
+	config, err := internal.ReadConfig(path)
+	require.NoError(t, err)
+
+	err = config.Apply(flags)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"aws_route53_zone", "aws_kms_key"}, []string(protectTypes))
+	assert.Equal(t, internal.KeyValueFlag{"Protected": "true"}, protectTags)
+}
+
+func TestConfig_Apply_RetryPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terradozer.hcl")
+
+	err := ioutil.WriteFile(path, []byte(`
+max_attempts       = 10
+retry_backoff      = "5s"
+retry_backoff_cap  = "1m"
+retry_jitter       = 0.5
+max_retry_duration = "15m"
+`), 0600)
+	require.NoError(t, err)
+
+	var maxAttempts int
+	var backoff, backoffCap, maxDuration time.Duration
+	var jitter float64
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.IntVar(&maxAttempts, "max-attempts", 5, "")
+	flags.DurationVar(&backoff, "retry-backoff", 2*time.Second, "")
+	flags.DurationVar(&backoffCap, "retry-backoff-cap", 30*time.Second, "")
+	flags.Float64Var(&jitter, "retry-jitter", 0.2, "")
+	flags.DurationVar(&maxDuration, "max-retry-duration", 5*time.Minute, "")
+
+	err = flags.Parse([]string{"-max-attempts", "3"})
+	require.NoError(t, err)
+
+	config, err := internal.ReadConfig(path)
+	require.NoError(t, err)
+
+	err = config.Apply(flags)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, maxAttempts)
+	assert.Equal(t, 5*time.Second, backoff)
+	assert.Equal(t, time.Minute, backoffCap)
+	assert.Equal(t, 0.5, jitter)
+	assert.Equal(t, 15*time.Minute, maxDuration)
+}
+
+func TestConfig_Apply_Run(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terradozer.hcl")
+
+	err := ioutil.WriteFile(path, []byte(`
+interactive  = true
+output       = "json"
+lock         = false
+update_state = true
+providers    = "/etc/terradozer/providers.json"
+`), 0600)
+	require.NoError(t, err)
+
+	var interactive, lock, updateState bool
+	var output, providersFile string
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.BoolVar(&interactive, "interactive", false, "")
+	flags.StringVar(&output, "output", "text", "")
+	flags.BoolVar(&lock, "lock", true, "")
+	flags.BoolVar(&updateState, "update-state", false, "")
+	flags.StringVar(&providersFile, "providers", "", "")
+
+	err = flags.Parse([]string{"-output", "text"})
+	require.NoError(t, err)
+
+	config, err := internal.ReadConfig(path)
+	require.NoError(t, err)
+
+	err = config.Apply(flags)
+	require.NoError(t, err)
+
+	assert.True(t, interactive)
+	assert.Equal(t, "text", output)
+	assert.False(t, lock)
+	assert.True(t, updateState)
+	assert.Equal(t, "/etc/terradozer/providers.json", providersFile)
+}
+
+func TestConfig_Apply_TypeTimeouts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terradozer.hcl")
+
+	err := ioutil.WriteFile(path, []byte(`
+type_timeouts = {
+  aws_db_instance = "40m"
+  aws_nat_gateway = "10m"
+}
+`), 0600)
+	require.NoError(t, err)
+
+	var typeTimeouts internal.DurationMapFlag
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var(&typeTimeouts, "type-timeout", "")
+
+	config, err := internal.ReadConfig(path)
+	require.NoError(t, err)
+
+	err = config.Apply(flags)
+	require.NoError(t, err)
+
+	assert.Equal(t, internal.DurationMapFlag{
+		"aws_db_instance": 40 * time.Minute,
+		"aws_nat_gateway": 10 * time.Minute,
+	}, typeTimeouts)
+}
+
+func TestConfig_Apply_Plugins(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terradozer.hcl")
+
+	err := ioutil.WriteFile(path, []byte(`
+plugin_dir    = ["/opt/terraform/plugins", "/usr/share/terraform/plugins"]
+plugin_mirror = "https://mirror.example.com/providers/"
+`), 0600)
+	require.NoError(t, err)
+
+	var pluginDirs []string
+	var pluginMirror string
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.Var((*internal.StringSliceFlag)(&pluginDirs), "plugin-dir", "")
+	flags.StringVar(&pluginMirror, "plugin-mirror", "", "")
+
+	err = flags.Parse([]string{"-plugin-dir", "./plugins"})
+	require.NoError(t, err)
+
+	config, err := internal.ReadConfig(path)
+	require.NoError(t, err)
+
+	err = config.Apply(flags)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"./plugins"}, pluginDirs)
+	assert.Equal(t, "https://mirror.example.com/providers/", pluginMirror)
+}
+
+func TestConfig_Apply_ProviderVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "terradozer.hcl")
+
+	err := ioutil.WriteFile(path, []byte(`
+dependency_lock_file = "./.terraform.lock.hcl"
+
+provider_versions = {
+  aws    = "3.74.0"
+  random = "3.1.0"
+}
+`), 0600)
+	require.NoError(t, err)
+
+	var dependencyLockFile string
+	var providerVersions internal.KeyValueFlag
+
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.StringVar(&dependencyLockFile, "dependency-lock-file", "", "")
+	flags.Var(&providerVersions, "provider-version", "")
+
+	err = flags.Parse([]string{"-provider-version", "aws=3.70.0"})
+	require.NoError(t, err)
+
+	config, err := internal.ReadConfig(path)
+	require.NoError(t, err)
+
+	err = config.Apply(flags)
+	require.NoError(t, err)
+
+	// relative to the config file
+	assert.Equal(t, filepath.Join(filepath.Dir(path), ".terraform.lock.hcl"), dependencyLockFile)
+	assert.Equal(t, internal.KeyValueFlag{
+		"aws":    "3.70.0",
+		"random": "3.1.0",
+	}, providerVersions)
+}
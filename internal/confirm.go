@@ -1,32 +1,191 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"strconv"
+	"strings"
 
 	"github.com/apex/log"
+	"github.com/mattn/go-isatty"
 )
 
+// ConfirmMode is what the user has to type to confirm deleting resources.
+type ConfirmMode string
+
+const (
+	// ConfirmYes requires typing YES.
+	ConfirmYes ConfirmMode = "yes"
+	// ConfirmAccountID requires typing the ID of the AWS account (comma-separated, if multiple).
+	ConfirmAccountID ConfirmMode = "account-id"
+	// ConfirmState requires typing the location of the state file (comma-separated, if multiple).
+	ConfirmState ConfirmMode = "state"
+	// ConfirmCount requires typing the number of resources to be deleted.
+	ConfirmCount ConfirmMode = "count"
+)
+
+// ParseConfirmMode returns the confirm mode of the given name.
+func ParseConfirmMode(s string) (ConfirmMode, error) {
+	switch mode := ConfirmMode(s); mode {
+	case ConfirmYes, ConfirmAccountID, ConfirmState, ConfirmCount:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown confirm mode: %s (expected yes, account-id, state, or count)", s)
+	}
+}
+
+// Confirmation is what the user is asked to confirm before deleting resources.
+type Confirmation struct {
+	Mode ConfirmMode
+	// Accounts are the AWS accounts that resources are deleted in.
+	Accounts []AWSAccount
+	// States are the locations of the state files whose resources are deleted.
+	States []string
+	// NumOfResources is the number of resources to be deleted.
+	NumOfResources int
+}
+
+// expectedAnswer returns what the user has to type and the instruction saying so.
+func (c Confirmation) expectedAnswer() (string, string, error) {
+	switch c.Mode {
+	case ConfirmYes, "":
+		return "YES", "Only YES will be accepted.", nil
+	case ConfirmAccountID:
+		if len(c.Accounts) == 0 {
+			return "", "", fmt.Errorf("cannot confirm by AWS account ID: no AWS account found")
+		}
+
+		var ids []string
+		for _, a := range c.Accounts {
+			ids = append(ids, a.ID)
+		}
+
+		if len(ids) == 1 {
+			return ids[0], "Type the ID of the AWS account to confirm.", nil
+		}
+
+		return strings.Join(ids, ","), "Type the IDs of the AWS accounts (comma-separated) to confirm.", nil
+	case ConfirmState:
+		if len(c.States) == 1 {
+			return c.States[0], "Type the location of the state file to confirm.", nil
+		}
+
+		return strings.Join(c.States, ","), "Type the locations of the state files (comma-separated) to confirm.", nil
+	case ConfirmCount:
+		return strconv.Itoa(c.NumOfResources), "Type the number of resources to be deleted to confirm.", nil
+	default:
+		return "", "", fmt.Errorf("unknown confirm mode: %s", c.Mode)
+	}
+}
+
 // UserConfirmedDeletion asks the user to confirm before destroying any resources.
-func UserConfirmedDeletion(r io.Reader, force bool) bool {
+// No confirmation is given if the context is cancelled while waiting for the user's answer.
+//
+// An error is returned if the answer would be read from a file that is not a terminal (e.g., piped to stdin),
+// as deleting without human interaction requires force mode.
+func UserConfirmedDeletion(ctx context.Context, r io.Reader, force bool, c Confirmation) (bool, error) {
 	if force {
 		LogTitle("user will not be asked for confirmation (force mode)")
-		return true
+		return true, nil
+	}
+
+	if f, ok := r.(*os.File); ok && !IsTerminal(f) {
+		return false, fmt.Errorf("refusing to read confirmation from %s, which is not a terminal "+
+			"(use -force to delete without confirmation)", f.Name())
 	}
 
-	log.Info("Are you sure you want to delete these resources (cannot be undone)? Only YES will be accepted.")
-	fmt.Print(fmt.Sprintf("%23v", "Enter a value: "))
+	expectedAnswer, instruction, err := c.expectedAnswer()
+	if err != nil {
+		return false, err
+	}
 
-	var response string
+	log.Info(fmt.Sprintf("Are you sure you want to delete these resources%s (cannot be undone)? %s",
+		inAccounts(c.Accounts), instruction))
+	// prompt on stderr (like all logs) to keep stdout clean for the JSON output
+	fmt.Fprint(os.Stderr, fmt.Sprintf("%23v", "Enter a value: "))
 
-	_, err := fmt.Fscanln(r, &response)
+	response, err := readLineWithContext(ctx, r)
 	if err != nil {
+		if ctx.Err() != nil {
+			return false, nil
+		}
+
 		log.Fatal(err.Error())
 	}
 
-	if response == "YES" {
-		return true
+	return response == expectedAnswer, nil
+}
+
+// IsTerminal returns true if the given file is a terminal.
+func IsTerminal(f *os.File) bool {
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// readLineWithContext reads a line (without surrounding whitespace) from r. Reading is given up
+// once the context is cancelled, in which case the error of the context is returned.
+//
+// Note: r is read byte by byte to not consume any input beyond the line.
+func readLineWithContext(ctx context.Context, r io.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+
+	results := make(chan result, 1)
+
+	go func() {
+		var line []byte
+
+		b := make([]byte, 1)
+
+		for {
+			n, err := r.Read(b)
+			if n > 0 {
+				if b[0] == '\n' {
+					break
+				}
+
+				line = append(line, b[0])
+			}
+
+			if err != nil {
+				if err == io.EOF && len(line) > 0 {
+					break
+				}
+
+				results <- result{err: err}
+
+				return
+			}
+		}
+
+		results <- result{line: strings.TrimSpace(string(line))}
+	}()
+
+	select {
+	case res := <-results:
+		return res.line, res.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// inAccounts returns the given AWS accounts as part of the confirmation question.
+func inAccounts(accounts []AWSAccount) string {
+	if len(accounts) == 0 {
+		return ""
+	}
+
+	var result []string
+	for _, a := range accounts {
+		result = append(result, a.String())
+	}
+
+	if len(accounts) == 1 {
+		return " in AWS account " + result[0]
 	}
 
-	return false
+	return " in AWS accounts " + strings.Join(result, ", ")
 }
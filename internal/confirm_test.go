@@ -1,19 +1,24 @@
 package internal_test
 
 import (
+	"context"
+	"io/ioutil"
 	"strings"
 	"testing"
 
 	"github.com/jckuester/terradozer/internal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUserConfirmedDeletion(t *testing.T) {
 	tests := []struct {
 		name                 string
 		force                bool
+		confirmation         internal.Confirmation
 		userInput            string
 		expectedConfirmation bool
+		expectedErrMsg       string
 	}{
 		{
 			name:                 "confirmed with YES",
@@ -29,11 +34,112 @@ func TestUserConfirmedDeletion(t *testing.T) {
 			force:                true,
 			expectedConfirmation: true,
 		},
+		{
+			name: "confirmed with account ID",
+			confirmation: internal.Confirmation{
+				Mode:     internal.ConfirmAccountID,
+				Accounts: []internal.AWSAccount{{ID: "111111111111", Region: "us-east-1"}},
+			},
+			userInput:            "111111111111\n",
+			expectedConfirmation: true,
+		},
+		{
+			name: "confirmed with YES instead of account ID",
+			confirmation: internal.Confirmation{
+				Mode:     internal.ConfirmAccountID,
+				Accounts: []internal.AWSAccount{{ID: "111111111111", Region: "us-east-1"}},
+			},
+			userInput: "YES\n",
+		},
+		{
+			name: "confirmed with multiple account IDs",
+			confirmation: internal.Confirmation{
+				Mode: internal.ConfirmAccountID,
+				Accounts: []internal.AWSAccount{
+					{ID: "111111111111", Region: "us-east-1"},
+					{ID: "222222222222", Region: "eu-west-1"},
+				},
+			},
+			userInput:            "111111111111,222222222222\n",
+			expectedConfirmation: true,
+		},
+		{
+			name:           "confirm by account ID without account",
+			confirmation:   internal.Confirmation{Mode: internal.ConfirmAccountID},
+			userInput:      "111111111111\n",
+			expectedErrMsg: "cannot confirm by AWS account ID: no AWS account found",
+		},
+		{
+			name: "confirmed with location of state",
+			confirmation: internal.Confirmation{
+				Mode:   internal.ConfirmState,
+				States: []string{"s3://bucket/dev/terraform.tfstate"},
+			},
+			userInput:            "s3://bucket/dev/terraform.tfstate\n",
+			expectedConfirmation: true,
+		},
+		{
+			name: "confirmed with number of resources",
+			confirmation: internal.Confirmation{
+				Mode:           internal.ConfirmCount,
+				NumOfResources: 42,
+			},
+			userInput:            "42\n",
+			expectedConfirmation: true,
+		},
+		{
+			name: "confirmed with wrong number of resources",
+			confirmation: internal.Confirmation{
+				Mode:           internal.ConfirmCount,
+				NumOfResources: 42,
+			},
+			userInput: "41\n",
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			actualConfirmation := internal.UserConfirmedDeletion(strings.NewReader(tc.userInput), tc.force)
+			actualConfirmation, err := internal.UserConfirmedDeletion(context.Background(),
+				strings.NewReader(tc.userInput), tc.force, tc.confirmation)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+			}
+
 			assert.Equal(t, tc.expectedConfirmation, actualConfirmation)
 		})
 	}
 }
+
+func TestUserConfirmedDeletion_NotATerminal(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "input")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString("YES\n")
+	require.NoError(t, err)
+
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+
+	actualConfirmation, err := internal.UserConfirmedDeletion(context.Background(), f, false,
+		internal.Confirmation{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "which is not a terminal")
+	assert.False(t, actualConfirmation)
+
+	actualConfirmation, err = internal.UserConfirmedDeletion(context.Background(), f, true,
+		internal.Confirmation{})
+	require.NoError(t, err)
+	assert.True(t, actualConfirmation)
+}
+
+func TestParseConfirmMode(t *testing.T) {
+	actualMode, err := internal.ParseConfirmMode("account-id")
+	require.NoError(t, err)
+	assert.Equal(t, internal.ConfirmAccountID, actualMode)
+
+	_, err = internal.ParseConfirmMode("YES")
+	assert.EqualError(t, err, "unknown confirm mode: YES (expected yes, account-id, state, or count)")
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StringSliceFlag is a flag that can be set multiple times and accepts comma-separated values,
+// e.g., -include-type aws_instance,aws_vpc -include-type aws_subnet.
+type StringSliceFlag []string
+
+// String returns the comma-separated values of the flag.
+func (f *StringSliceFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set appends the comma-separated values to the flag.
+func (f *StringSliceFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			*f = append(*f, v)
+		}
+	}
+
+	return nil
+}
+
+// KeyValueFlag is a flag that can be set multiple times and accepts comma-separated key=value pairs,
+// e.g., -provider-region aws.us_east_1=us-east-1,aws.eu=eu-west-1 -provider-region aws=eu-central-1.
+type KeyValueFlag map[string]string
+
+// String returns the comma-separated key=value pairs of the flag, sorted by key.
+func (f *KeyValueFlag) String() string {
+	var pairs []string
+
+	for k, v := range *f {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// Set adds the comma-separated key=value pairs to the flag.
+func (f *KeyValueFlag) Set(value string) error {
+	if *f == nil {
+		*f = KeyValueFlag{}
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return fmt.Errorf("expected key=value: %s", pair)
+		}
+
+		(*f)[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return nil
+}
+
+// DurationMapFlag is a flag that can be set multiple times and accepts comma-separated key=duration pairs,
+// e.g., -type-timeout aws_db_instance=40m,aws_nat_gateway=10m.
+type DurationMapFlag map[string]time.Duration
+
+// String returns the comma-separated key=duration pairs of the flag, sorted by key.
+func (f *DurationMapFlag) String() string {
+	var pairs []string
+
+	for k, v := range *f {
+		pairs = append(pairs, k+"="+v.String())
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// Set adds the comma-separated key=duration pairs to the flag.
+func (f *DurationMapFlag) Set(value string) error {
+	var kv KeyValueFlag
+
+	err := kv.Set(value)
+	if err != nil {
+		return err
+	}
+
+	if *f == nil {
+		*f = DurationMapFlag{}
+	}
+
+	for k, v := range kv {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid duration for %s: %s", k, err)
+		}
+
+		(*f)[k] = d
+	}
+
+	return nil
+}
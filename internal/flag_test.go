@@ -0,0 +1,157 @@
+package internal_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/jckuester/terradozer/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringSliceFlag(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedValues []string
+	}{
+		{
+			name: "flag not set",
+		},
+		{
+			name:           "single value",
+			args:           []string{"-type", "aws_vpc"},
+			expectedValues: []string{"aws_vpc"},
+		},
+		{
+			name:           "comma-separated values",
+			args:           []string{"-type", "aws_vpc, aws_subnet,"},
+			expectedValues: []string{"aws_vpc", "aws_subnet"},
+		},
+		{
+			name:           "flag set multiple times",
+			args:           []string{"-type", "aws_vpc", "-type", "aws_instance,aws_subnet"},
+			expectedValues: []string{"aws_vpc", "aws_instance", "aws_subnet"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var actualValues internal.StringSliceFlag
+
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.Var(&actualValues, "type", "")
+
+			err := flags.Parse(tc.args)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedValues, []string(actualValues))
+		})
+	}
+}
+
+func TestKeyValueFlag(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedValues internal.KeyValueFlag
+		expectedErr    bool
+	}{
+		{
+			name: "flag not set",
+		},
+		{
+			name:           "single pair",
+			args:           []string{"-region", "aws.us_east_1=us-east-1"},
+			expectedValues: internal.KeyValueFlag{"aws.us_east_1": "us-east-1"},
+		},
+		{
+			name:           "comma-separated pairs",
+			args:           []string{"-region", "aws=eu-west-1, aws.us_east_1=us-east-1,"},
+			expectedValues: internal.KeyValueFlag{"aws": "eu-west-1", "aws.us_east_1": "us-east-1"},
+		},
+		{
+			name:           "flag set multiple times",
+			args:           []string{"-region", "aws=eu-west-1", "-region", "aws=eu-central-1,aws.x=us-east-1"},
+			expectedValues: internal.KeyValueFlag{"aws": "eu-central-1", "aws.x": "us-east-1"},
+		},
+		{
+			name:        "missing value",
+			args:        []string{"-region", "aws"},
+			expectedErr: true,
+		},
+		{
+			name:        "missing key",
+			args:        []string{"-region", "=us-east-1"},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var actualValues internal.KeyValueFlag
+
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.SetOutput(ioutil.Discard)
+			flags.Var(&actualValues, "region", "")
+
+			err := flags.Parse(tc.args)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedValues, actualValues)
+		})
+	}
+}
+
+func TestDurationMapFlag(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedValues internal.DurationMapFlag
+		expectedErr    bool
+	}{
+		{
+			name: "flag not set",
+		},
+		{
+			name: "comma-separated pairs",
+			args: []string{"-type-timeout", "aws_db_instance=40m,aws_nat_gateway=10m"},
+			expectedValues: internal.DurationMapFlag{
+				"aws_db_instance": 40 * time.Minute,
+				"aws_nat_gateway": 10 * time.Minute,
+			},
+		},
+		{
+			name:           "flag set multiple times",
+			args:           []string{"-type-timeout", "aws_db_instance=40m", "-type-timeout", "aws_db_instance=1h"},
+			expectedValues: internal.DurationMapFlag{"aws_db_instance": time.Hour},
+		},
+		{
+			name:        "invalid duration",
+			args:        []string{"-type-timeout", "aws_db_instance=40"},
+			expectedErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var actualValues internal.DurationMapFlag
+
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.SetOutput(ioutil.Discard)
+			flags.Var(&actualValues, "type-timeout", "")
+
+			err := flags.Parse(tc.args)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedValues, actualValues)
+		})
+	}
+}
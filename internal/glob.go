@@ -0,0 +1,32 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// MatchesAnyGlob returns true if any of the given strings matches any of the given glob patterns,
+// in which "*" matches any sequence of characters and "?" matches any single character.
+// All other characters match literally.
+func MatchesAnyGlob(patterns []string, s ...string) bool {
+	for _, pattern := range patterns {
+		re := globToRegexp(pattern)
+
+		for _, v := range s {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// globToRegexp converts a glob pattern into an anchored regular expression.
+func globToRegexp(pattern string) *regexp.Regexp {
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+
+	return regexp.MustCompile("^" + expr + "$")
+}
@@ -1,8 +1,12 @@
 package internal
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/cli"
@@ -15,15 +19,156 @@ const DefaultInitialPadding = 3
 // ExtraPadding is the double of the DefaultInitialPadding.
 const ExtraPadding = DefaultInitialPadding * 2
 
+const (
+	// LogFormatText is the default, human-readable log format.
+	LogFormatText = "text"
+	// LogFormatJSON logs one JSON object per event.
+	LogFormatJSON = "json"
+)
+
+//nolint:gochecknoglobals
+var (
+	// jsonLogs is true if logs are written in JSON (see SetLogFormat).
+	jsonLogs bool
+
+	// phase is the current phase of a run, which is added to each event logged in JSON (see SetPhase).
+	phase   string
+	phaseMu sync.RWMutex
+)
+
+// SetLogFormat sets the handler of the log library to write logs in the given format (text or json) to w.
+func SetLogFormat(format string, w io.Writer) error {
+	switch format {
+	case LogFormatText:
+		jsonLogs = false
+
+		log.SetHandler(cli.New(w))
+	case LogFormatJSON:
+		jsonLogs = true
+
+		log.SetHandler(NewJSONHandler(w))
+	default:
+		return fmt.Errorf("unknown log format: %s (expected text or json)", format)
+	}
+
+	return nil
+}
+
+// IsJSONLogs returns true if logs are written in JSON (see SetLogFormat).
+func IsJSONLogs() bool {
+	return jsonLogs
+}
+
+// SetPhase sets the current phase of a run (e.g., "read" or "destroy"),
+// which is added as field to each event logged in JSON.
+func SetPhase(p string) {
+	phaseMu.Lock()
+	defer phaseMu.Unlock()
+
+	phase = p
+}
+
+func currentPhase() string {
+	phaseMu.RLock()
+	defer phaseMu.RUnlock()
+
+	return phase
+}
+
 // LogTitle pretty prints a given title.
 func LogTitle(title string) {
-	cli.Default.Padding = DefaultInitialPadding
+	if jsonLogs {
+		log.Info(title)
+		return
+	}
+
+	handler := cli.Default
+	if logger, ok := log.Log.(*log.Logger); ok {
+		if h, ok := logger.Handler.(*cli.Handler); ok {
+			handler = h
+		}
+	}
+
+	handler.Padding = DefaultInitialPadding
 
 	log.Info(color.New(color.Bold).Sprint(strings.ToUpper(title)))
 
-	cli.Default.Padding = ExtraPadding
+	handler.Padding = ExtraPadding
 }
 
+// Pad pads a log message, so that the fields of subsequent messages are aligned.
+// Messages logged in JSON are not padded.
 func Pad(s string) string {
+	if jsonLogs {
+		return s
+	}
+
 	return fmt.Sprintf("%-50v", s)
 }
+
+// LogResource returns a log entry with the ID of the given resource. In JSON, the entry
+// additionally has the type and address (if any) of the resource.
+func LogResource(r interface {
+	Type() string
+	ID() string
+}) *log.Entry {
+	if !jsonLogs {
+		return log.WithField("id", r.ID())
+	}
+
+	fields := log.Fields{
+		"type": r.Type(),
+		"id":   r.ID(),
+	}
+
+	if addressable, ok := r.(interface{ Address() string }); ok && addressable.Address() != "" {
+		fields["address"] = addressable.Address()
+	}
+
+	return log.WithFields(fields)
+}
+
+// JSONHandler is a handler of the log library that writes one JSON object per event,
+// with the fields of the event at the top level.
+type JSONHandler struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONHandler creates a handler that writes events as JSON to w.
+func NewJSONHandler(w io.Writer) *JSONHandler {
+	return &JSONHandler{w: w}
+}
+
+// HandleLog implements log.Handler.
+func (h *JSONHandler) HandleLog(e *log.Entry) error {
+	event := map[string]interface{}{}
+
+	for k, v := range e.Fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+
+		event[k] = v
+	}
+
+	if p := currentPhase(); p != "" {
+		event["phase"] = p
+	}
+
+	event["time"] = e.Timestamp.UTC().Format(time.RFC3339Nano)
+	event["level"] = e.Level.String()
+	event["message"] = e.Message
+
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	_, err = h.w.Write(append(content, '\n'))
+
+	return err
+}
@@ -0,0 +1,101 @@
+package internal_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/jckuester/terradozer/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type loggedResource struct {
+	rType, id, address string
+}
+
+func (r loggedResource) Type() string    { return r.rType }
+func (r loggedResource) ID() string      { return r.id }
+func (r loggedResource) Address() string { return r.address }
+
+func TestSetLogFormat_JSON(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := internal.SetLogFormat(internal.LogFormatJSON, &buf)
+	require.NoError(t, err)
+
+	defer func() {
+		internal.SetPhase("")
+
+		err := internal.SetLogFormat(internal.LogFormatText, &bytes.Buffer{})
+		require.NoError(t, err)
+	}()
+
+	internal.SetPhase("destroy")
+
+	internal.LogTitle("Starting to delete resources")
+	internal.LogResource(loggedResource{rType: "aws_vpc", id: "vpc-1234", address: "module.app.aws_vpc.test"}).
+		WithField("attempt", 2).Error(internal.Pad("aws_vpc"))
+	internal.LogResource(loggedResource{rType: "aws_subnet", id: "subnet-1234"}).
+		WithError(fmt.Errorf("some error")).Warn(internal.Pad("aws_subnet"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+
+	expectedEvents := []map[string]interface{}{
+		{
+			"level":   "info",
+			"message": "Starting to delete resources",
+			"phase":   "destroy",
+		},
+		{
+			"level":   "error",
+			"message": "aws_vpc",
+			"phase":   "destroy",
+			"type":    "aws_vpc",
+			"id":      "vpc-1234",
+			"address": "module.app.aws_vpc.test",
+			"attempt": float64(2),
+		},
+		{
+			"level":   "warn",
+			"message": "aws_subnet",
+			"phase":   "destroy",
+			"type":    "aws_subnet",
+			"id":      "subnet-1234",
+			"error":   "some error",
+		},
+	}
+
+	for i, line := range lines {
+		var actualEvent map[string]interface{}
+
+		err := json.Unmarshal([]byte(line), &actualEvent)
+		require.NoError(t, err)
+
+		assert.NotEmpty(t, actualEvent["time"])
+		delete(actualEvent, "time")
+
+		assert.Equal(t, expectedEvents[i], actualEvent)
+	}
+}
+
+func TestSetLogFormat_Text(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := internal.SetLogFormat(internal.LogFormatText, &buf)
+	require.NoError(t, err)
+
+	assert.False(t, internal.IsJSONLogs())
+
+	log.Info(internal.Pad("using state"))
+	assert.Contains(t, buf.String(), fmt.Sprintf("%-50v", "using state"))
+}
+
+func TestSetLogFormat_Unknown(t *testing.T) {
+	err := internal.SetLogFormat("xml", &bytes.Buffer{})
+	assert.EqualError(t, err, "unknown log format: xml (expected text or json)")
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// ProviderSettings configure a single provider configuration (e.g., an aliased AWS provider),
+// as its region and profile are not stored as part of the state.
+type ProviderSettings struct {
+	Region  string `json:"region,omitempty"`
+	Profile string `json:"profile,omitempty"`
+}
+
+// ReadProviderSettings reads a JSON file mapping addresses of provider configurations to their settings, e.g.:
+//
+//	{
+//	  "aws": {"region": "eu-west-1"},
+//	  "aws.us_east_1": {"region": "us-east-1", "profile": "prod"}
+//	}
+func ReadProviderSettings(path string) (map[string]ProviderSettings, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]ProviderSettings
+
+	err = json.Unmarshal(content, &result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse provider settings (%s): %s", path, err)
+	}
+
+	return result, nil
+}
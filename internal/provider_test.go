@@ -0,0 +1,54 @@
+package internal_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jckuester/terradozer/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadProviderSettings(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		expectedSettings map[string]internal.ProviderSettings
+		expectedErr      bool
+	}{
+		{
+			name: "region and profile",
+			content: `{
+  "aws": {"region": "eu-west-1"},
+  "aws.us_east_1": {"region": "us-east-1", "profile": "prod"}
+}`,
+			expectedSettings: map[string]internal.ProviderSettings{
+				"aws":           {Region: "eu-west-1"},
+				"aws.us_east_1": {Region: "us-east-1", Profile: "prod"},
+			},
+		},
+		{
+			name:        "invalid JSON",
+			content:     `{"aws": "eu-west-1"}`,
+			expectedErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "providers.json")
+
+			err := ioutil.WriteFile(path, []byte(tc.content), 0600)
+			require.NoError(t, err)
+
+			actualSettings, err := internal.ReadProviderSettings(path)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedSettings, actualSettings)
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/hashicorp/terraform/addrs"
+)
+
+// SelectItem is a resource that the user can select for deletion (see UserSelectedItems).
+type SelectItem struct {
+	// Address is the absolute address of the resource instance (e.g., module.app.aws_vpc.test).
+	Address string
+	Type    string
+	ID      string
+}
+
+const selectHelp = `Toggle resources to delete, then press enter (or type "done") to continue:
+  <n>, <n>-<m>, <n>,<m>   toggle resources by number
+  type <pattern>          toggle all resources whose type matches a glob pattern (e.g., aws_iam_*)
+  module <pattern>        toggle all resources of modules (incl. nested ones) matching a glob pattern
+  all, none               select all or no resources
+  quit                    delete nothing`
+
+// UserSelectedItems lets the user toggle which of the given items to select, reading commands (one per line)
+// from r and printing the list of items to w. All items are selected initially.
+//
+// Returns for each item whether it has been selected. Nothing is selected if the user quits
+// or the context is cancelled.
+func UserSelectedItems(ctx context.Context, r io.Reader, w io.Writer, items []SelectItem) []bool {
+	selected := make([]bool, len(items))
+	for i := range selected {
+		selected[i] = true
+	}
+
+	fmt.Fprintln(w, selectHelp)
+
+	for {
+		printItems(w, items, selected)
+		fmt.Fprint(w, fmt.Sprintf("%23v", "Enter a command: "))
+
+		line, err := readLineWithContext(ctx, r)
+		if err != nil {
+			return make([]bool, len(items))
+		}
+
+		switch line {
+		case "", "done":
+			return selected
+		case "quit":
+			return make([]bool, len(items))
+		}
+
+		err = toggle(line, items, selected)
+		if err != nil {
+			fmt.Fprintln(w, color.RedString("Error: %s", err))
+			fmt.Fprintln(w, selectHelp)
+		}
+	}
+}
+
+// toggle changes the selection of items according to the given command.
+func toggle(command string, items []SelectItem, selected []bool) error {
+	fields := strings.Fields(command)
+
+	switch fields[0] {
+	case "all", "none":
+		for i := range selected {
+			selected[i] = fields[0] == "all"
+		}
+
+		return nil
+	case "type", "module":
+		if len(fields) != 2 {
+			return fmt.Errorf("expected a single pattern: %s", command)
+		}
+
+		var matches []int
+
+		for i, item := range items {
+			if fields[0] == "type" && MatchesAnyGlob(fields[1:], item.Type) ||
+				fields[0] == "module" && MatchesAnyGlob(fields[1:], modulePathsOf(item.Address)...) {
+				matches = append(matches, i)
+			}
+		}
+
+		if len(matches) == 0 {
+			return fmt.Errorf("no resources match: %s", command)
+		}
+
+		toggleAll(matches, selected)
+
+		return nil
+	}
+
+	var numbers []int
+
+	for _, field := range strings.FieldsFunc(command, func(r rune) bool { return r == ',' || r == ' ' }) {
+		from, to := field, field
+		if i := strings.Index(field, "-"); i > 0 {
+			from, to = field[:i], field[i+1:]
+		}
+
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return fmt.Errorf("unknown command: %s", command)
+		}
+
+		end, err := strconv.Atoi(to)
+		if err != nil {
+			return fmt.Errorf("unknown command: %s", command)
+		}
+
+		if start < 1 || end > len(items) || start > end {
+			return fmt.Errorf("invalid number or range (1-%d): %s", len(items), field)
+		}
+
+		for n := start; n <= end; n++ {
+			numbers = append(numbers, n-1)
+		}
+	}
+
+	for _, i := range numbers {
+		selected[i] = !selected[i]
+	}
+
+	return nil
+}
+
+// toggleAll selects all given items if any of them is not selected; otherwise, all of them are deselected.
+func toggleAll(items []int, selected []bool) {
+	allSelected := true
+
+	for _, i := range items {
+		if !selected[i] {
+			allSelected = false
+		}
+	}
+
+	for _, i := range items {
+		selected[i] = !allSelected
+	}
+}
+
+func printItems(w io.Writer, items []SelectItem, selected []bool) {
+	numOfSelected := 0
+
+	for i, item := range items {
+		mark := "[ ]"
+		if selected[i] {
+			mark = "[x]"
+			numOfSelected++
+		}
+
+		name := item.Address
+		if name == "" {
+			name = item.Type
+		}
+
+		fmt.Fprintf(w, "%6v %4d  %-60v id=%s\n", mark, i+1, name, item.ID)
+	}
+
+	fmt.Fprintf(w, "%6v selected resources: %d of %d\n", "", numOfSelected, len(items))
+}
+
+// modulePathsOf returns the path of the module of the resource instance with the given address and the paths
+// of all its parent modules (e.g., module.a.module.b and module.a).
+func modulePathsOf(address string) []string {
+	addr, diags := addrs.ParseAbsResourceInstanceStr(address)
+	if diags.HasErrors() {
+		return nil
+	}
+
+	var result []string
+
+	for i := len(addr.Module); i > 0; i-- {
+		result = append(result, addr.Module[:i].String())
+	}
+
+	return result
+}
@@ -0,0 +1,94 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jckuester/terradozer/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserSelectedItems(t *testing.T) {
+	items := []internal.SelectItem{
+		{Address: "aws_vpc.test", Type: "aws_vpc", ID: "vpc-1"},
+		{Address: "module.app.aws_instance.web[0]", Type: "aws_instance", ID: "i-1"},
+		{Address: "module.app.aws_instance.web[1]", Type: "aws_instance", ID: "i-2"},
+		{Address: "module.app.module.db.aws_db_instance.main", Type: "aws_db_instance", ID: "db-1"},
+		{Address: "aws_iam_role.test", Type: "aws_iam_role", ID: "role-1"},
+	}
+
+	tests := []struct {
+		name             string
+		userInput        string
+		expectedSelected []bool
+		expectedOutput   []string
+	}{
+		{
+			name:             "keep all selected",
+			userInput:        "\n",
+			expectedSelected: []bool{true, true, true, true, true},
+			expectedOutput:   []string{"selected resources: 5 of 5"},
+		},
+		{
+			name:             "toggle by number",
+			userInput:        "1\n5\ndone\n",
+			expectedSelected: []bool{false, true, true, true, false},
+			expectedOutput:   []string{"selected resources: 3 of 5"},
+		},
+		{
+			name:             "toggle by range and list",
+			userInput:        "2-3,5\n\n",
+			expectedSelected: []bool{true, false, false, true, false},
+		},
+		{
+			name:             "toggle by type",
+			userInput:        "type aws_instance\n\n",
+			expectedSelected: []bool{true, false, false, true, true},
+		},
+		{
+			name:             "toggle type with some resources deselected selects all",
+			userInput:        "2\ntype aws_instance\n\n",
+			expectedSelected: []bool{true, true, true, true, true},
+		},
+		{
+			name:             "toggle by module includes nested modules",
+			userInput:        "none\nmodule module.app\n\n",
+			expectedSelected: []bool{false, true, true, true, false},
+		},
+		{
+			name:             "invalid command",
+			userInput:        "6\nfoo\nnone\n1\n\n",
+			expectedSelected: []bool{true, false, false, false, false},
+			expectedOutput: []string{
+				"Error: invalid number or range (1-5): 6",
+				"Error: unknown command: foo",
+			},
+		},
+		{
+			name:             "quit",
+			userInput:        "1\nquit\n",
+			expectedSelected: []bool{false, false, false, false, false},
+		},
+		{
+			name:             "end of input",
+			userInput:        "1\n",
+			expectedSelected: []bool{false, false, false, false, false},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var output bytes.Buffer
+
+			actualSelected := internal.UserSelectedItems(context.Background(), strings.NewReader(tc.userInput),
+				&output, items)
+
+			assert.Equal(t, tc.expectedSelected, actualSelected)
+
+			for _, expectedOutput := range tc.expectedOutput {
+				assert.Contains(t, output.String(), expectedOutput)
+			}
+		})
+	}
+}
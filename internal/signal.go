@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/apex/log"
+)
+
+// CancelOnInterrupt returns a copy of the parent context that is cancelled on the first interrupt
+// (SIGINT or SIGTERM). On a second interrupt, the process exits immediately.
+//
+// Calling the returned stop function stops listening for interrupts and cancels the context.
+func CancelOnInterrupt(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-interrupts:
+			log.Warn("interrupted: waiting for destroys in progress to finish (interrupt again to force exit)")
+			cancel()
+		case <-done:
+			return
+		}
+
+		select {
+		case <-interrupts:
+			log.Error("interrupted again: forcing exit")
+			os.Exit(1)
+		case <-done:
+			return
+		}
+	}()
+
+	stop := func() {
+		signal.Stop(interrupts)
+		close(done)
+		cancel()
+	}
+
+	return ctx, stop
+}
@@ -0,0 +1,41 @@
+//go:build !windows
+// +build !windows
+
+package internal_test
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jckuester/terradozer/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelOnInterrupt(t *testing.T) {
+	ctx, stop := internal.CancelOnInterrupt(context.Background())
+	defer stop()
+
+	require.NoError(t, ctx.Err())
+
+	err := syscall.Kill(os.Getpid(), syscall.SIGINT)
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		assert.Equal(t, context.Canceled, ctx.Err())
+	case <-time.After(5 * time.Second):
+		t.Fatal("context has not been cancelled on interrupt")
+	}
+}
+
+func TestCancelOnInterrupt_Stop(t *testing.T) {
+	ctx, stop := internal.CancelOnInterrupt(context.Background())
+
+	stop()
+
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
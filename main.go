@@ -4,6 +4,7 @@ package main
 //go:generate mockgen -source=pkg/resource/destroy.go -destination=pkg/resource/destroy_mock_test.go -package=resource_test
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -13,11 +14,12 @@ import (
 	"time"
 
 	"github.com/apex/log"
-	"github.com/apex/log/handlers/cli"
 	"github.com/fatih/color"
 	"github.com/jckuester/awstools-lib/terraform"
 	"github.com/jckuester/awstools-lib/terraform/provider"
 	"github.com/jckuester/terradozer/internal"
+	"github.com/jckuester/terradozer/pkg/plan"
+	"github.com/jckuester/terradozer/pkg/plugin"
 	"github.com/jckuester/terradozer/pkg/resource"
 	"github.com/jckuester/terradozer/pkg/state"
 )
@@ -26,110 +28,341 @@ func main() {
 	os.Exit(mainExitCode())
 }
 
-//nolint:wsl
 func mainExitCode() int {
-	var dryRun bool
-	var force bool
-	var logDebug bool
-	var parallel int
-	var timeout string
-	var version bool
+	var o options
 
-	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	flags := newFlagSet(&o)
 
-	flags.Usage = func() {
-		printHelp(flags)
-	}
-
-	flags.StringVar(&timeout, "timeout", "30s", "Amount of time to wait for a destroy of a resource to finish")
-	flags.BoolVar(&dryRun, "dry-run", false, "Show what would be destroyed")
-	flags.BoolVar(&force, "force", false, "Destroy without asking for confirmation")
-	flags.BoolVar(&logDebug, "debug", false, "Enable debug logging")
-	flags.IntVar(&parallel, "parallel", 10, "Limit the number of concurrent destroy operations")
-	flags.BoolVar(&version, "version", false, "Show application version")
+	command, commandArgs := parseCommand(os.Args[1:])
 
-	_ = flags.Parse(os.Args[1:])
+	_ = flags.Parse(commandArgs)
 	args := flags.Args()
 
-	log.SetHandler(cli.Default)
+	err := internal.SetLogFormat(o.logFormat, os.Stderr)
+	if err != nil {
+		printError("Error: %s\n", err)
+		printHelp(flags)
 
-	fmt.Println()
-	defer fmt.Println()
+		return 1
+	}
+
+	// keep stdout clean for the JSON report and logs
+	if o.output != outputJSON && o.logFormat != internal.LogFormatJSON {
+		fmt.Println()
+		defer fmt.Println()
+	}
 
-	if logDebug {
+	if o.logDebug {
 		log.SetLevel(log.DebugLevel)
 	}
 
 	// discard TRACE logs of GRPCProvider
 	stdlog.SetOutput(ioutil.Discard)
 
-	if version {
+	if o.version {
 		fmt.Println(internal.BuildVersionString())
 		return 0
 	}
 
-	if force && dryRun {
-		fmt.Fprint(os.Stderr, color.RedString("Error:️ -force and -dry-run flag cannot be used together\n"))
+	if o.configFile != "" {
+		config, err := internal.ReadConfig(o.configFile)
+		if err != nil {
+			printError("Error: %s\n", err)
+
+			return 1
+		}
+
+		err = config.Apply(flags)
+		if err != nil {
+			printError("Error: %s\n", err)
+			printHelp(flags)
+
+			return 1
+		}
+
+		if len(args) == 0 {
+			args = config.States
+		}
+
+		err = internal.SetLogFormat(o.logFormat, os.Stderr)
+		if err != nil {
+			printError("Error: %s\n", err)
+			printHelp(flags)
+
+			return 1
+		}
+	}
+
+	err = o.validate(command, flags)
+	if err != nil {
+		printError("Error: %s\n", err)
 		printHelp(flags)
 
 		return 1
 	}
 
-	timeoutDuration, err := time.ParseDuration(timeout)
+	var planToApply *plan.Plan
+
+	if command == commandApply {
+		if len(args) != 1 {
+			printError("Error: path to a single plan file expected\n")
+			printHelp(flags)
+
+			return 1
+		}
+
+		planToApply, err = plan.Read(args[0])
+		if err != nil {
+			printError("Error:️ failed to read plan: %s\n", err)
+
+			return 1
+		}
+
+		args = planToApply.Locations()
+
+		if len(args) == 0 {
+			internal.LogTitle("plan contains no states")
+			return 0
+		}
+	}
+
+	err = o.validateLocations(args)
 	if err != nil {
-		fmt.Fprint(os.Stderr, color.RedString("Error: failed to parse timeout flag: %s\n", err))
+		printError("Error: %s\n", err)
 		printHelp(flags)
 
 		return 1
 	}
 
-	if len(args) == 0 {
-		fmt.Fprint(os.Stderr, color.RedString("Error: path to Terraform state file expected\n"))
-		printHelp(flags)
+	if command == commandPlan {
+		for i, location := range args {
+			args[i], err = absLocation(location)
+			if err != nil {
+				printError("Error:️ %s\n", err)
+
+				return 1
+			}
+		}
+	}
+
+	return run(command, args, planToApply, o)
+}
+
+// stateResources are the resources found in the states of a run.
+type stateResources struct {
+	states []*state.State
+	// all are the resources of all states, and byState the same resources grouped by state.
+	all     []terraform.UpdatableResource
+	byState map[*state.State][]terraform.UpdatableResource
+	// planned are the parts of the plan to apply for each state; nil if no plan is applied.
+	planned map[*state.State]plan.State
+	// ignored are the resources of providers without version, which are not part of all.
+	ignored []*resource.Resource
+}
+
+// run reads the states at the given locations, launches their providers, and processes the resources found
+// according to the given command (see parseCommand) and options (see processResources). If a plan is given,
+// only its resources are processed. Returns the exit code.
+func run(command string, locations []string, planToApply *plan.Plan, o options) int {
+	providerSettings, err := readProviderSettings(o.providerSettingsFile, o.providerRegions, o.providerProfiles)
+	if err != nil {
+		printError("Error:️ failed to read provider settings: %s\n", err)
 
 		return 1
 	}
 
-	pathToState := args[0]
+	// on interrupt, stop gracefully to release locks, close the providers,
+	// and report what has been deleted so far
+	ctx, stop := internal.CancelOnInterrupt(context.Background())
+	defer stop()
+
+	internal.SetPhase("read")
+
+	var sources []state.Source
+
+	for _, location := range locations {
+		sourcesOfLocation, err := findSources(location, o.recursive)
+		if err != nil {
+			printError("Error:️ failed to read Terraform state file: %s\n", err)
+
+			return 1
+		}
+
+		sources = append(sources, sourcesOfLocation...)
+	}
+
+	if o.lock {
+		unlock, err := lockSources(ctx, sources, o.lockTable, o.lockTimeout)
+		if err != nil {
+			printError("Error:️ failed to lock Terraform state: %s\n", err)
+
+			return 1
+		}
+
+		defer unlock()
+	}
 
-	tfstate, err := state.New(pathToState)
+	tfstates, err := readStates(sources, o.filter)
 	if err != nil {
-		fmt.Fprint(os.Stderr, color.RedString("Error:️ failed to read Terraform state file: %s\n", err))
+		printError("Error:️ failed to read Terraform state file: %s\n", err)
 
 		return 1
 	}
 
-	internal.LogTitle("reading state")
-	log.WithField("file", pathToState).Info(internal.Pad("using state"))
+	providers, upgraders, ignoredResources, err := setupProviders(tfstates, providerSettings, o)
+	if err != nil {
+		printError("\nError:️ %s\n", err)
+
+		return 1
+	}
+
+	defer closeProviders(providers, upgraders)
 
-	providers, err := provider.InitProviders(tfstate.ProviderNames(), "~/.terradozer", timeoutDuration)
+	accounts, err := checkAccounts(providers, providerSettings, o)
 	if err != nil {
-		fmt.Fprint(os.Stderr, color.RedString("\nError:️ failed to initialize Terraform providers: %s\n", err))
+		printError("\nError:️ %s\n", err)
 
 		return 1
 	}
 
-	defer func() {
-		for _, p := range providers {
-			_ = p.Close()
-		}
-	}()
+	found, err := readResources(tfstates, providers, upgraders, planToApply, o)
+	if err != nil {
+		printError("\nError:️ %s\n", err)
+
+		return 1
+	}
+
+	found.ignored = ignoredResources
 
-	resources, err := tfstate.Resources(providers)
+	return processResources(ctx, command, found, accounts, o)
+}
+
+// readResources returns the resources of the given states with the timeouts to destroy them set
+// (see setDestroyTimeouts). If a plan is given, only the planned resources are returned, as long as
+// the states haven't changed since the plan has been created.
+func readResources(tfstates []*state.State, providers map[*state.State]map[string]*provider.TerraformProvider,
+	upgraders map[*provider.TerraformProvider]*plugin.Upgrader, planToApply *plan.Plan,
+	o options) (*stateResources, error) {
+	deleteTimeouts, err := readDeleteTimeouts(tfstates)
 	if err != nil {
-		fmt.Fprint(os.Stderr, color.RedString("\nError:️ failed to get resources from Terraform state: %s\n", err))
+		return nil, fmt.Errorf("failed to read Terraform state file: %s", err)
+	}
 
+	result := &stateResources{
+		states:  tfstates,
+		byState: map[*state.State][]terraform.UpdatableResource{},
+	}
+
+	if planToApply != nil {
+		result.planned = map[*state.State]plan.State{}
+	}
+
+	for _, tfstate := range tfstates {
+		tfstate.SetUpgraders(stateUpgraders(upgraders))
+
+		resourcesOfState, err := tfstate.Resources(providers[tfstate])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get resources from Terraform state: %s", err)
+		}
+
+		setDestroyTimeouts(resourcesOfState, deleteTimeouts[tfstate], o.typeTimeouts, o.timeoutDuration)
+
+		if planToApply != nil {
+			// only planned resources are considered, as long as their state hasn't changed
+			plannedState, err := planOfState(planToApply, tfstate)
+			if err != nil {
+				return nil, fmt.Errorf("refusing to apply plan: %s", err)
+			}
+
+			result.planned[tfstate] = plannedState
+			resourcesOfState = plannedState.Filter(resourcesOfState, providerConfigOf(tfstate))
+		}
+
+		result.all = append(result.all, resourcesOfState...)
+		result.byState[tfstate] = resourcesOfState
+	}
+
+	return result, nil
+}
+
+// processResources refreshes the given resources to find the ones that still exist and shows them; in plan mode,
+// they are written to a plan instead of being destroyed. Unless in dry run, the resources (or the ones selected
+// in interactive mode) are destroyed afterwards (see destroy). Returns the exit code.
+func processResources(ctx context.Context, command string, found *stateResources, accounts []internal.AWSAccount,
+	o options) int {
+	var err error
+
+	startTime := time.Now()
+
+	internal.SetPhase("refresh")
+
+	resourcesWithUpdatedState := terraform.UpdateResources(found.all, o.parallel)
+
+	if found.planned != nil {
+		resourcesWithUpdatedState, err = selectPlannedResources(found.planned, found.states, found.byState,
+			resourcesWithUpdatedState)
+		if err != nil {
+			printError("\nError:️ refusing to apply plan: %s\n", err)
+
+			return 1
+		}
+	}
+
+	var stateLocations []string
+	for _, tfstate := range found.states {
+		stateLocations = append(stateLocations, tfstate.Location())
+	}
+
+	result := newReport(stateLocations, found.all, resourcesWithUpdatedState, o.dryRun)
+	result.addIgnored(found.ignored)
+
+	if o.output == outputJSON {
+		defer func() {
+			err := result.write(os.Stdout, startTime)
+			if err != nil {
+				printError("Error:️ failed to write JSON output: %s\n", err)
+			}
+		}()
+	}
+
+	if ctx.Err() != nil {
+		internal.LogTitle("interrupted: no resources have been deleted")
 		return 1
 	}
 
-	resourcesWithUpdatedState := terraform.UpdateResources(resources, parallel)
+	destroyableResources := convertToDestroyableResources(resourcesWithUpdatedState)
+
+	protected := o.protection.ProtectedResources(destroyableResources)
+	result.addProtected(destroyableResources, protected)
+
+	numOfResourcesToDelete := len(destroyableResources) - len(protected)
 
-	if !force {
+	internal.SetPhase("plan")
+
+	if !o.force {
 		internal.LogTitle("showing resources that would be deleted (dry run)")
 
 		// always show the resources that would be affected before deleting anything
-		for _, r := range resourcesWithUpdatedState {
-			log.WithField("id", r.ID()).Warn(internal.Pad(r.Type()))
+		for _, r := range destroyableResources {
+			if _, ok := protected[r]; !ok {
+				internal.LogResource(r).Warn(internal.Pad(r.Type()))
+			}
+		}
+
+		logProtectedResources(destroyableResources, protected)
+
+		if command == commandPlan {
+			err := writePlan(o.out, found, resourcesWithUpdatedState, protected)
+			if err != nil {
+				printError("\nError:️ failed to write plan: %s\n", err)
+
+				return 1
+			}
+
+			log.WithFields(log.Fields{
+				"file":      o.out,
+				"resources": numOfResourcesToDelete,
+			}).Info(internal.Pad("saved plan"))
 		}
 
 		if len(resourcesWithUpdatedState) == 0 {
@@ -137,24 +370,210 @@ func mainExitCode() int {
 			return 0
 		}
 
+		if numOfResourcesToDelete == 0 {
+			internal.LogTitle("all resources are protected")
+			return 0
+		}
+
 		internal.LogTitle(fmt.Sprintf("total number of resources that would be deleted: %d",
-			len(resourcesWithUpdatedState)))
+			numOfResourcesToDelete))
 	}
 
-	if !dryRun {
-		if !internal.UserConfirmedDeletion(os.Stdin, force) {
+	if o.interactive {
+		selectedResources, deselectedResources, err := selectResources(ctx, resourcesWithUpdatedState, protected)
+		if err != nil {
+			printError("Error:️ %s\n", err)
+
+			return 1
+		}
+
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr)
+			internal.LogTitle("interrupted: no resources have been deleted")
+
+			return 1
+		}
+
+		result.addSkipped(deselectedResources)
+
+		destroyableResources = convertToDestroyableResources(selectedResources)
+		numOfResourcesToDelete = len(destroyableResources) - len(protected)
+
+		if numOfResourcesToDelete == 0 {
+			internal.LogTitle("no resources selected")
 			return 0
 		}
 
-		internal.LogTitle("Starting to delete resources")
+		internal.LogTitle(fmt.Sprintf("total number of selected resources that would be deleted: %d",
+			numOfResourcesToDelete))
+	}
+
+	if o.dryRun {
+		return 0
+	}
+
+	return destroy(ctx, destroyableResources, internal.Confirmation{
+		Mode:           o.confirmMode,
+		Accounts:       accounts,
+		States:         stateLocations,
+		NumOfResources: numOfResourcesToDelete,
+	}, found, result, o)
+}
+
+// findSources returns the source of the Terraform state file at the given location. In recursive mode,
+// the sources of all state files found under the given location (a directory or S3 prefix) are returned.
+func findSources(location string, recursive bool) ([]state.Source, error) {
+	if !recursive {
+		src, err := state.NewSource(location)
+		if err != nil {
+			return nil, err
+		}
+
+		return []state.Source{src}, nil
+	}
+
+	sources, err := state.DiscoverSources(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no state files found under %s", location)
+	}
+
+	return sources, nil
+}
+
+// lockSources acquires the locks of the state files of the given sources. State files in S3 are locked
+// via the given DynamoDB table.
+//
+// Returns a function to release all acquired locks. On error, all acquired locks have already been released.
+func lockSources(ctx context.Context, sources []state.Source, lockTable string,
+	timeout time.Duration) (func(), error) {
+	var unlockFuncs []func() error
+
+	unlock := func() {
+		for _, unlockFunc := range unlockFuncs {
+			err := unlockFunc()
+			if err != nil {
+				printError("Error:️ %s\n", err)
+			}
+		}
+	}
+
+	for _, src := range sources {
+		if s3Source, ok := src.(*state.S3Source); ok && lockTable != "" {
+			s3Source.SetLockTable(lockTable, nil)
+		}
+
+		unlockFunc, err := state.Lock(ctx, src, timeout)
+		if err != nil {
+			unlock()
 
-		numDeletedResources := resource.DestroyResources(
-			convertToDestroyableResources(resourcesWithUpdatedState), parallel)
+			return nil, err
+		}
 
-		internal.LogTitle(fmt.Sprintf("total number of deleted resources: %d", numDeletedResources))
+		unlockFuncs = append(unlockFuncs, unlockFunc)
 	}
 
-	return 0
+	return unlock, nil
+}
+
+// readStates reads the Terraform state files of the given sources.
+//
+// Only resources selected by the given filter will be returned by each state.
+func readStates(sources []state.Source, filter state.Filter) ([]*state.State, error) {
+	internal.LogTitle("reading state")
+
+	var result []*state.State
+
+	for _, src := range sources {
+		tfstate, err := state.NewFromSource(src, state.WithFilter(filter))
+		if err != nil {
+			return nil, err
+		}
+
+		log.WithField("file", src.String()).Info(internal.Pad("using state"))
+
+		result = append(result, tfstate)
+	}
+
+	return result, nil
+}
+
+// selectResources lets the user select which of the given resources to delete, except for protected resources,
+// which are always kept (see resource.WithProtection).
+//
+// Returns the selected (including protected) and deselected resources.
+func selectResources(ctx context.Context, resources []terraform.UpdatableResource,
+	protected map[resource.DestroyableResource]string) ([]terraform.UpdatableResource,
+	[]terraform.UpdatableResource, error) {
+	if !internal.IsTerminal(os.Stdin) {
+		return nil, nil, fmt.Errorf("interactive mode requires a terminal")
+	}
+
+	var selectable, selected, deselected []terraform.UpdatableResource
+
+	var items []internal.SelectItem
+
+	for _, r := range resources {
+		if _, ok := protected[r.(resource.DestroyableResource)]; ok {
+			selected = append(selected, r)
+
+			continue
+		}
+
+		item := internal.SelectItem{Type: r.Type(), ID: r.ID()}
+		if addressable, ok := r.(interface{ Address() string }); ok {
+			item.Address = addressable.Address()
+		}
+
+		selectable = append(selectable, r)
+		items = append(items, item)
+	}
+
+	internal.SetPhase("select")
+
+	internal.LogTitle("select resources to delete")
+
+	isSelected := internal.UserSelectedItems(ctx, os.Stdin, os.Stderr, items)
+
+	for i, r := range selectable {
+		if isSelected[i] {
+			selected = append(selected, r)
+		} else {
+			deselected = append(deselected, r)
+		}
+	}
+
+	return selected, deselected, nil
+}
+
+// logProtectedResources lists the given protected resources (in the order of the given resources)
+// with the reason why they are protected.
+func logProtectedResources(resources []resource.DestroyableResource,
+	protected map[resource.DestroyableResource]string) {
+	if len(protected) == 0 {
+		return
+	}
+
+	internal.LogTitle(fmt.Sprintf("protected resources that will not be deleted: %d", len(protected)))
+
+	for _, r := range resources {
+		if reason, ok := protected[r]; ok {
+			internal.LogResource(r).WithField("protected_by", reason).Info(internal.Pad(r.Type()))
+		}
+	}
+}
+
+// logIgnoredResources logs the resources of providers without version, which can't be deleted.
+func logIgnoredResources(resources []*resource.Resource) {
+	internal.LogTitle(fmt.Sprintf("resources of providers without version that will not be deleted: %d",
+		len(resources)))
+
+	for _, r := range resources {
+		internal.LogResource(r).WithField("address", r.Address()).Warn(internal.Pad(r.Type()))
+	}
 }
 
 func convertToDestroyableResources(resources []terraform.UpdatableResource) []resource.DestroyableResource {
@@ -167,6 +586,19 @@ func convertToDestroyableResources(resources []terraform.UpdatableResource) []re
 	return result
 }
 
+// printError prints an error message (formatted like fmt.Printf) to stderr.
+// If logs are written in JSON, the message is logged as an event instead.
+func printError(format string, args ...interface{}) {
+	if internal.IsJSONLogs() {
+		msg := strings.ReplaceAll(fmt.Sprintf(format, args...), "\uFE0F", "")
+		log.Error(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(msg), "Error:")))
+
+		return
+	}
+
+	fmt.Fprint(os.Stderr, color.RedString(format, args...))
+}
+
 func printHelp(fs *flag.FlagSet) {
 	fmt.Fprintf(os.Stderr, "\n"+strings.TrimSpace(help)+"\n")
 	fs.PrintDefaults()
@@ -177,7 +609,12 @@ const help = `
 Terraform destroy using only the state - no *.tf files needed.
 
 USAGE:
-  $ terradozer [flags] <path/to/terraform.tfstate>
+  $ terradozer [flags] <path/to/terraform.tfstate>...
+  $ terradozer [flags] s3://<bucket>/<path/to/terraform.tfstate>
+  $ terradozer -recursive [flags] <path/to/dir/ | s3://<bucket>/<prefix/>>
+  $ terradozer -config terradozer.hcl [flags]
+  $ terradozer plan -out <plan.json> [flags] <path/to/terraform.tfstate>...
+  $ terradozer apply [flags] <plan.json>
 
 FLAGS:
 `
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/terradozer/pkg/resource"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// report is the machine-readable result of a run (see -output json).
+type report struct {
+	DryRun bool `json:"dry_run"`
+	// States are the locations of all state files that have been read.
+	States []string `json:"states"`
+	// Found are all resources found in the states.
+	Found []reportResource `json:"found"`
+	// AlreadyGone are resources found in the states that don't exist anymore.
+	AlreadyGone []reportResource `json:"already_gone"`
+	// Skipped are resources that have been deselected in interactive mode.
+	Skipped []reportResource `json:"skipped"`
+	// Protected are resources that haven't been destroyed, as they are protected.
+	Protected []reportResource `json:"protected"`
+	// Ignored are resources that haven't been destroyed, as no version of their provider is given.
+	// Unlike the resources above, they are not included in Found.
+	Ignored   []reportResource `json:"ignored"`
+	Destroyed []reportResource `json:"destroyed"`
+	Failed    []reportResource `json:"failed"`
+	Summary   reportSummary    `json:"summary"`
+	// DurationSeconds is the duration of the whole run.
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+type reportResource struct {
+	Address string `json:"address,omitempty"`
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	// Attempts is the number of times destroying the resource has been tried.
+	Attempts int `json:"attempts,omitempty"`
+	// DurationSeconds is the time spent in all attempts to destroy the resource.
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	Error           string  `json:"error,omitempty"`
+	// ProtectedBy is the reason why a resource is protected.
+	ProtectedBy string `json:"protected_by,omitempty"`
+}
+
+type reportSummary struct {
+	Found       int `json:"found"`
+	AlreadyGone int `json:"already_gone"`
+	Skipped     int `json:"skipped"`
+	Protected   int `json:"protected"`
+	Ignored     int `json:"ignored"`
+	Destroyed   int `json:"destroyed"`
+	Failed      int `json:"failed"`
+	// Retries is the number of all attempts to destroy resources after the first one.
+	Retries int `json:"retries"`
+}
+
+// newReport creates a report of the resources found in the given states and the ones that still exist.
+func newReport(stateLocations []string, found, existing []terraform.UpdatableResource, dryRun bool) *report {
+	r := &report{
+		DryRun:      dryRun,
+		States:      stateLocations,
+		Found:       []reportResource{},
+		AlreadyGone: []reportResource{},
+		Skipped:     []reportResource{},
+		Protected:   []reportResource{},
+		Ignored:     []reportResource{},
+		Destroyed:   []reportResource{},
+		Failed:      []reportResource{},
+	}
+
+	stillExists := map[terraform.UpdatableResource]bool{}
+	for _, res := range existing {
+		stillExists[res] = true
+	}
+
+	for _, res := range found {
+		r.Found = append(r.Found, newReportResource(res))
+
+		if !stillExists[res] {
+			r.AlreadyGone = append(r.AlreadyGone, newReportResource(res))
+		}
+	}
+
+	r.Summary.Found = len(r.Found)
+	r.Summary.AlreadyGone = len(r.AlreadyGone)
+
+	return r
+}
+
+// addSkipped adds the given resources that have been deselected in interactive mode to the report.
+func (r *report) addSkipped(resources []terraform.UpdatableResource) {
+	for _, res := range resources {
+		r.Skipped = append(r.Skipped, newReportResource(res))
+	}
+
+	r.Summary.Skipped = len(r.Skipped)
+}
+
+// addProtected adds the given protected resources (in the order of the given resources) to the report.
+func (r *report) addProtected(resources []resource.DestroyableResource,
+	protected map[resource.DestroyableResource]string) {
+	for _, res := range resources {
+		reason, ok := protected[res]
+		if !ok {
+			continue
+		}
+
+		entry := newReportResource(res)
+		entry.ProtectedBy = reason
+
+		r.Protected = append(r.Protected, entry)
+	}
+
+	r.Summary.Protected = len(r.Protected)
+}
+
+// addIgnored adds the given resources of providers without version to the report.
+func (r *report) addIgnored(resources []*resource.Resource) {
+	for _, res := range resources {
+		r.Ignored = append(r.Ignored, newReportResource(res))
+	}
+
+	r.Summary.Ignored = len(r.Ignored)
+}
+
+// addDestroyResults adds the outcome of destroying resources to the report.
+// Protected resources are expected to be already added (see addProtected).
+func (r *report) addDestroyResults(destroyReport *resource.DestroyReport) {
+	for _, res := range destroyReport.Results {
+		if res.Protected {
+			continue
+		}
+
+		entry := newReportResource(res.Resource)
+		entry.Attempts = res.Attempts
+		entry.DurationSeconds = res.Duration.Seconds()
+
+		if res.Destroyed {
+			r.Destroyed = append(r.Destroyed, entry)
+			continue
+		}
+
+		if res.Err != nil {
+			entry.Error = res.Err.Error()
+		}
+
+		r.Failed = append(r.Failed, entry)
+	}
+
+	r.Summary.Destroyed = len(r.Destroyed)
+	r.Summary.Failed = len(r.Failed)
+	r.Summary.Retries = destroyReport.NumOfRetries()
+}
+
+// write writes the report as JSON.
+func (r *report) write(w io.Writer, startTime time.Time) error {
+	r.DurationSeconds = time.Since(startTime).Seconds()
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(r)
+}
+
+func newReportResource(r interface {
+	Type() string
+	ID() string
+}) reportResource {
+	result := reportResource{
+		Type: r.Type(),
+		ID:   r.ID(),
+	}
+
+	if addressable, ok := r.(interface{ Address() string }); ok {
+		result.Address = addressable.Address()
+	}
+
+	return result
+}
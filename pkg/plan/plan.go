@@ -0,0 +1,225 @@
+// Package plan records which resources would be destroyed, so that exactly those can be destroyed later on
+// (see terradozer plan and apply).
+package plan
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Version is the version of the plan file format.
+const Version = 1
+
+// Plan is the list of resource instances to be destroyed, grouped by the state they belong to.
+type Plan struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	States    []State   `json:"states"`
+}
+
+// State is a state file as it was when the plan has been created, plus its resources to be destroyed.
+type State struct {
+	// Location is where the state has been read from (e.g., an absolute path or S3 URL).
+	Location  string     `json:"location"`
+	Lineage   string     `json:"lineage"`
+	Serial    uint64     `json:"serial"`
+	Resources []Resource `json:"resources"`
+}
+
+// Resource is a resource instance to be destroyed.
+type Resource struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	ID      string `json:"id"`
+	// Provider is the address of the provider configuration managing the resource (e.g., aws.us_east_1).
+	Provider string `json:"provider"`
+	// StateHash is the hash of the resource's state (see HashState) after it has been refreshed.
+	StateHash string `json:"state_hash"`
+}
+
+// ProviderOf returns the address of the provider configuration managing a resource.
+type ProviderOf func(r terraform.UpdatableResource) string
+
+// New creates an empty plan.
+func New() *Plan {
+	return &Plan{
+		Version:   Version,
+		CreatedAt: time.Now().UTC(),
+		States:    []State{},
+	}
+}
+
+// NewResource creates a resource to be destroyed from a resource with refreshed state.
+func NewResource(r terraform.UpdatableResource, provider string) (Resource, error) {
+	hash, err := HashState(r)
+	if err != nil {
+		return Resource{}, err
+	}
+
+	result := Resource{
+		Type:      r.Type(),
+		ID:        r.ID(),
+		Provider:  provider,
+		StateHash: hash,
+	}
+
+	if addressable, ok := r.(interface{ Address() string }); ok {
+		result.Address = addressable.Address()
+	}
+
+	return result, nil
+}
+
+// Read reads a plan from a file.
+func Read(path string) (*Plan, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Plan
+
+	err = json.Unmarshal(content, &p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plan (%s): %s", path, err)
+	}
+
+	if p.Version != Version {
+		return nil, fmt.Errorf("unsupported plan version (%s): %d", path, p.Version)
+	}
+
+	return &p, nil
+}
+
+// Write writes the plan as JSON to a file.
+func (p *Plan) Write(path string) error {
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(content, '\n'), 0600)
+}
+
+// Locations returns the locations of all states of the plan.
+func (p *Plan) Locations() []string {
+	var result []string
+
+	for _, s := range p.States {
+		result = append(result, s.Location)
+	}
+
+	return result
+}
+
+// State returns the state of the plan read from the given location.
+func (p *Plan) State(location string) (State, bool) {
+	for _, s := range p.States {
+		if s.Location == location {
+			return s, true
+		}
+	}
+
+	return State{}, false
+}
+
+// CheckUnchanged returns an error if the given lineage and serial differ from the ones of the planned state,
+// i.e., the state has been replaced or changed since the plan has been created.
+func (s State) CheckUnchanged(lineage string, serial uint64) error {
+	if lineage != s.Lineage {
+		return fmt.Errorf("state has been replaced since the plan has been created (%s): lineage %s, planned %s",
+			s.Location, lineage, s.Lineage)
+	}
+
+	if serial != s.Serial {
+		return fmt.Errorf("state has changed since the plan has been created (%s): serial %d, planned %d",
+			s.Location, serial, s.Serial)
+	}
+
+	return nil
+}
+
+// Filter returns the given resources that are part of the plan (without comparing their state).
+func (s State) Filter(resources []terraform.UpdatableResource, providerOf ProviderOf) []terraform.UpdatableResource {
+	var result []terraform.UpdatableResource
+
+	for _, r := range resources {
+		if _, ok := s.lookup(r, providerOf(r)); ok {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// Select returns the given resources (with refreshed state) that are part of the plan. Planned resources
+// that are missing (e.g., because they have already been deleted) are skipped.
+//
+// An error is returned if the refreshed state of any planned resource has changed since the plan has been created.
+func (s State) Select(resources []terraform.UpdatableResource,
+	providerOf ProviderOf) ([]terraform.UpdatableResource, error) {
+	var result []terraform.UpdatableResource
+
+	for _, r := range resources {
+		planned, ok := s.lookup(r, providerOf(r))
+		if !ok {
+			continue
+		}
+
+		hash, err := HashState(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if hash != planned.StateHash {
+			return nil, fmt.Errorf("resource has changed since the plan has been created: %s (id=%s)",
+				planned.Address, planned.ID)
+		}
+
+		result = append(result, r)
+	}
+
+	return result, nil
+}
+
+// lookup returns the planned resource with the same address, type, ID, and provider configuration
+// as the given resource.
+func (s State) lookup(r terraform.UpdatableResource, provider string) (Resource, bool) {
+	var address string
+	if addressable, ok := r.(interface{ Address() string }); ok {
+		address = addressable.Address()
+	}
+
+	for _, planned := range s.Resources {
+		if planned.Address == address && planned.Type == r.Type() && planned.ID == r.ID() &&
+			planned.Provider == provider {
+			return planned, true
+		}
+	}
+
+	return Resource{}, false
+}
+
+// HashState returns the SHA-256 hash of the JSON representation of a resource's state.
+func HashState(r terraform.UpdatableResource) (string, error) {
+	state := r.State()
+	if state == nil {
+		return "", fmt.Errorf("resource state is nil: %s (id=%s)", r.Type(), r.ID())
+	}
+
+	content, err := ctyjson.Marshal(*state, state.Type())
+	if err != nil {
+		return "", fmt.Errorf("failed to hash resource state: %s (id=%s): %s", r.Type(), r.ID(), err)
+	}
+
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:]), nil
+}
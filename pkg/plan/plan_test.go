@@ -0,0 +1,169 @@
+package plan_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/terradozer/pkg/plan"
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func newResource(rType, id, address, name string) *resource.Resource {
+	state := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal(id),
+		"name": cty.StringVal(name),
+	})
+
+	r := resource.NewWithState(rType, id, nil, &state)
+	r.SetAddress(address)
+
+	return r
+}
+
+func TestPlan_WriteAndRead(t *testing.T) {
+	vpc := newResource("aws_vpc", "vpc-1", "aws_vpc.test", "test")
+
+	planned, err := plan.NewResource(vpc, "aws")
+	require.NoError(t, err)
+
+	expectedPlan := plan.New()
+	expectedPlan.States = append(expectedPlan.States, plan.State{
+		Location:  "/live/dev/terraform.tfstate",
+		Lineage:   "e5931376-a89f-3e94-a4e0-b3431bf3e524",
+		Serial:    106,
+		Resources: []plan.Resource{planned},
+	})
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+
+	err = expectedPlan.Write(path)
+	require.NoError(t, err)
+
+	actualPlan, err := plan.Read(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, expectedPlan.States, actualPlan.States)
+	assert.True(t, expectedPlan.CreatedAt.Equal(actualPlan.CreatedAt))
+	assert.Equal(t, []string{"/live/dev/terraform.tfstate"}, actualPlan.Locations())
+
+	assert.Equal(t, "aws_vpc.test", planned.Address)
+	assert.Equal(t, "aws_vpc", planned.Type)
+	assert.Equal(t, "vpc-1", planned.ID)
+	assert.Equal(t, "aws", planned.Provider)
+	assert.Len(t, planned.StateHash, 64)
+}
+
+func TestState_CheckUnchanged(t *testing.T) {
+	s := plan.State{Location: "terraform.tfstate", Lineage: "abc", Serial: 3}
+
+	assert.NoError(t, s.CheckUnchanged("abc", 3))
+	assert.EqualError(t, s.CheckUnchanged("abc", 4),
+		"state has changed since the plan has been created (terraform.tfstate): serial 4, planned 3")
+	assert.EqualError(t, s.CheckUnchanged("def", 3),
+		"state has been replaced since the plan has been created (terraform.tfstate): lineage def, planned abc")
+}
+
+func TestState_Select(t *testing.T) {
+	vpc := newResource("aws_vpc", "vpc-1", "aws_vpc.test", "test")
+	subnet := newResource("aws_subnet", "subnet-1", "aws_subnet.test", "test")
+
+	plannedVpc, err := plan.NewResource(vpc, "aws")
+	require.NoError(t, err)
+
+	s := plan.State{Resources: []plan.Resource{plannedVpc}}
+
+	tests := []struct {
+		name              string
+		resources         []terraform.UpdatableResource
+		provider          string
+		expectedResources []terraform.UpdatableResource
+		expectedErrMsg    string
+	}{
+		{
+			name:              "only planned resources",
+			resources:         []terraform.UpdatableResource{vpc, subnet},
+			provider:          "aws",
+			expectedResources: []terraform.UpdatableResource{vpc},
+		},
+		{
+			name:      "planned resource already deleted",
+			resources: []terraform.UpdatableResource{subnet},
+			provider:  "aws",
+		},
+		{
+			name: "planned resource has changed",
+			resources: []terraform.UpdatableResource{
+				newResource("aws_vpc", "vpc-1", "aws_vpc.test", "renamed"),
+			},
+			provider:       "aws",
+			expectedErrMsg: "resource has changed since the plan has been created: aws_vpc.test (id=vpc-1)",
+		},
+		{
+			name: "resource with same ID at different address",
+			resources: []terraform.UpdatableResource{
+				newResource("aws_vpc", "vpc-1", "aws_vpc.other", "test"),
+			},
+			provider: "aws",
+		},
+		{
+			name:      "resource managed by different provider configuration",
+			resources: []terraform.UpdatableResource{vpc},
+			provider:  "aws.us_east_1",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualResources, err := s.Select(tc.resources, func(terraform.UpdatableResource) string {
+				return tc.provider
+			})
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedResources, actualResources)
+		})
+	}
+}
+
+func TestState_Filter(t *testing.T) {
+	vpc := newResource("aws_vpc", "vpc-1", "aws_vpc.test", "test")
+	subnet := newResource("aws_subnet", "subnet-1", "aws_subnet.test", "test")
+
+	plannedVpc, err := plan.NewResource(vpc, "aws")
+	require.NoError(t, err)
+
+	s := plan.State{Resources: []plan.Resource{plannedVpc}}
+
+	tests := []struct {
+		name              string
+		provider          string
+		expectedResources []terraform.UpdatableResource
+	}{
+		{
+			name:              "same provider configuration",
+			provider:          "aws",
+			expectedResources: []terraform.UpdatableResource{vpc},
+		},
+		{
+			name:     "different provider configuration",
+			provider: "aws.us_east_1",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualResources := s.Filter([]terraform.UpdatableResource{vpc, subnet},
+				func(terraform.UpdatableResource) string {
+					return tc.provider
+				})
+
+			assert.Equal(t, tc.expectedResources, actualResources)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclsimple"
+)
+
+// lockFile is the content of a dependency lock file of Terraform (.terraform.lock.hcl).
+type lockFile struct {
+	Providers []struct {
+		Address     string   `hcl:"address,label"`
+		Version     string   `hcl:"version"`
+		Constraints *string  `hcl:"constraints,optional"`
+		Hashes      []string `hcl:"hashes,optional"`
+	} `hcl:"provider,block"`
+}
+
+// ReadLockFile reads the providers and their selected versions from a dependency lock file
+// of Terraform (.terraform.lock.hcl).
+func ReadLockFile(path string) ([]Provider, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lf lockFile
+
+	// the file name is only used to tell the syntax, which is HCL regardless of the actual name
+	err = hclsimple.Decode("terraform.lock.hcl", content, nil, &lf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse dependency lock file (%s): %s", path, err)
+	}
+
+	var result []Provider
+
+	for _, p := range lf.Providers {
+		parts := strings.Split(p.Address, "/")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid provider address in dependency lock file (%s): %s", path, p.Address)
+		}
+
+		result = append(result, Provider{
+			Hostname:  parts[0],
+			Namespace: parts[1],
+			Type:      parts[2],
+			Version:   p.Version,
+		})
+	}
+
+	return result, nil
+}
@@ -0,0 +1,84 @@
+package plugin_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadLockFile(t *testing.T) {
+	tests := []struct {
+		name              string
+		content           string
+		expectedProviders []plugin.Provider
+		expectedErrMsg    string
+	}{
+		{
+			name: "providers with constraints and hashes",
+			content: `
+# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "3.74.0"
+  constraints = "~> 3.0"
+  hashes = [
+    "h1:YNOblHBUf+XTjGTfIIsAMGp4weXB+tmQrMPCrpp4jO4=",
+    "zh:00767509c13c0d1c7ad6af702c6942e6572aa6d529b40a00baacc0e73faafea2",
+  ]
+}
+
+provider "registry.example.com/acme/widgets" {
+  version = "0.1.0"
+}
+`,
+			expectedProviders: []plugin.Provider{
+				plugin.NewProvider("aws", "3.74.0"),
+				{Hostname: "registry.example.com", Namespace: "acme", Type: "widgets", Version: "0.1.0"},
+			},
+		},
+		{
+			name:    "no providers",
+			content: "",
+		},
+		{
+			name: "invalid provider address",
+			content: `
+provider "hashicorp/aws" {
+  version = "3.74.0"
+}
+`,
+			expectedErrMsg: "invalid provider address in dependency lock file (%s): hashicorp/aws",
+		},
+		{
+			name: "provider without version",
+			content: `
+provider "registry.terraform.io/hashicorp/aws" {
+}
+`,
+			expectedErrMsg: "failed to parse dependency lock file (%s): terraform.lock.hcl:2,48-48: " +
+				"Missing required argument; The argument \"version\" is required, but no definition was found.",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".terraform.lock.hcl")
+			writeFile(t, path, tc.content)
+
+			actualProviders, err := plugin.ReadLockFile(path)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, fmt.Sprintf(tc.expectedErrMsg, path))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedProviders, actualProviders)
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package plugin
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/jckuester/terradozer/internal"
+)
+
+// versionResponse is the list of archives of a provider version in a network mirror.
+type versionResponse struct {
+	Archives map[string]struct {
+		URL    string   `json:"url"`
+		Hashes []string `json:"hashes"`
+	} `json:"archives"`
+}
+
+// download downloads the plugin archive of the given provider from the network mirror and extracts it
+// into the install directory. Returns false if the mirror doesn't have the provider.
+func (s Source) download(p Provider, installDir string) (bool, error) {
+	base, err := url.Parse(strings.TrimSuffix(s.MirrorURL, "/") + "/")
+	if err != nil {
+		return false, fmt.Errorf("failed to parse URL of network mirror: %s", err)
+	}
+
+	versionURL, err := base.Parse(p.mirrorPath() + "/" + p.Version + ".json")
+	if err != nil {
+		return false, err
+	}
+
+	var version versionResponse
+
+	found, err := s.getJSON(versionURL.String(), &version)
+	if err != nil || !found {
+		return false, err
+	}
+
+	archive, ok := version.Archives[s.platform()]
+	if !ok {
+		return false, nil
+	}
+
+	archiveURL, err := versionURL.Parse(archive.URL)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse URL of archive: %s", err)
+	}
+
+	log.WithFields(log.Fields{
+		"provider": p.String(),
+		"url":      archiveURL.String(),
+	}).Debug(internal.Pad("download provider plugin from network mirror"))
+
+	err = os.MkdirAll(installDir, 0755)
+	if err != nil {
+		return false, err
+	}
+
+	// not to be mistaken for a plugin binary
+	f, err := ioutil.TempFile(installDir, "."+p.ArchiveName(s.platform())+".*")
+	if err != nil {
+		return false, err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = s.get(archiveURL.String(), f)
+	if err != nil {
+		return false, err
+	}
+
+	err = verifyArchive(f.Name(), archive.Hashes)
+	if err != nil {
+		return false, fmt.Errorf("failed to verify archive (%s): %s", archiveURL, err)
+	}
+
+	return true, extract(f.Name(), installDir, p)
+}
+
+// getJSON decodes the JSON response of a GET request. Returns false if not found.
+func (s Source) getJSON(rawURL string, v interface{}) (bool, error) {
+	resp, err := s.client().Get(rawURL)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected response (%s): %s", rawURL, resp.Status)
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse response (%s): %s", rawURL, err)
+	}
+
+	return true, nil
+}
+
+// get writes the body of the response of a GET request to w.
+func (s Source) get(rawURL string, w io.Writer) error {
+	resp, err := s.client().Get(rawURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response (%s): %s", rawURL, resp.Status)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+
+	return err
+}
+
+// verifyArchive checks that the archive matches any of the given hashes, either of the archive itself (zh:) or
+// of its content (h1:). Hashes of other schemes are ignored. An archive without hashes isn't verified.
+func verifyArchive(path string, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	zh, err := hashFile(path)
+	if err != nil {
+		return err
+	}
+
+	h1, err := hashZip(path)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hashes {
+		if h == zh || h == h1 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("checksum doesn't match any of %s", strings.Join(hashes, ", "))
+}
+
+// hashFile returns the SHA-256 hash of a file in the zh: scheme of Terraform.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return "zh:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashZip returns the hash of the files in a zip archive in the h1: scheme of Terraform
+// (i.e., the one of Go modules).
+func hashZip(path string) (string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open archive: %s", err)
+	}
+	defer r.Close()
+
+	files := map[string]*zip.File{}
+
+	var names []string
+
+	for _, f := range r.File {
+		files[f.Name] = f
+		names = append(names, f.Name)
+	}
+
+	sort.Strings(names)
+
+	summary := sha256.New()
+
+	for _, name := range names {
+		h, err := hashZipFile(files[name])
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(summary, "%x  %s\n", h, name)
+	}
+
+	return "h1:" + base64.StdEncoding.EncodeToString(summary.Sum(nil)), nil
+}
+
+func hashZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, rc)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// extract extracts a plugin archive into the given directory, which needs to contain the
+// plugin binary of the given provider afterwards.
+func extract(path, dir string, p Provider) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %s", err)
+	}
+	defer r.Close()
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range r.File {
+		name := filepath.Base(f.Name)
+
+		// skip license files and the like
+		if f.FileInfo().IsDir() || !strings.HasPrefix(name, "terraform-provider-") {
+			continue
+		}
+
+		err := extractFile(f, filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to extract %s from archive (%s): %s", name, path, err)
+		}
+	}
+
+	if !hasPlugin(dir, p) {
+		return fmt.Errorf("archive doesn't contain plugin binary (%s): %s", path, p.BinaryName())
+	}
+
+	log.WithFields(log.Fields{
+		"provider": p.String(),
+		"archive":  path,
+	}).Debug(internal.Pad("extracted provider plugin"))
+
+	return nil
+}
+
+func extractFile(f *zip.File, path string) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	//nolint:gosec
+	_, err = io.Copy(out, rc)
+
+	return err
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+
+	return err == nil && !info.IsDir()
+}
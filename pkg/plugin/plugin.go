@@ -0,0 +1,325 @@
+// Package plugin looks up provider plugin binaries locally, i.e., in plugin directories (also in the layout
+// of a Terraform filesystem mirror), or downloads them from a Terraform network mirror, so that no
+// provider needs to be downloaded from the public registry (e.g., on machines without internet access).
+package plugin
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/hashicorp/terraform/plugin/discovery"
+	"github.com/jckuester/terradozer/internal"
+	goHomeDir "github.com/mitchellh/go-homedir"
+)
+
+const (
+	// DefaultHostname is the hostname of the registry of providers given by name only (e.g., aws).
+	DefaultHostname = "registry.terraform.io"
+	// DefaultNamespace is the namespace of providers given by name only.
+	DefaultNamespace = "hashicorp"
+)
+
+// Provider is a provider plugin in a specific version.
+type Provider struct {
+	Hostname  string
+	Namespace string
+	Type      string
+	Version   string
+}
+
+// NewProvider returns the provider with the given name (e.g., aws) and version
+// of the default registry and namespace.
+func NewProvider(name, version string) Provider {
+	return Provider{
+		Hostname:  DefaultHostname,
+		Namespace: DefaultNamespace,
+		Type:      name,
+		Version:   version,
+	}
+}
+
+// ParseProvider parses the source address of a provider, i.e., [<hostname>/]<namespace>/<type>
+// (e.g., registry.example.com/acme/widgets), or <type> for a provider of the default registry and namespace.
+// The version of the returned provider is empty.
+func ParseProvider(source string) (Provider, error) {
+	parts := strings.Split(source, "/")
+
+	for _, part := range parts {
+		if part == "" {
+			return Provider{}, fmt.Errorf("invalid provider source address: %s", source)
+		}
+	}
+
+	switch len(parts) {
+	case 1:
+		return NewProvider(parts[0], ""), nil
+	case 2:
+		return Provider{Hostname: DefaultHostname, Namespace: parts[0], Type: parts[1]}, nil
+	case 3:
+		return Provider{Hostname: parts[0], Namespace: parts[1], Type: parts[2]}, nil
+	default:
+		return Provider{}, fmt.Errorf("invalid provider source address: %s", source)
+	}
+}
+
+// Address returns the source address of the provider (e.g., registry.terraform.io/hashicorp/aws).
+func (p Provider) Address() string {
+	return fmt.Sprintf("%s/%s/%s", p.Hostname, p.Namespace, p.Type)
+}
+
+// String returns the source address and version of the provider (e.g., registry.terraform.io/hashicorp/aws v3.42.0).
+func (p Provider) String() string {
+	return fmt.Sprintf("%s v%s", p.Address(), p.Version)
+}
+
+// IsDefault returns true if the provider is of the default registry and namespace, i.e., if it can be installed
+// by provider.Install of awstools-lib.
+func (p Provider) IsDefault() bool {
+	return p.Hostname == DefaultHostname && p.Namespace == DefaultNamespace
+}
+
+// BinaryName returns the file name of the plugin binary (without the suffix of the plugin protocol version).
+func (p Provider) BinaryName() string {
+	return fmt.Sprintf("terraform-provider-%s_v%s", p.Type, p.Version)
+}
+
+// ArchiveName returns the file name of the plugin archive for the given platform (e.g., linux_amd64).
+func (p Provider) ArchiveName(platform string) string {
+	return fmt.Sprintf("terraform-provider-%s_%s_%s.zip", p.Type, p.Version, platform)
+}
+
+// mirrorPath returns the (slash-separated) path of the provider in a filesystem or network mirror.
+func (p Provider) mirrorPath() string {
+	return path.Join(p.Hostname, p.Namespace, p.Type)
+}
+
+// Source is where provider plugins are looked up.
+type Source struct {
+	// Dirs are directories with plugin binaries (directly or in a subdirectory per platform, e.g., linux_amd64),
+	// or with providers in the unpacked or packed layout of a Terraform filesystem mirror.
+	Dirs []string
+	// MirrorURL is the base URL of a Terraform network mirror (optional).
+	MirrorURL string
+	// InstallDir is the directory where plugins are installed (e.g., ~/.terradozer), i.e., where plugins installed
+	// previously are found and plugins of archives from mirrors are extracted to.
+	InstallDir string
+	// Platform is the platform of the plugins to look up (default: the current platform, e.g., linux_amd64).
+	Platform string
+	// Client is the HTTP client to download plugins with (default: http.DefaultClient).
+	Client *http.Client
+}
+
+// IsLocal returns true if plugins are looked up in plugin directories or a network mirror only,
+// i.e., never downloaded from the registry.
+func (s Source) IsLocal() bool {
+	return len(s.Dirs) > 0 || s.MirrorURL != ""
+}
+
+// Find returns the directory with the plugin binary of each of the given providers, which is the
+// install directory to launch the provider from via provider.Install and provider.Launch of awstools-lib.
+//
+// Plugin directories are searched first (in the given order), then the install directory, then the network mirror.
+// If no plugin directory or network mirror is given, the install directory is returned for each provider
+// of the default registry and namespace, i.e., these are downloaded via provider.Install if they haven't been
+// installed yet. All other providers are downloaded from their registry (see downloadFromRegistry)
+// into a directory of their own in the install directory (see registryInstallDir).
+//
+// A MissingError lists all providers that haven't been found.
+func (s Source) Find(providers []Provider) (map[Provider]string, error) {
+	result := map[Provider]string{}
+
+	if !s.IsLocal() {
+		for _, p := range providers {
+			if p.IsDefault() {
+				result[p] = s.InstallDir
+				continue
+			}
+
+			dir, err := s.install(p)
+			if err != nil {
+				return nil, fmt.Errorf("failed to install provider plugin (%s): %s", p, err)
+			}
+
+			result[p] = dir
+		}
+
+		return result, nil
+	}
+
+	var missing []Provider
+
+	for _, p := range providers {
+		dir, err := s.find(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up provider plugin (%s): %s", p, err)
+		}
+
+		if dir == "" {
+			missing = append(missing, p)
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"provider": p.String(),
+			"dir":      dir,
+		}).Debug(internal.Pad("found provider plugin"))
+
+		result[p] = dir
+	}
+
+	if len(missing) > 0 {
+		return nil, &MissingError{
+			Providers: missing,
+			Platform:  s.platform(),
+			Searched:  s.searched(),
+		}
+	}
+
+	return result, nil
+}
+
+// find returns the directory with the plugin binary of the given provider, or an empty string if not found.
+func (s Source) find(p Provider) (string, error) {
+	installDir, err := goHomeDir.Expand(s.InstallDir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, d := range s.Dirs {
+		dir, err := goHomeDir.Expand(d)
+		if err != nil {
+			return "", err
+		}
+
+		for _, candidate := range []string{
+			dir,
+			filepath.Join(dir, s.platform()),
+			filepath.Join(dir, filepath.FromSlash(p.mirrorPath()), p.Version, s.platform()),
+		} {
+			if hasPlugin(candidate, p) {
+				return candidate, nil
+			}
+		}
+
+		archive := filepath.Join(dir, filepath.FromSlash(p.mirrorPath()), p.ArchiveName(s.platform()))
+		if fileExists(archive) {
+			// the archive has been extracted previously
+			if hasPlugin(installDir, p) {
+				return installDir, nil
+			}
+
+			return installDir, extract(archive, installDir, p)
+		}
+	}
+
+	if hasPlugin(installDir, p) {
+		return installDir, nil
+	}
+
+	if s.MirrorURL != "" {
+		found, err := s.download(p, installDir)
+		if err != nil || !found {
+			return "", err
+		}
+
+		return installDir, nil
+	}
+
+	return "", nil
+}
+
+// install returns the directory with the plugin binary of the given provider, which is downloaded from its registry
+// unless it has been installed previously.
+func (s Source) install(p Provider) (string, error) {
+	dir, err := s.registryInstallDir(p)
+	if err != nil {
+		return "", err
+	}
+
+	if hasPlugin(dir, p) {
+		return dir, nil
+	}
+
+	return dir, s.downloadFromRegistry(p, dir)
+}
+
+// registryInstallDir returns the directory in the install directory where the plugin binary of the given provider
+// is installed from its registry, which is in the unpacked layout of a filesystem mirror (e.g.,
+// ~/.terradozer/registry.example.com/acme/widgets/0.1.0/linux_amd64). Otherwise, the plugin would be removed
+// by provider.Install of awstools-lib, which removes all other plugins from the install directory.
+func (s Source) registryInstallDir(p Provider) (string, error) {
+	installDir, err := goHomeDir.Expand(s.InstallDir)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(installDir, filepath.FromSlash(p.mirrorPath()), p.Version, s.platform()), nil
+}
+
+func (s Source) client() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+
+	return http.DefaultClient
+}
+
+func (s Source) platform() string {
+	if s.Platform != "" {
+		return s.Platform
+	}
+
+	return runtime.GOOS + "_" + runtime.GOARCH
+}
+
+// searched returns where plugins are looked up.
+func (s Source) searched() []string {
+	result := append([]string{}, s.Dirs...)
+	result = append(result, s.InstallDir)
+
+	if s.MirrorURL != "" {
+		result = append(result, s.MirrorURL)
+	}
+
+	return result
+}
+
+// hasPlugin returns true if the directory contains the plugin binary of the given provider.
+func hasPlugin(dir string, p Provider) bool {
+	version, err := discovery.VersionStr(p.Version).Parse()
+	if err != nil {
+		return false
+	}
+
+	for meta := range discovery.FindPlugins("provider", []string{dir}).WithName(p.Type) {
+		v, err := meta.Version.Parse()
+		if err == nil && v.Equal(version) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MissingError is returned if the plugin binaries of providers haven't been found.
+type MissingError struct {
+	Providers []Provider
+	Platform  string
+	// Searched is where plugins have been looked up (i.e., directories and URL of the network mirror).
+	Searched []string
+}
+
+func (e *MissingError) Error() string {
+	var missing []string
+	for _, p := range e.Providers {
+		missing = append(missing, fmt.Sprintf("%s (%s for %s)", p, p.BinaryName(), e.Platform))
+	}
+
+	return fmt.Sprintf("provider plugins not found in %s: %s",
+		strings.Join(e.Searched, ", "), strings.Join(missing, ", "))
+}
@@ -0,0 +1,333 @@
+package plugin_test
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const platform = "linux_amd64"
+
+//nolint:gochecknoglobals
+var aws = plugin.NewProvider("aws", "3.42.0")
+
+func TestParseProvider(t *testing.T) {
+	tests := []struct {
+		name             string
+		source           string
+		expectedProvider plugin.Provider
+		expectedErrMsg   string
+	}{
+		{
+			name:             "provider name",
+			source:           "aws",
+			expectedProvider: plugin.NewProvider("aws", ""),
+		},
+		{
+			name:             "namespace and type",
+			source:           "acme/widgets",
+			expectedProvider: plugin.Provider{Hostname: "registry.terraform.io", Namespace: "acme", Type: "widgets"},
+		},
+		{
+			name:             "full source address",
+			source:           "registry.example.com/acme/widgets",
+			expectedProvider: plugin.Provider{Hostname: "registry.example.com", Namespace: "acme", Type: "widgets"},
+		},
+		{
+			name:           "empty part",
+			source:         "registry.example.com//widgets",
+			expectedErrMsg: "invalid provider source address: registry.example.com//widgets",
+		},
+		{
+			name:           "too many parts",
+			source:         "registry.example.com/acme/widgets/thing",
+			expectedErrMsg: "invalid provider source address: registry.example.com/acme/widgets/thing",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualProvider, err := plugin.ParseProvider(tc.source)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedProvider, actualProvider)
+		})
+	}
+}
+
+func TestSource_Find(t *testing.T) {
+	tests := []struct {
+		name string
+		// files are created relative to the plugin directory
+		files          []string
+		archive        string
+		installed      bool
+		expectedDir    string
+		expectedErrMsg string
+	}{
+		{
+			name:        "plugin binary in plugin directory",
+			files:       []string{"terraform-provider-aws_v3.42.0"},
+			expectedDir: ".",
+		},
+		{
+			name:        "plugin binary with protocol version suffix",
+			files:       []string{"terraform-provider-aws_v3.42.0_x5"},
+			expectedDir: ".",
+		},
+		{
+			name:        "plugin binary in platform directory",
+			files:       []string{"linux_amd64/terraform-provider-aws_v3.42.0_x5"},
+			expectedDir: "linux_amd64",
+		},
+		{
+			name: "unpacked layout of filesystem mirror",
+			files: []string{
+				"registry.terraform.io/hashicorp/aws/3.42.0/linux_amd64/terraform-provider-aws_v3.42.0_x5",
+			},
+			expectedDir: "registry.terraform.io/hashicorp/aws/3.42.0/linux_amd64",
+		},
+		{
+			name:        "packed layout of filesystem mirror",
+			archive:     "registry.terraform.io/hashicorp/aws/terraform-provider-aws_3.42.0_linux_amd64.zip",
+			expectedDir: "install",
+		},
+		{
+			name:        "plugin installed previously",
+			installed:   true,
+			expectedDir: "install",
+		},
+		{
+			name: "plugin of other version",
+			files: []string{
+				"terraform-provider-aws_v3.41.0",
+				"registry.terraform.io/hashicorp/aws/3.41.0/linux_amd64/terraform-provider-aws_v3.41.0_x5",
+			},
+			expectedErrMsg: "provider plugins not found in %[1]s, %[1]s/install: " +
+				"registry.terraform.io/hashicorp/aws v3.42.0 (terraform-provider-aws_v3.42.0 for linux_amd64)",
+		},
+		{
+			name: "plugin of other platform",
+			files: []string{
+				"darwin_amd64/terraform-provider-aws_v3.42.0_x5",
+			},
+			expectedErrMsg: "provider plugins not found in %[1]s, %[1]s/install: " +
+				"registry.terraform.io/hashicorp/aws v3.42.0 (terraform-provider-aws_v3.42.0 for linux_amd64)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pluginDir := t.TempDir()
+			installDir := filepath.Join(pluginDir, "install")
+
+			for _, f := range tc.files {
+				writeFile(t, filepath.Join(pluginDir, f), "")
+			}
+
+			if tc.archive != "" {
+				writeArchive(t, filepath.Join(pluginDir, tc.archive), "terraform-provider-aws_v3.42.0_x5")
+			}
+
+			if tc.installed {
+				writeFile(t, filepath.Join(installDir, "terraform-provider-aws_v3.42.0_x5"), "")
+			}
+
+			source := plugin.Source{
+				Dirs:       []string{pluginDir},
+				InstallDir: installDir,
+				Platform:   platform,
+			}
+
+			actualDirs, err := source.Find([]plugin.Provider{aws})
+
+			if tc.expectedErrMsg != "" {
+				require.EqualError(t, err, fmt.Sprintf(tc.expectedErrMsg, pluginDir))
+
+				var missingErr *plugin.MissingError
+				require.ErrorAs(t, err, &missingErr)
+				assert.Equal(t, []plugin.Provider{aws}, missingErr.Providers)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, map[plugin.Provider]string{
+				aws: filepath.Join(pluginDir, tc.expectedDir),
+			}, actualDirs)
+		})
+	}
+}
+
+func TestSource_Find_NotLocal(t *testing.T) {
+	source := plugin.Source{
+		InstallDir: "~/.terradozer",
+	}
+
+	actualDirs, err := source.Find([]plugin.Provider{aws})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[plugin.Provider]string{aws: "~/.terradozer"}, actualDirs)
+}
+
+func TestSource_Find_NetworkMirror(t *testing.T) {
+	tests := []struct {
+		name           string
+		hashes         func(archive string) []string
+		platform       string
+		expectedErrMsg string
+	}{
+		{
+			name: "archive with zh hash",
+			hashes: func(archive string) []string {
+				return []string{"zh:" + sha256File(t, archive)}
+			},
+			platform: platform,
+		},
+		{
+			name: "archive without hashes",
+			hashes: func(archive string) []string {
+				return nil
+			},
+			platform: platform,
+		},
+		{
+			name: "archive with wrong hash",
+			hashes: func(archive string) []string {
+				return []string{"zh:0000"}
+			},
+			platform: platform,
+			expectedErrMsg: "failed to look up provider plugin (registry.terraform.io/hashicorp/aws v3.42.0): " +
+				"failed to verify archive (%[1]s/registry.terraform.io/hashicorp/aws/" +
+				"terraform-provider-aws_3.42.0_linux_amd64.zip): checksum doesn't match any of zh:0000",
+		},
+		{
+			name: "archive of other platform",
+			hashes: func(archive string) []string {
+				return nil
+			},
+			platform: "darwin_amd64",
+			expectedErrMsg: "provider plugins not found in %[2]s, %[1]s: " +
+				"registry.terraform.io/hashicorp/aws v3.42.0 (terraform-provider-aws_v3.42.0 for linux_amd64)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "aws.zip")
+			writeArchive(t, archive, "terraform-provider-aws_v3.42.0_x5")
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/registry.terraform.io/hashicorp/aws/3.42.0.json", func(w http.ResponseWriter,
+				r *http.Request) {
+				fmt.Fprintf(w, `{"archives": {%q: {"url": %q, "hashes": %q}}}`, tc.platform,
+					"terraform-provider-aws_3.42.0_"+tc.platform+".zip", tc.hashes(archive))
+			})
+			mux.HandleFunc("/registry.terraform.io/hashicorp/aws/terraform-provider-aws_3.42.0_"+tc.platform+".zip",
+				func(w http.ResponseWriter, r *http.Request) {
+					http.ServeFile(w, r, archive)
+				})
+
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			installDir := t.TempDir()
+
+			source := plugin.Source{
+				MirrorURL:  server.URL,
+				InstallDir: installDir,
+				Platform:   platform,
+			}
+
+			actualDirs, err := source.Find([]plugin.Provider{aws})
+
+			if tc.expectedErrMsg != "" {
+				require.EqualError(t, err, fmt.Sprintf(tc.expectedErrMsg, server.URL, installDir))
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, map[plugin.Provider]string{aws: installDir}, actualDirs)
+
+			assert.FileExists(t, filepath.Join(installDir, "terraform-provider-aws_v3.42.0_x5"))
+			assert.NoFileExists(t, filepath.Join(installDir, "CHANGELOG.md"))
+		})
+	}
+}
+
+func TestSource_Find_NetworkMirror_NotFound(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	installDir := t.TempDir()
+
+	source := plugin.Source{
+		MirrorURL:  server.URL,
+		InstallDir: installDir,
+		Platform:   platform,
+	}
+
+	_, err := source.Find([]plugin.Provider{aws, plugin.NewProvider("random", "3.1.0")})
+
+	assert.EqualError(t, err, fmt.Sprintf("provider plugins not found in %s, %s: "+
+		"registry.terraform.io/hashicorp/aws v3.42.0 (terraform-provider-aws_v3.42.0 for linux_amd64), "+
+		"registry.terraform.io/hashicorp/random v3.1.0 (terraform-provider-random_v3.1.0 for linux_amd64)",
+		installDir, server.URL))
+}
+
+func writeFile(t *testing.T, path, content string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(path, []byte(content), 0755)
+	require.NoError(t, err)
+}
+
+// writeArchive writes a plugin archive with the given binary and a license file.
+func writeArchive(t *testing.T, path, binaryName string) {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	require.NoError(t, err)
+
+	f, err := os.Create(path)
+	require.NoError(t, err)
+
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+
+	for _, name := range []string{"CHANGELOG.md", binaryName} {
+		fw, err := w.Create(name)
+		require.NoError(t, err)
+
+		_, err = fw.Write([]byte(name))
+		require.NoError(t, err)
+	}
+
+	err = w.Close()
+	require.NoError(t, err)
+}
+
+func sha256File(t *testing.T, path string) string {
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	hash := sha256.Sum256(content)
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,193 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/hashicorp/terraform/registry/response"
+	"github.com/jckuester/terradozer/internal"
+	"golang.org/x/crypto/openpgp"
+)
+
+// providersServiceID is the ID of the service of the provider registry protocol,
+// which is looked up via the service discovery protocol of Terraform.
+const providersServiceID = "providers.v1"
+
+// downloadFromRegistry downloads the plugin archive of the given provider from the registry of its hostname
+// (via the provider registry protocol of Terraform) and extracts it into the given directory.
+//
+// The archive is verified by its checksum, which is listed in a file signed by one of the signing keys
+// of the provider as returned by the registry.
+func (s Source) downloadFromRegistry(p Provider, dir string) error {
+	serviceURL, err := s.discoverProviders(p.Hostname)
+	if err != nil {
+		return err
+	}
+
+	osName, arch, err := splitPlatform(s.platform())
+	if err != nil {
+		return err
+	}
+
+	locationURL, err := serviceURL.Parse(path.Join(p.Namespace, p.Type, p.Version, "download", osName, arch))
+	if err != nil {
+		return err
+	}
+
+	var location response.TerraformProviderPlatformLocation
+
+	found, err := s.getJSON(locationURL.String(), &location)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		return fmt.Errorf("registry has no plugin for %s", s.platform())
+	}
+
+	shasums, err := s.verifiedShasums(locationURL, &location)
+	if err != nil {
+		return err
+	}
+
+	if checksumForFile(shasums, location.Filename) != location.Shasum {
+		return fmt.Errorf("checksum of %s doesn't match signed checksums", location.Filename)
+	}
+
+	archiveURL, err := locationURL.Parse(location.DownloadURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse URL of archive: %s", err)
+	}
+
+	log.WithFields(log.Fields{
+		"provider": p.String(),
+		"url":      archiveURL.String(),
+	}).Debug(internal.Pad("download provider plugin from registry"))
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	// not to be mistaken for a plugin binary
+	f, err := ioutil.TempFile(dir, "."+location.Filename+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	err = s.get(archiveURL.String(), f)
+	if err != nil {
+		return err
+	}
+
+	err = verifyArchive(f.Name(), []string{"zh:" + location.Shasum})
+	if err != nil {
+		return fmt.Errorf("failed to verify archive (%s): %s", archiveURL, err)
+	}
+
+	return extract(f.Name(), dir, p)
+}
+
+// discoverProviders returns the base URL of the provider registry of the given hostname.
+func (s Source) discoverProviders(hostname string) (*url.URL, error) {
+	discoveryURL := &url.URL{Scheme: "https", Host: hostname, Path: "/.well-known/terraform.json"}
+
+	var services map[string]interface{}
+
+	found, err := s.getJSON(discoveryURL.String(), &services)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover services of %s: %s", hostname, err)
+	}
+
+	rawURL, ok := services[providersServiceID].(string)
+	if !found || !ok {
+		return nil, fmt.Errorf("host doesn't provide a provider registry: %s", hostname)
+	}
+
+	result, err := discoveryURL.Parse(strings.TrimSuffix(rawURL, "/") + "/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL of provider registry (%s): %s", hostname, err)
+	}
+
+	return result, nil
+}
+
+// verifiedShasums downloads the checksums of the archives of a provider version and verifies their signature.
+func (s Source) verifiedShasums(base *url.URL,
+	location *response.TerraformProviderPlatformLocation) ([]byte, error) {
+	shasums, err := s.getFile(base, location.ShasumsURL)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := s.getFile(base, location.ShasumsSignatureURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range location.SigningKeys.GPGKeys {
+		_, err := verifySig(shasums, signature, key.ASCIIArmor)
+		if err == nil {
+			return shasums, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to verify signature of checksums (%s)", location.ShasumsURL)
+}
+
+func (s Source) getFile(base *url.URL, rawURL string) ([]byte, error) {
+	u, err := base.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	err = s.get(u.String(), &buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// splitPlatform splits a platform (e.g., linux_amd64) into operating system and architecture.
+func splitPlatform(platform string) (string, string, error) {
+	parts := strings.SplitN(platform, "_", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid platform: %s", platform)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// copied from github.com/hashicorp/terraform/plugin/discovery
+func verifySig(data, sig []byte, armor string) (*openpgp.Entity, error) {
+	el, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armor))
+	if err != nil {
+		return nil, err
+	}
+
+	return openpgp.CheckDetachedSignature(el, bytes.NewReader(data), bytes.NewReader(sig))
+}
+
+// copied from github.com/hashicorp/terraform/plugin/discovery
+func checksumForFile(sums []byte, name string) string {
+	for _, line := range strings.Split(string(sums), "\n") {
+		parts := strings.Fields(line)
+		if len(parts) > 1 && parts[1] == name {
+			return parts[0]
+		}
+	}
+
+	return ""
+}
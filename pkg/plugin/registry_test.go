@@ -0,0 +1,190 @@
+package plugin_test
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/plugin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestSource_Find_Registry(t *testing.T) {
+	signingKey := newSigningKey(t)
+	otherKey := newSigningKey(t)
+
+	tests := []struct {
+		name string
+		// shasums returns the content of the checksums file for the given SHA-256 hash of the archive
+		shasums        func(hash string) string
+		signedBy       *openpgp.Entity
+		platform       string
+		expectedErrMsg string
+	}{
+		{
+			name: "signed checksums",
+			shasums: func(hash string) string {
+				return hash + "  terraform-provider-widgets_0.1.0_linux_amd64.zip\n"
+			},
+			signedBy: signingKey,
+			platform: platform,
+		},
+		{
+			name: "checksums signed by other key",
+			shasums: func(hash string) string {
+				return hash + "  terraform-provider-widgets_0.1.0_linux_amd64.zip\n"
+			},
+			signedBy: otherKey,
+			platform: platform,
+			expectedErrMsg: "failed to install provider plugin (%s/acme/widgets v0.1.0): " +
+				"failed to verify signature of checksums (/files/terraform-provider-widgets_0.1.0_SHA256SUMS)",
+		},
+		{
+			name: "archive not in checksums",
+			shasums: func(hash string) string {
+				return hash + "  terraform-provider-widgets_0.1.0_darwin_amd64.zip\n"
+			},
+			signedBy: signingKey,
+			platform: platform,
+			expectedErrMsg: "failed to install provider plugin (%s/acme/widgets v0.1.0): " +
+				"checksum of terraform-provider-widgets_0.1.0_linux_amd64.zip doesn't match signed checksums",
+		},
+		{
+			name: "archive of other platform",
+			shasums: func(hash string) string {
+				return ""
+			},
+			signedBy: signingKey,
+			platform: "darwin_amd64",
+			expectedErrMsg: "failed to install provider plugin (%s/acme/widgets v0.1.0): " +
+				"registry has no plugin for linux_amd64",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			archive := filepath.Join(t.TempDir(), "widgets.zip")
+			writeArchive(t, archive, "terraform-provider-widgets_v0.1.0_x5")
+
+			hash := sha256File(t, archive)
+			shasums := tc.shasums(hash)
+
+			var signature bytes.Buffer
+			err := openpgp.DetachSign(&signature, tc.signedBy, strings.NewReader(shasums), nil)
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/.well-known/terraform.json", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"providers.v1": "/v1/providers/"}`)
+			})
+			mux.HandleFunc("/v1/providers/acme/widgets/0.1.0/download/"+strings.Replace(tc.platform, "_", "/", 1),
+				func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprintf(w, `{
+  "filename": "terraform-provider-widgets_0.1.0_linux_amd64.zip",
+  "download_url": "/files/terraform-provider-widgets_0.1.0_linux_amd64.zip",
+  "shasums_url": "/files/terraform-provider-widgets_0.1.0_SHA256SUMS",
+  "shasums_signature_url": "/files/terraform-provider-widgets_0.1.0_SHA256SUMS.sig",
+  "shasum": %q,
+  "signing_keys": {"gpg_public_keys": [{"ascii_armor": %q}]}
+}`, hash, armoredPublicKey(t, signingKey))
+				})
+			mux.HandleFunc("/files/terraform-provider-widgets_0.1.0_linux_amd64.zip",
+				func(w http.ResponseWriter, r *http.Request) {
+					http.ServeFile(w, r, archive)
+				})
+			mux.HandleFunc("/files/terraform-provider-widgets_0.1.0_SHA256SUMS",
+				func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, shasums)
+				})
+			mux.HandleFunc("/files/terraform-provider-widgets_0.1.0_SHA256SUMS.sig",
+				func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write(signature.Bytes())
+				})
+
+			server := httptest.NewTLSServer(mux)
+			defer server.Close()
+
+			host := server.Listener.Addr().String()
+			widgets := plugin.Provider{Hostname: host, Namespace: "acme", Type: "widgets", Version: "0.1.0"}
+
+			installDir := t.TempDir()
+
+			source := plugin.Source{
+				InstallDir: installDir,
+				Platform:   platform,
+				Client:     server.Client(),
+			}
+
+			actualDirs, err := source.Find([]plugin.Provider{widgets})
+
+			if tc.expectedErrMsg != "" {
+				require.EqualError(t, err, fmt.Sprintf(tc.expectedErrMsg, host))
+				return
+			}
+
+			require.NoError(t, err)
+
+			expectedDir := filepath.Join(installDir, host, "acme", "widgets", "0.1.0", platform)
+			assert.Equal(t, map[plugin.Provider]string{widgets: expectedDir}, actualDirs)
+
+			assert.FileExists(t, filepath.Join(expectedDir, "terraform-provider-widgets_v0.1.0_x5"))
+			assert.NoFileExists(t, filepath.Join(expectedDir, "CHANGELOG.md"))
+
+			// installed previously
+			server.Close()
+
+			actualDirs, err = source.Find([]plugin.Provider{widgets})
+			require.NoError(t, err)
+			assert.Equal(t, map[plugin.Provider]string{widgets: expectedDir}, actualDirs)
+		})
+	}
+}
+
+func TestSource_Find_Registry_NoProviderRegistry(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	host := server.Listener.Addr().String()
+
+	source := plugin.Source{
+		InstallDir: t.TempDir(),
+		Platform:   platform,
+		Client:     server.Client(),
+	}
+
+	_, err := source.Find([]plugin.Provider{
+		{Hostname: host, Namespace: "acme", Type: "widgets", Version: "0.1.0"},
+	})
+
+	assert.EqualError(t, err, fmt.Sprintf("failed to install provider plugin (%[1]s/acme/widgets v0.1.0): "+
+		"host doesn't provide a provider registry: %[1]s", host))
+}
+
+func newSigningKey(t *testing.T) *openpgp.Entity {
+	entity, err := openpgp.NewEntity("terradozer", "test", "test@example.com", nil)
+	require.NoError(t, err)
+
+	return entity
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	var buf bytes.Buffer
+
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+
+	err = entity.Serialize(w)
+	require.NoError(t, err)
+
+	err = w.Close()
+	require.NoError(t, err)
+
+	return buf.String()
+}
@@ -0,0 +1,96 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/hashicorp/go-hclog"
+	goPlugin "github.com/hashicorp/go-plugin"
+	"github.com/hashicorp/terraform/plugin"
+	"github.com/hashicorp/terraform/providers"
+)
+
+// Upgrader upgrades the states of resources written by older versions of a provider to the schema of the
+// given provider plugin binary. The plugin is launched on first use, separately from the one launched via
+// provider.Launch of awstools-lib, which doesn't support upgrading resource states.
+type Upgrader struct {
+	path string
+
+	mu       sync.Mutex
+	provider providers.Interface
+}
+
+// NewUpgrader creates an upgrader of resource states for the given plugin binary.
+func NewUpgrader(path string) *Upgrader {
+	return &Upgrader{path: path}
+}
+
+// UpgradeResourceState upgrades the state of a resource to the schema of the provider plugin.
+func (u *Upgrader) UpgradeResourceState(
+	req providers.UpgradeResourceStateRequest) providers.UpgradeResourceStateResponse {
+	p, err := u.launch()
+	if err != nil {
+		var resp providers.UpgradeResourceStateResponse
+		resp.Diagnostics = resp.Diagnostics.Append(fmt.Errorf("failed to launch provider (%s): %s", u.path, err))
+
+		return resp
+	}
+
+	return p.UpgradeResourceState(req)
+}
+
+// Close shuts down the plugin process, if it has been launched.
+func (u *Upgrader) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.provider == nil {
+		return nil
+	}
+
+	return u.provider.Close()
+}
+
+// copied (and modified) from providerFactory of github.com/jckuester/awstools-lib/terraform/provider
+func (u *Upgrader) launch() (providers.Interface, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.provider != nil {
+		return u.provider, nil
+	}
+
+	client := goPlugin.NewClient(&goPlugin.ClientConfig{
+		Cmd:              exec.Command(u.path), //nolint:gosec
+		HandshakeConfig:  plugin.Handshake,
+		VersionedPlugins: plugin.VersionedPlugins,
+		Managed:          true,
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "plugin",
+			Level:  hclog.Error,
+			Output: os.Stderr,
+		}),
+		AllowedProtocols: []goPlugin.Protocol{goPlugin.ProtocolGRPC},
+		AutoMTLS:         true,
+	})
+
+	rpcClient, err := client.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := rpcClient.Dispense(plugin.ProviderPluginName)
+	if err != nil {
+		return nil, err
+	}
+
+	// store the client so that the plugin process is killed on close
+	p := raw.(*plugin.GRPCProvider)
+	p.PluginClient = client
+
+	u.provider = p
+
+	return p, nil
+}
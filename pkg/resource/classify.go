@@ -0,0 +1,113 @@
+package resource
+
+import (
+	"strings"
+)
+
+// ErrorClass is the kind of an error returned by a provider when destroying a resource.
+type ErrorClass int
+
+const (
+	// ErrorRetryable is an error that may go away by retrying (e.g., a resource is still in use by another one).
+	ErrorRetryable ErrorClass = iota
+	// ErrorAlreadyGone is an error saying that the resource doesn't exist (anymore).
+	ErrorAlreadyGone
+	// ErrorPermanent is an error that won't go away by retrying (e.g., missing permissions).
+	ErrorPermanent
+)
+
+func (c ErrorClass) String() string {
+	switch c {
+	case ErrorAlreadyGone:
+		return "already gone"
+	case ErrorPermanent:
+		return "permanent"
+	default:
+		return "retryable"
+	}
+}
+
+// errorPattern classifies provider errors whose message contains the pattern.
+type errorPattern struct {
+	pattern string
+	class   ErrorClass
+}
+
+//nolint:gochecknoglobals
+var (
+	// errorPatterns are the patterns to classify errors by provider name. Patterns are matched in order,
+	// so permanent errors are checked before errors of resources that are already gone;
+	// errors not matching any pattern are retryable.
+	errorPatterns = map[string][]errorPattern{
+		"aws": {
+			{pattern: "AccessDenied", class: ErrorPermanent},
+			{pattern: "UnauthorizedOperation", class: ErrorPermanent},
+			{pattern: "AuthFailure", class: ErrorPermanent},
+			{pattern: "InvalidClientTokenId", class: ErrorPermanent},
+			{pattern: "UnrecognizedClientException", class: ErrorPermanent},
+			{pattern: "ExpiredToken", class: ErrorPermanent},
+			{pattern: "OptInRequired", class: ErrorPermanent},
+			// ValidationError is also returned for conflicts that go away by retrying (e.g., a stack or
+			// Auto Scaling group that is still being updated), so only malformed requests and protected
+			// resources are permanent
+			{pattern: "validation error detected", class: ErrorPermanent},
+			{pattern: "validation errors detected", class: ErrorPermanent},
+			{pattern: "TerminationProtection is enabled", class: ErrorPermanent},
+			{pattern: "ValidationException", class: ErrorPermanent},
+			{pattern: "InvalidParameterCombination", class: ErrorPermanent},
+			// the shared config profile of the AWS SDK doesn't exist (not the resource)
+			{pattern: "ProfileNotFound", class: ErrorPermanent},
+
+			// error codes of resources that don't exist; only matched as codes (e.g., InvalidVpcID.NotFound,
+			// ResourceNotFoundException), not when "NotFound" is part of some other message
+			{pattern: ".NotFound", class: ErrorAlreadyGone},
+			{pattern: "NotFound:", class: ErrorAlreadyGone},
+			{pattern: "NotFoundException", class: ErrorAlreadyGone},
+			{pattern: "NotFoundFault", class: ErrorAlreadyGone},
+			{pattern: "NoSuchEntity", class: ErrorAlreadyGone},
+			{pattern: "NoSuchBucket", class: ErrorAlreadyGone},
+			{pattern: "NoSuchHostedZone", class: ErrorAlreadyGone},
+			{pattern: "AutoScalingGroup name not found", class: ErrorAlreadyGone},
+
+			{pattern: "DependencyViolation", class: ErrorRetryable},
+			{pattern: "DeleteConflict", class: ErrorRetryable},
+			{pattern: "ResourceInUse", class: ErrorRetryable},
+			{pattern: "InvalidIPAddress.InUse", class: ErrorRetryable},
+			{pattern: "ConcurrentModification", class: ErrorRetryable},
+			{pattern: "OperationAborted", class: ErrorRetryable},
+			{pattern: "Throttling", class: ErrorRetryable},
+			{pattern: "RequestLimitExceeded", class: ErrorRetryable},
+		},
+	}
+)
+
+// ClassifyError returns the class of an error returned by the provider of the given resource type
+// (e.g., aws_instance) when destroying a resource.
+func ClassifyError(resourceType string, err error) ErrorClass {
+	providerName := strings.SplitN(resourceType, "_", 2)[0]
+
+	for _, p := range errorPatterns[providerName] {
+		if strings.Contains(err.Error(), p.pattern) {
+			return p.class
+		}
+	}
+
+	return ErrorRetryable
+}
+
+// NewDestroyError wraps an error returned by the provider when destroying the given resource into
+// a RetryDestroyError, AlreadyGoneError, or PermanentDestroyError (see ClassifyError).
+func NewDestroyError(err error, r DestroyableResource) error {
+	if err == nil {
+		return nil
+	}
+
+	switch ClassifyError(r.Type(), err) {
+	case ErrorAlreadyGone:
+		return NewAlreadyGoneError(err, r)
+	case ErrorPermanent:
+		return NewPermanentDestroyError(err, r)
+	default:
+		return NewRetryDestroyError(err, r)
+	}
+}
@@ -0,0 +1,130 @@
+package resource_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name          string
+		resourceType  string
+		err           error
+		expectedClass resource.ErrorClass
+	}{
+		{
+			name:          "dependency violation",
+			resourceType:  "aws_security_group",
+			err:           fmt.Errorf("DependencyViolation: resource sg-1234 has a dependent object"),
+			expectedClass: resource.ErrorRetryable,
+		},
+		{
+			name:          "not found",
+			resourceType:  "aws_vpc",
+			err:           fmt.Errorf("InvalidVpcID.NotFound: The vpc ID 'vpc-1234' does not exist"),
+			expectedClass: resource.ErrorAlreadyGone,
+		},
+		{
+			name:          "not found exception",
+			resourceType:  "aws_dynamodb_table",
+			err:           fmt.Errorf("ResourceNotFoundException: Requested resource not found: Table: test not found"),
+			expectedClass: resource.ErrorAlreadyGone,
+		},
+		{
+			name:          "not found as code without prefix",
+			resourceType:  "aws_s3_bucket_object",
+			err:           fmt.Errorf("error deleting S3 Bucket Object (test): NotFound: Not Found"),
+			expectedClass: resource.ErrorAlreadyGone,
+		},
+		{
+			name:         "not found in message of other error",
+			resourceType: "aws_security_group",
+			err: fmt.Errorf("error deleting security group (sg-1234): InvalidParameterValue: " +
+				"referenced group sg-5678 NotFound"),
+			expectedClass: resource.ErrorRetryable,
+		},
+		{
+			name:          "not found for missing dependency",
+			resourceType:  "aws_subnet",
+			err:           fmt.Errorf("error waiting for dependency aws_network_interface.eni-1234 (NotFound in state)"),
+			expectedClass: resource.ErrorRetryable,
+		},
+		{
+			name:          "profile not found",
+			resourceType:  "aws_instance",
+			err:           fmt.Errorf("ProfileNotFound: failed to get profile, test"),
+			expectedClass: resource.ErrorPermanent,
+		},
+		{
+			name:          "not found in message of permanent error",
+			resourceType:  "aws_iam_role",
+			err:           fmt.Errorf("AccessDenied: not authorized to call iam:DeleteRole (ResourceNotFoundException)"),
+			expectedClass: resource.ErrorPermanent,
+		},
+		{
+			name:          "no such entity",
+			resourceType:  "aws_iam_role",
+			err:           fmt.Errorf("NoSuchEntity: The role with name test cannot be found."),
+			expectedClass: resource.ErrorAlreadyGone,
+		},
+		{
+			name:          "access denied",
+			resourceType:  "aws_s3_bucket",
+			err:           fmt.Errorf("error deleting S3 Bucket (test): AccessDenied: Access Denied"),
+			expectedClass: resource.ErrorPermanent,
+		},
+		{
+			name:          "unauthorized operation",
+			resourceType:  "aws_instance",
+			err:           fmt.Errorf("UnauthorizedOperation: You are not authorized to perform this operation."),
+			expectedClass: resource.ErrorPermanent,
+		},
+		{
+			name:         "malformed request",
+			resourceType: "aws_autoscaling_group",
+			err: fmt.Errorf("ValidationError: 1 validation error detected: Value 'test!' at " +
+				"'autoScalingGroupName' failed to satisfy constraint"),
+			expectedClass: resource.ErrorPermanent,
+		},
+		{
+			name:         "termination protection",
+			resourceType: "aws_cloudformation_stack",
+			err: fmt.Errorf("ValidationError: Stack [test] cannot be deleted while TerminationProtection " +
+				"is enabled"),
+			expectedClass: resource.ErrorPermanent,
+		},
+		{
+			name:         "validation error of dependency conflict",
+			resourceType: "aws_autoscaling_group",
+			err: fmt.Errorf("ValidationError: AutoScalingGroup test is pending delete, or has scaling " +
+				"activities in progress"),
+			expectedClass: resource.ErrorRetryable,
+		},
+		{
+			name:          "validation error of resource already gone",
+			resourceType:  "aws_autoscaling_group",
+			err:           fmt.Errorf("ValidationError: AutoScalingGroup name not found - AutoScalingGroup test not found"),
+			expectedClass: resource.ErrorAlreadyGone,
+		},
+		{
+			name:          "unknown error",
+			resourceType:  "aws_instance",
+			err:           fmt.Errorf("some error"),
+			expectedClass: resource.ErrorRetryable,
+		},
+		{
+			name:          "provider without patterns",
+			resourceType:  "google_compute_instance",
+			err:           fmt.Errorf("AccessDenied"),
+			expectedClass: resource.ErrorRetryable,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedClass, resource.ClassifyError(tc.resourceType, tc.err))
+		})
+	}
+}
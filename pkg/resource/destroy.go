@@ -1,10 +1,16 @@
 package resource
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/apex/log"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/jckuester/awstools-lib/terraform/provider"
 	"github.com/jckuester/terradozer/internal"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // DestroyableResource implementations can destroy a Terraform resource.
@@ -14,23 +20,213 @@ type DestroyableResource interface {
 	ID() string
 }
 
+// DependentResource implementations are destroyable resources that know which other resources they depend on.
+type DependentResource interface {
+	DestroyableResource
+	Dependencies() ([]DestroyableResource, bool)
+}
+
+// DestroyOption configures DestroyResources.
+type DestroyOption func(*destroyOptions)
+
+type destroyOptions struct {
+	protection  Protection
+	retryPolicy RetryPolicy
+}
+
+// WithProtection prevents resources selected by the given protection from being destroyed.
+func WithProtection(p Protection) DestroyOption {
+	return func(o *destroyOptions) {
+		o.protection = p
+	}
+}
+
 // DestroyResources destroys a given list of resources, which may depend on each other.
 //
+// Resources that know which other resources they depend on (see DependentResource) are destroyed
+// in reverse topological order of their dependencies first, i.e., a resource is only destroyed
+// once all resources depending on it are gone.
+//
+// All other resources (plus the ones that couldn't be destroyed in order) are destroyed by trial and error:
+// if at least one resource is successfully destroyed per run (iteration through the list of given resources),
+// the remaining, failed resources will be retried in a next run (until all resources are destroyed or
+// some destroys have permanently failed). Given a retry policy via WithRetryPolicy, each of these resources
+// is retried on its own with exponential backoff instead.
+//
+// Once the given context is cancelled, no further destroys are started, but the ones in progress
+// are waited for to finish. Resources that haven't been tried to destroy are reported as failed
+// with the error of the context.
+//
+// Resources protected by WithProtection are never tried to destroy.
+//
+// The returned report contains the outcome of destroying each of the given resources.
+func DestroyResources(ctx context.Context, resources []DestroyableResource, parallel int,
+	opts ...DestroyOption) *DestroyReport {
+	startTime := time.Now()
+
+	var options destroyOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	report := newDestroyReport(resources)
+
+	protected := options.protection.ProtectedResources(resources)
+	report.protect(protected)
+
+	var resourcesInOrder []DependentResource
+
+	var resourcesToRetry []DestroyableResource
+
+	for _, r := range resources {
+		if reason, ok := protected[r]; ok {
+			log.WithFields(log.Fields{
+				"id":     r.ID(),
+				"reason": reason,
+			}).Debug(internal.Pad("not deleting protected resource"))
+
+			continue
+		}
+
+		if dr, ok := r.(DependentResource); ok {
+			if _, hasDependencyInfo := dr.Dependencies(); hasDependencyInfo {
+				resourcesInOrder = append(resourcesInOrder, dr)
+
+				continue
+			}
+		}
+
+		resourcesToRetry = append(resourcesToRetry, r)
+	}
+
+	if len(resourcesInOrder) > 0 {
+		remainingResources := destroyInDependencyOrder(ctx, resourcesInOrder, parallel, report)
+
+		resourcesToRetry = append(resourcesToRetry, remainingResources...)
+	}
+
+	if len(resourcesToRetry) > 0 && ctx.Err() == nil {
+		if options.retryPolicy.IsEmpty() {
+			destroyWithRetries(ctx, resourcesToRetry, parallel, report)
+		} else {
+			destroyWithBackoff(ctx, resourcesToRetry, parallel, options.retryPolicy, report)
+		}
+	}
+
+	report.cancel(ctx.Err())
+	report.Duration = time.Since(startTime)
+
+	return report
+}
+
+// destroyInDependencyOrder destroys the given resources in reverse topological order of their dependencies.
+// A resource is scheduled for destroy once all resources (of the given list) that depend on it have been destroyed.
+//
+// Returns the resources that remain, either because their destroy failed or because a resource
+// depending on them couldn't be destroyed (e.g., due to a dependency cycle).
+func destroyInDependencyOrder(ctx context.Context, resources []DependentResource, parallel int,
+	report *DestroyReport) []DestroyableResource {
+	isToBeDeleted := map[DestroyableResource]bool{}
+
+	for _, r := range resources {
+		isToBeDeleted[r] = true
+	}
+
+	// numOfDependents is the number of not yet destroyed resources that depend on a resource
+	numOfDependents := map[DestroyableResource]int{}
+
+	for _, r := range resources {
+		dependencies, _ := r.Dependencies()
+		for _, dep := range dependencies {
+			if isToBeDeleted[dep] {
+				numOfDependents[dep]++
+			}
+		}
+	}
+
+	jobQueue := make(chan DestroyableResource, len(resources))
+
+	workerResults := make(chan workerResult, len(resources))
+
+	for i := 1; i <= parallel; i++ {
+		go workerDestroy(ctx, jobQueue, workerResults)
+	}
+
+	log.Debug("start destroying resources in order of their dependencies")
+
+	numOfScheduledResources := 0
+
+	for _, r := range resources {
+		if numOfDependents[r] == 0 {
+			jobQueue <- r
+			numOfScheduledResources++
+		}
+	}
+
+	deletedResources := map[DestroyableResource]bool{}
+
+	for numOfScheduledResources > 0 {
+		result := <-workerResults
+		numOfScheduledResources--
+
+		report.record(result)
+
+		if !result.resourceHasBeenDeleted {
+			continue
+		}
+
+		deletedResources[result.resource] = true
+
+		dependencies, _ := result.resource.(DependentResource).Dependencies()
+		for _, dep := range dependencies {
+			if !isToBeDeleted[dep] {
+				continue
+			}
+
+			numOfDependents[dep]--
+
+			if numOfDependents[dep] == 0 && ctx.Err() == nil {
+				jobQueue <- dep
+				numOfScheduledResources++
+			}
+		}
+	}
+
+	close(jobQueue)
+
+	var remainingResources []DestroyableResource
+
+	for _, r := range resources {
+		if !deletedResources[r] {
+			remainingResources = append(remainingResources, r)
+		}
+	}
+
+	return remainingResources
+}
+
+// destroyWithRetries destroys a given list of resources by trial and error.
+//
 // If at least one resource is successfully destroyed per run (iteration through the list of given resources),
 // the remaining, failed resources will be retried in a next run (until all resources are destroyed or
 // some destroys have permanently failed).
-func DestroyResources(resources []DestroyableResource, parallel int) int {
+//
+// Returns the number of destroyed resources.
+func destroyWithRetries(ctx context.Context, resources []DestroyableResource, parallel int,
+	report *DestroyReport) int {
 	numOfResourcesToDelete := len(resources)
 	numOfDeletedResources := 0
 
 	var retryableResourceErrors []RetryDestroyError
 
+	var resourcesToRetry []DestroyableResource
+
 	jobQueue := make(chan DestroyableResource, numOfResourcesToDelete)
 
 	workerResults := make(chan workerResult, numOfResourcesToDelete)
 
 	for i := 1; i <= parallel; i++ {
-		go workerDestroy(jobQueue, workerResults)
+		go workerDestroy(ctx, jobQueue, workerResults)
 	}
 
 	log.Debug("start distributing resources to workers for this run")
@@ -44,6 +240,8 @@ func DestroyResources(resources []DestroyableResource, parallel int) int {
 	for i := 1; i <= numOfResourcesToDelete; i++ {
 		result := <-workerResults
 
+		report.record(result)
+
 		if result.resourceHasBeenDeleted {
 			numOfDeletedResources++
 
@@ -52,16 +250,16 @@ func DestroyResources(resources []DestroyableResource, parallel int) int {
 
 		if result.Err != nil {
 			retryableResourceErrors = append(retryableResourceErrors, *result.Err)
+			resourcesToRetry = append(resourcesToRetry, result.resource)
 		}
 	}
 
-	if len(retryableResourceErrors) > 0 && numOfDeletedResources > 0 {
-		var resourcesToRetry []DestroyableResource
-		for _, retryErr := range retryableResourceErrors {
-			resourcesToRetry = append(resourcesToRetry, retryErr.Resource)
-		}
+	if ctx.Err() != nil {
+		return numOfDeletedResources
+	}
 
-		numOfDeletedResources += DestroyResources(resourcesToRetry, parallel)
+	if len(retryableResourceErrors) > 0 && numOfDeletedResources > 0 {
+		numOfDeletedResources += destroyWithRetries(ctx, resourcesToRetry, parallel, report)
 	}
 
 	if len(retryableResourceErrors) > 0 && numOfDeletedResources == 0 {
@@ -69,7 +267,7 @@ func DestroyResources(resources []DestroyableResource, parallel int) int {
 			len(retryableResourceErrors)))
 
 		for _, err := range retryableResourceErrors {
-			log.WithError(err).WithField("id", err.Resource.ID()).Warn(internal.Pad(err.Resource.Type()))
+			internal.LogResource(err.Resource).WithError(err).Warn(internal.Pad(err.Resource.Type()))
 		}
 	}
 
@@ -77,15 +275,39 @@ func DestroyResources(resources []DestroyableResource, parallel int) int {
 }
 
 type workerResult struct {
+	// resource is the resource the worker tried to destroy.
+	resource               DestroyableResource
 	resourceHasBeenDeleted bool
+	// err is set if the destroy failed.
+	err error
 	// if set, it is worth retrying to delete this resource
 	Err *RetryDestroyError
+	// duration is the time the destroy took.
+	duration time.Duration
+	// cancelled is true if the destroy hasn't been started because the context was cancelled.
+	cancelled bool
 }
 
-// workerDestroy is a worker that destroys a resource.
-func workerDestroy(resources <-chan DestroyableResource, result chan<- workerResult) {
+// workerDestroy is a worker that destroys a resource. Once the context is cancelled,
+// the worker doesn't start destroying any further resources.
+func workerDestroy(ctx context.Context, resources <-chan DestroyableResource, result chan<- workerResult) {
 	for r := range resources {
+		if ctx.Err() != nil {
+			result <- workerResult{
+				resource:  r,
+				err:       ctx.Err(),
+				cancelled: true,
+			}
+
+			continue
+		}
+
+		startTime := time.Now()
+
 		err := r.Destroy()
+
+		duration := time.Since(startTime)
+
 		if err != nil {
 			switch err := err.(type) {
 			case *RetryDestroyError:
@@ -95,7 +317,34 @@ func workerDestroy(resources <-chan DestroyableResource, result chan<- workerRes
 				}).Info(internal.Pad("will retry to delete resource"))
 
 				result <- workerResult{
-					Err: err,
+					resource: r,
+					err:      err,
+					Err:      err,
+					duration: duration,
+				}
+
+			case *AlreadyGoneError:
+				log.WithFields(log.Fields{
+					"type":        r.Type(),
+					"resource_id": r.ID(),
+				}).Info(internal.Pad("resource has already been deleted"))
+
+				result <- workerResult{
+					resource:               r,
+					resourceHasBeenDeleted: true,
+					duration:               duration,
+				}
+
+			case *PermanentDestroyError:
+				log.WithError(err).WithFields(log.Fields{
+					"type":        r.Type(),
+					"resource_id": r.ID(),
+				}).Warn(internal.Pad("unable to delete resource (not retrying)"))
+
+				result <- workerResult{
+					resource: r,
+					err:      err,
+					duration: duration,
 				}
 
 			default:
@@ -104,14 +353,20 @@ func workerDestroy(resources <-chan DestroyableResource, result chan<- workerRes
 					"resource_id": r.ID(),
 				}).Debug(internal.Pad("unable to delete resource"))
 
-				result <- workerResult{}
+				result <- workerResult{
+					resource: r,
+					err:      err,
+					duration: duration,
+				}
 			}
 
 			continue
 		}
 
 		result <- workerResult{
+			resource:               r,
 			resourceHasBeenDeleted: true,
+			duration:               duration,
 		}
 	}
 }
@@ -122,15 +377,130 @@ func (r Resource) Destroy() error {
 		return fmt.Errorf("resource state is nil; need to call update first")
 	}
 
-	err := r.Provider.DestroyResource(r.Type(), *r.State())
+	err := r.destroyWithTimeout()
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"id": r.ID(), "type": r.Type()}).Debug(internal.Pad("failed to delete resource"))
 
-		return NewRetryDestroyError(err, &r)
+		return NewDestroyError(err, &r)
 	}
 
-	log.WithField("id", r.ID()).Error(internal.Pad(r.Type()))
+	internal.LogResource(r).Error(internal.Pad(r.Type()))
 
 	return nil
 }
+
+// destroyWithTimeout calls the provider to destroy the resource, but stops waiting for the destroy to finish
+// once the timeout of the resource (if any) is exceeded.
+//
+// As the provider can't be told to abort, a timed-out destroy keeps running in the background. A retry
+// of the resource doesn't issue a second destroy while the first one is still running, but waits for it instead.
+func (r Resource) destroyWithTimeout() error {
+	if r.timeout <= 0 {
+		return r.Provider.DestroyResource(r.Type(), *r.State())
+	}
+
+	d, running := startDestroy(r)
+	if running {
+		internal.LogResource(r).Debug(internal.Pad("waiting for timed-out destroy still in progress"))
+	}
+
+	select {
+	case <-d.done:
+		return d.err
+	case <-time.After(r.timeout):
+		return fmt.Errorf("destroy timed out (%s)", r.timeout)
+	}
+}
+
+// destroyKey identifies a resource instance of a provider.
+type destroyKey struct {
+	provider     interface{}
+	resourceType string
+	id           string
+}
+
+// inFlightDestroy is a call of the provider to destroy a resource.
+type inFlightDestroy struct {
+	// done is closed once the destroy has finished.
+	done chan struct{}
+	err  error
+}
+
+//nolint:gochecknoglobals
+var (
+	// inFlightDestroys are the destroys that have been started but not finished yet.
+	inFlightDestroys = struct {
+		sync.Mutex
+		destroys map[destroyKey]*inFlightDestroy
+	}{destroys: map[destroyKey]*inFlightDestroy{}}
+)
+
+// startDestroy calls the provider to destroy the resource in the background. If a destroy of the
+// resource is still running (i.e., an earlier one has timed out), that one is returned instead (and true).
+func startDestroy(r Resource) (*inFlightDestroy, bool) {
+	key := destroyKey{provider: r.Provider, resourceType: r.Type(), id: r.ID()}
+
+	inFlightDestroys.Lock()
+	defer inFlightDestroys.Unlock()
+
+	if d, ok := inFlightDestroys.destroys[key]; ok {
+		return d, true
+	}
+
+	d := &inFlightDestroy{done: make(chan struct{})}
+	inFlightDestroys.destroys[key] = d
+
+	go func() {
+		d.err = destroyResource(r.Provider, r.Type(), *r.State())
+
+		inFlightDestroys.Lock()
+		delete(inFlightDestroys.destroys, key)
+		inFlightDestroys.Unlock()
+
+		close(d.done)
+	}()
+
+	return d, false
+}
+
+// destroyResource calls the provider to destroy a resource with the given state. Unlike provider.DestroyResource
+// of awstools-lib, the destroy isn't limited by the timeout the provider has been launched with, so that
+// the timeout of the resource applies instead (see destroyWithTimeout).
+func destroyResource(p *provider.TerraformProvider, resourceType string, state cty.Value) error {
+	response := p.ApplyResourceChange(providers.ApplyResourceChangeRequest{
+		TypeName:     resourceType,
+		PriorState:   enableForceDestroyAttributes(state),
+		PlannedState: cty.NullVal(cty.DynamicPseudoType),
+		Config:       cty.NullVal(cty.DynamicPseudoType),
+	})
+
+	return response.Diagnostics.Err()
+}
+
+// enableForceDestroyAttributes sets force destroy attributes of a resource to true
+// to be able to successfully delete some resources
+// (eg. a non-empty S3 bucket or a AWS IAM role with attached policies).
+//
+// copied from github.com/jckuester/awstools-lib/terraform/provider/provider.go
+func enableForceDestroyAttributes(state cty.Value) cty.Value {
+	stateWithDestroyAttrs := map[string]cty.Value{}
+
+	if state.IsNull() {
+		return state
+	}
+
+	if state.CanIterateElements() {
+		for k, v := range state.AsValueMap() {
+			if k == "force_detach_policies" || k == "force_destroy" {
+				if v.Type().Equals(cty.Bool) {
+					stateWithDestroyAttrs[k] = cty.True
+				}
+			} else {
+				stateWithDestroyAttrs[k] = v
+			}
+		}
+	}
+
+	return cty.ObjectVal(stateWithDestroyAttrs)
+}
@@ -1,8 +1,10 @@
 package resource_test
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,10 +13,13 @@ import (
 	"github.com/gruntwork-io/terratest/modules/aws"
 	"github.com/gruntwork-io/terratest/modules/random"
 	"github.com/gruntwork-io/terratest/modules/terraform"
+	awsterraform "github.com/jckuester/awstools-lib/terraform"
 	"github.com/jckuester/awstools-lib/terraform/provider"
 	testUtil "github.com/jckuester/awstools-lib/test"
 	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/state"
 	"github.com/jckuester/terradozer/test"
+	"github.com/jckuester/terradozer/test/fakeprovider"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -99,8 +104,9 @@ func TestDestroyResources(t *testing.T) {
 				resources = append(resources, m)
 			}
 
-			actualDeletionCount := resource.DestroyResources(resources, tc.parallel)
-			assert.Equal(t, tc.expectedDeletionCount, actualDeletionCount)
+			actualReport := resource.DestroyResources(context.Background(), resources, tc.parallel)
+			assert.Len(t, actualReport.Destroyed(), tc.expectedDeletionCount)
+			assert.Len(t, actualReport.Failed(), len(resources)-tc.expectedDeletionCount)
 
 			ctrl.Finish()
 		})
@@ -120,8 +126,210 @@ func TestDestroyResources_DestroyError(t *testing.T) {
 	m.EXPECT().ID().Return("1234").AnyTimes()
 	m.EXPECT().Type().Return("aws_vpc").AnyTimes()
 
-	actualDeletionCount := resource.DestroyResources([]resource.DestroyableResource{m}, 3)
-	assert.Equal(t, actualDeletionCount, 0)
+	actualReport := resource.DestroyResources(context.Background(), []resource.DestroyableResource{m}, 3)
+	assert.Empty(t, actualReport.Destroyed())
+
+	require.Len(t, actualReport.Failed(), 1)
+	assert.EqualError(t, actualReport.Failed()[0].Err, "some error")
+	assert.Equal(t, 1, actualReport.Failed()[0].Attempts)
+}
+
+func TestDestroyResources_Report(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	ctrl := gomock.NewController(t)
+
+	newResource := func(rType string, numOfFailedDeletions int) *MockDestroyableResource {
+		m := NewMockDestroyableResource(ctrl)
+
+		resFailedDeletions := m.EXPECT().Destroy().
+			Return(resource.NewRetryDestroyError(fmt.Errorf("some error"), m)).
+			Times(numOfFailedDeletions)
+
+		m.EXPECT().Destroy().Return(nil).After(resFailedDeletions).AnyTimes()
+
+		m.EXPECT().ID().Return("1234").AnyTimes()
+		m.EXPECT().Type().Return(rType).AnyTimes()
+
+		return m
+	}
+
+	vpc := newResource("aws_vpc", 2)
+	subnet := newResource("aws_subnet", 1)
+	instance := newResource("aws_instance", 0)
+
+	// permanently failing resource
+	bucket := NewMockDestroyableResource(ctrl)
+	bucket.EXPECT().Destroy().Return(fmt.Errorf("bucket not empty")).Times(1)
+	bucket.EXPECT().ID().Return("1234").AnyTimes()
+	bucket.EXPECT().Type().Return("aws_s3_bucket").AnyTimes()
+
+	actualReport := resource.DestroyResources(context.Background(), []resource.DestroyableResource{vpc, subnet, instance, bucket}, 1)
+
+	require.Len(t, actualReport.Results, 4)
+
+	expectedResults := []struct {
+		resource  resource.DestroyableResource
+		destroyed bool
+		attempts  int
+		errMsg    string
+	}{
+		{resource: vpc, destroyed: true, attempts: 3},
+		{resource: subnet, destroyed: true, attempts: 2},
+		{resource: instance, destroyed: true, attempts: 1},
+		{resource: bucket, attempts: 1, errMsg: "bucket not empty"},
+	}
+
+	for i, expected := range expectedResults {
+		actualResult := actualReport.Results[i]
+
+		assert.Equal(t, expected.resource, actualResult.Resource)
+		assert.Equal(t, expected.destroyed, actualResult.Destroyed)
+		assert.Equal(t, expected.attempts, actualResult.Attempts)
+
+		if expected.errMsg != "" {
+			assert.EqualError(t, actualResult.Err, expected.errMsg)
+		} else {
+			assert.NoError(t, actualResult.Err)
+		}
+	}
+
+	assert.Len(t, actualReport.Destroyed(), 3)
+	assert.Len(t, actualReport.Failed(), 1)
+	assert.Equal(t, 3, actualReport.NumOfRetries())
+
+	ctrl.Finish()
+}
+
+func TestDestroyResources_Cancelled(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	tests := []struct {
+		name             string
+		withDependencies bool
+	}{
+		{
+			name: "no further destroys started after cancel",
+		},
+		{
+			name:             "no further destroys scheduled in dependency order after cancel",
+			withDependencies: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			newResource := func(rType string, numOfDeletions int,
+				dependencies ...resource.DestroyableResource) *MockDependentResource {
+				m := NewMockDependentResource(ctrl)
+
+				// cancel while destroying the first resource
+				m.EXPECT().Destroy().Do(cancel).Return(nil).Times(numOfDeletions)
+
+				m.EXPECT().ID().Return("1234").AnyTimes()
+				m.EXPECT().Type().Return(rType).AnyTimes()
+				m.EXPECT().Dependencies().Return(dependencies, tc.withDependencies).AnyTimes()
+
+				return m
+			}
+
+			vpc := newResource("aws_vpc", 0)
+			subnet := newResource("aws_subnet", 0, vpc)
+			instance := newResource("aws_instance", 1, subnet)
+
+			actualReport := resource.DestroyResources(ctx,
+				[]resource.DestroyableResource{instance, subnet, vpc}, 1)
+
+			assert.True(t, actualReport.Cancelled)
+
+			require.Len(t, actualReport.Destroyed(), 1)
+			assert.Equal(t, instance, actualReport.Destroyed()[0].Resource)
+
+			require.Len(t, actualReport.Failed(), 2)
+
+			for _, r := range actualReport.Failed() {
+				assert.Equal(t, 0, r.Attempts)
+				assert.Equal(t, context.Canceled, r.Err)
+			}
+
+			ctrl.Finish()
+		})
+	}
+}
+
+func TestDestroyResources_DependencyOrder(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	tests := []struct {
+		name                  string
+		failedDeletions       map[string]int
+		expectedDeletionCount int
+		expectedOrder         []string
+	}{
+		{
+			name:                  "destroyed in reverse order of dependencies",
+			expectedDeletionCount: 4,
+			expectedOrder:         []string{"aws_instance", "aws_subnet", "aws_vpc"},
+		},
+		{
+			name: "failed destroy of dependent resource falls back to retries",
+			failedDeletions: map[string]int{
+				"aws_instance": 1,
+			},
+			expectedDeletionCount: 4,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			var mu sync.Mutex
+
+			var actualOrder []string
+
+			newResource := func(rType string, dependencies ...resource.DestroyableResource) *MockDependentResource {
+				m := NewMockDependentResource(ctrl)
+
+				destroyed := func() { mu.Lock(); actualOrder = append(actualOrder, rType); mu.Unlock() }
+
+				resFailedDeletions := m.EXPECT().Destroy().Do(destroyed).
+					Return(resource.NewRetryDestroyError(fmt.Errorf("some error"), m)).
+					Times(tc.failedDeletions[rType])
+
+				m.EXPECT().Destroy().Do(destroyed).Return(nil).After(resFailedDeletions).Times(1)
+
+				m.EXPECT().ID().Return("1234").AnyTimes()
+				m.EXPECT().Type().Return(rType).AnyTimes()
+				m.EXPECT().Dependencies().Return(dependencies, true).AnyTimes()
+
+				return m
+			}
+
+			vpc := newResource("aws_vpc")
+			subnet := newResource("aws_subnet", vpc)
+			instance := newResource("aws_instance", subnet)
+
+			// resource without dependency information
+			bucket := NewMockDestroyableResource(ctrl)
+			bucket.EXPECT().Destroy().Return(nil).Times(1)
+			bucket.EXPECT().ID().Return("1234").AnyTimes()
+			bucket.EXPECT().Type().Return("aws_s3_bucket").AnyTimes()
+
+			actualReport := resource.DestroyResources(context.Background(),
+				[]resource.DestroyableResource{vpc, bucket, subnet, instance}, 10)
+			assert.Len(t, actualReport.Destroyed(), tc.expectedDeletionCount)
+
+			if tc.expectedOrder != nil {
+				assert.Equal(t, tc.expectedOrder, actualOrder)
+			}
+
+			ctrl.Finish()
+		})
+	}
 }
 
 func TestResource_Destroy(t *testing.T) {
@@ -313,3 +521,133 @@ func TestResource_Destroy_NilState(t *testing.T) {
 	err := r.Destroy()
 	assert.EqualError(t, err, "resource state is nil; need to call update first")
 }
+
+func TestDestroyResources_Protected(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	ctrl := gomock.NewController(t)
+
+	vpc := NewMockDestroyableResource(ctrl)
+	vpc.EXPECT().Destroy().Return(nil).Times(1)
+	vpc.EXPECT().ID().Return("vpc-1").AnyTimes()
+	vpc.EXPECT().Type().Return("aws_vpc").AnyTimes()
+
+	key := NewMockDestroyableResource(ctrl)
+	key.EXPECT().Destroy().Times(0)
+	key.EXPECT().ID().Return("key-1").AnyTimes()
+	key.EXPECT().Type().Return("aws_kms_key").AnyTimes()
+
+	actualReport := resource.DestroyResources(context.Background(),
+		[]resource.DestroyableResource{vpc, key}, 1,
+		resource.WithProtection(resource.Protection{Types: []string{"aws_kms_key"}}))
+
+	require.Len(t, actualReport.Results, 2)
+
+	assert.True(t, actualReport.Results[0].Destroyed)
+	assert.False(t, actualReport.Results[0].Protected)
+
+	assert.False(t, actualReport.Results[1].Destroyed)
+	assert.True(t, actualReport.Results[1].Protected)
+	assert.Equal(t, "type aws_kms_key", actualReport.Results[1].ProtectedBy)
+	assert.Equal(t, 0, actualReport.Results[1].Attempts)
+
+	assert.Len(t, actualReport.Destroyed(), 1)
+	assert.Len(t, actualReport.Protected(), 1)
+	assert.Empty(t, actualReport.Failed())
+
+	ctrl.Finish()
+}
+
+func TestDestroyResources_ErrorClasses(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	ctrl := gomock.NewController(t)
+
+	newResource := func(rType string, err error) *MockDestroyableResource {
+		m := NewMockDestroyableResource(ctrl)
+
+		m.EXPECT().ID().Return("1234").AnyTimes()
+		m.EXPECT().Type().Return(rType).AnyTimes()
+
+		m.EXPECT().Destroy().Return(resource.NewDestroyError(err, m)).Times(1)
+
+		return m
+	}
+
+	vpc := newResource("aws_vpc", fmt.Errorf("InvalidVpcID.NotFound: The vpc ID 'vpc-1234' does not exist"))
+	bucket := newResource("aws_s3_bucket", fmt.Errorf("AccessDenied: Access Denied"))
+
+	actualReport := resource.DestroyResources(context.Background(), []resource.DestroyableResource{vpc, bucket}, 1,
+		resource.WithRetryPolicy(resource.RetryPolicy{MaxAttempts: 5, BackoffBase: time.Millisecond}))
+
+	require.Len(t, actualReport.Destroyed(), 1)
+	assert.Equal(t, vpc, actualReport.Destroyed()[0].Resource)
+
+	require.Len(t, actualReport.Failed(), 1)
+	assert.Equal(t, bucket, actualReport.Failed()[0].Resource)
+	assert.Equal(t, 1, actualReport.Failed()[0].Attempts)
+	assert.EqualError(t, actualReport.Failed()[0].Err, "AccessDenied: Access Denied")
+
+	ctrl.Finish()
+}
+
+func TestDestroyResources_FakeProvider(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	installDir := t.TempDir()
+
+	fakeprovider.Install(t, installDir, fakeprovider.Config{
+		Resources: map[string]fakeprovider.ResourceConfig{
+			"aws_instance": {
+				Attributes: []string{"subnet_id"},
+				Instances: map[string]fakeprovider.InstanceConfig{
+					"i-1": {DestroyErrors: []string{"Throttling: Rate exceeded"}},
+				},
+			},
+			"aws_subnet": {
+				Attributes: []string{"vpc_id"},
+				Instances: map[string]fakeprovider.InstanceConfig{
+					"subnet-1": {Gone: true},
+				},
+			},
+			"aws_vpc": {
+				Attributes: []string{"cidr_block"},
+				Instances: map[string]fakeprovider.InstanceConfig{
+					"vpc-1": {DependedOnBy: []string{"i-1", "subnet-1"}},
+				},
+			},
+		},
+	})
+
+	awsProvider, err := provider.Init("aws", installDir, 10*time.Second)
+	require.NoError(t, err)
+
+	defer awsProvider.Close()
+
+	tfstate, err := state.New("../../test/test-fixtures/tfstates/fake-provider.tfstate")
+	require.NoError(t, err)
+
+	resources, err := tfstate.Resources(map[string]*provider.TerraformProvider{"aws": awsProvider})
+	require.NoError(t, err)
+	require.Len(t, resources, 3)
+
+	resourcesWithUpdatedState := awsterraform.UpdateResources(resources, 2)
+	require.Len(t, resourcesWithUpdatedState, 2)
+
+	var resourcesToDelete []resource.DestroyableResource
+	for _, r := range resourcesWithUpdatedState {
+		resourcesToDelete = append(resourcesToDelete, r.(resource.DestroyableResource))
+	}
+
+	actualReport := resource.DestroyResources(context.Background(), resourcesToDelete, 2,
+		resource.WithRetryPolicy(resource.RetryPolicy{
+			MaxAttempts: 20,
+			BackoffBase: 100 * time.Millisecond,
+			BackoffCap:  200 * time.Millisecond,
+		}))
+
+	assert.Len(t, actualReport.Destroyed(), 2)
+	assert.Empty(t, actualReport.Failed())
+
+	assert.Equal(t, []string{"i-1", "vpc-1"}, fakeprovider.Destroyed(t, installDir))
+}
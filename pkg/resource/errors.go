@@ -20,3 +20,45 @@ type RetryDestroyError struct {
 func (r RetryDestroyError) Error() string {
 	return r.Err.Error()
 }
+
+// NewPermanentDestroyError creates a PermanentDestroyError.
+func NewPermanentDestroyError(err error, r DestroyableResource) *PermanentDestroyError {
+	if err == nil {
+		return nil
+	}
+
+	return &PermanentDestroyError{Err: err, Resource: r}
+}
+
+// PermanentDestroyError is returned when destroying of a resource has failed for a reason that
+// won't go away by retrying (e.g., missing permissions). It is not worth retrying.
+type PermanentDestroyError struct {
+	Err error
+	// Resource is the resource for which a destroy has failed.
+	Resource DestroyableResource
+}
+
+func (p PermanentDestroyError) Error() string {
+	return p.Err.Error()
+}
+
+// NewAlreadyGoneError creates an AlreadyGoneError.
+func NewAlreadyGoneError(err error, r DestroyableResource) *AlreadyGoneError {
+	if err == nil {
+		return nil
+	}
+
+	return &AlreadyGoneError{Err: err, Resource: r}
+}
+
+// AlreadyGoneError is returned when destroying of a resource has failed because the resource doesn't exist
+// (anymore). The resource counts as destroyed.
+type AlreadyGoneError struct {
+	Err error
+	// Resource is the resource that doesn't exist.
+	Resource DestroyableResource
+}
+
+func (a AlreadyGoneError) Error() string {
+	return a.Err.Error()
+}
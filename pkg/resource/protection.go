@@ -0,0 +1,154 @@
+package resource
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jckuester/terradozer/internal"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Protection is a set of rules selecting resources that must never be destroyed (e.g., a shared KMS key),
+// even if they are part of a state. Patterns are globs, in which "*" matches any sequence of characters
+// and "?" matches any single character.
+//
+// A resource is protected if it matches any of the rules. Resources that a protected resource depends on
+// are protected as well.
+type Protection struct {
+	// Types match the resource type (e.g., aws_kms_key).
+	Types []string
+	// Addresses match the absolute address of a resource instance (e.g., module.dns.aws_route53_zone.main).
+	Addresses []string
+	// IDs match the ID of a resource.
+	IDs []string
+	// Tags match the value of a tag (given by key) of a resource, e.g., {"Protected": "true"}.
+	// Tags are read from the tags (or tags_all) attribute of a resource's state.
+	Tags map[string]string
+}
+
+// IsEmpty returns true if the protection has no rules.
+func (p Protection) IsEmpty() bool {
+	return len(p.Types) == 0 && len(p.Addresses) == 0 && len(p.IDs) == 0 && len(p.Tags) == 0
+}
+
+// ProtectedResources returns all of the given resources that are protected, mapped to the reason why.
+func (p Protection) ProtectedResources(resources []DestroyableResource) map[DestroyableResource]string {
+	result := map[DestroyableResource]string{}
+
+	if p.IsEmpty() {
+		return result
+	}
+
+	for _, r := range resources {
+		if reason, ok := p.matches(r); ok {
+			result[r] = reason
+		}
+	}
+
+	var protectDependencies func(r DestroyableResource)
+
+	protectDependencies = func(r DestroyableResource) {
+		dr, ok := r.(DependentResource)
+		if !ok {
+			return
+		}
+
+		dependencies, _ := dr.Dependencies()
+		for _, dep := range dependencies {
+			if _, ok := result[dep]; ok {
+				continue
+			}
+
+			result[dep] = "dependency of " + displayName(r)
+			protectDependencies(dep)
+		}
+	}
+
+	for _, r := range resources {
+		if _, ok := result[r]; ok {
+			protectDependencies(r)
+		}
+	}
+
+	return result
+}
+
+// matches returns the rule that the given resource matches.
+func (p Protection) matches(r DestroyableResource) (string, bool) {
+	if internal.MatchesAnyGlob(p.Types, r.Type()) {
+		return "type " + r.Type(), true
+	}
+
+	if addressable, ok := r.(interface{ Address() string }); ok && addressable.Address() != "" {
+		if internal.MatchesAnyGlob(p.Addresses, addressable.Address()) {
+			return "address " + addressable.Address(), true
+		}
+	}
+
+	if internal.MatchesAnyGlob(p.IDs, r.ID()) {
+		return "id " + r.ID(), true
+	}
+
+	if len(p.Tags) == 0 {
+		return "", false
+	}
+
+	stateful, ok := r.(interface{ State() *cty.Value })
+	if !ok {
+		return "", false
+	}
+
+	tags := tagsOf(stateful.State())
+
+	var keys []string
+	for k := range p.Tags {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if v, ok := tags[k]; ok && internal.MatchesAnyGlob([]string{p.Tags[k]}, v) {
+			return fmt.Sprintf("tag %s=%s", k, v), true
+		}
+	}
+
+	return "", false
+}
+
+// tagsOf returns the tags of a resource read from the tags (and tags_all) attribute of its state.
+func tagsOf(state *cty.Value) map[string]string {
+	result := map[string]string{}
+
+	if state == nil || state.IsNull() || !state.IsKnown() || !state.Type().IsObjectType() {
+		return result
+	}
+
+	for _, attr := range []string{"tags_all", "tags"} {
+		if !state.Type().HasAttribute(attr) {
+			continue
+		}
+
+		tags := state.GetAttr(attr)
+		if tags.IsNull() || !tags.IsKnown() || !(tags.Type().IsMapType() || tags.Type().IsObjectType()) {
+			continue
+		}
+
+		for k, v := range tags.AsValueMap() {
+			if v.IsKnown() && !v.IsNull() && v.Type() == cty.String {
+				result[k] = v.AsString()
+			}
+		}
+	}
+
+	return result
+}
+
+// displayName returns the address of a resource or, if unknown, its type and ID.
+func displayName(r DestroyableResource) string {
+	if addressable, ok := r.(interface{ Address() string }); ok && addressable.Address() != "" {
+		return addressable.Address()
+	}
+
+	return fmt.Sprintf("%s (id=%s)", r.Type(), r.ID())
+}
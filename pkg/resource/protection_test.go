@@ -0,0 +1,99 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestProtection_ProtectedResources(t *testing.T) {
+	newResource := func(rType, id, address string, tags map[string]cty.Value) *resource.Resource {
+		state := cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.StringVal(id),
+			"tags": cty.NullVal(cty.Map(cty.String)),
+		})
+
+		if tags != nil {
+			state = cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.StringVal(id),
+				"tags": cty.MapVal(tags),
+			})
+		}
+
+		r := resource.NewWithState(rType, id, nil, &state)
+		r.SetAddress(address)
+
+		return r
+	}
+
+	zone := newResource("aws_route53_zone", "Z123", "module.dns.aws_route53_zone.main", nil)
+	key := newResource("aws_kms_key", "key-1", "aws_kms_key.shared",
+		map[string]cty.Value{"Protected": cty.StringVal("true")})
+	vpc := newResource("aws_vpc", "vpc-1", "aws_vpc.test", map[string]cty.Value{"Env": cty.StringVal("prod-eu")})
+	subnet := newResource("aws_subnet", "subnet-1", "aws_subnet.test", nil)
+	instance := newResource("aws_instance", "i-1", "aws_instance.test", nil)
+
+	vpc.SetDependencies(nil)
+	subnet.SetDependencies([]resource.DestroyableResource{vpc})
+	instance.SetDependencies([]resource.DestroyableResource{subnet})
+
+	resources := []resource.DestroyableResource{zone, key, vpc, subnet, instance}
+
+	tests := []struct {
+		name              string
+		protection        resource.Protection
+		expectedProtected map[resource.DestroyableResource]string
+	}{
+		{
+			name:              "no rules",
+			expectedProtected: map[resource.DestroyableResource]string{},
+		},
+		{
+			name:       "by type",
+			protection: resource.Protection{Types: []string{"aws_kms_*"}},
+			expectedProtected: map[resource.DestroyableResource]string{
+				key: "type aws_kms_key",
+			},
+		},
+		{
+			name:       "by address",
+			protection: resource.Protection{Addresses: []string{"module.dns.*"}},
+			expectedProtected: map[resource.DestroyableResource]string{
+				zone: "address module.dns.aws_route53_zone.main",
+			},
+		},
+		{
+			name:       "by ID",
+			protection: resource.Protection{IDs: []string{"Z123"}},
+			expectedProtected: map[resource.DestroyableResource]string{
+				zone: "id Z123",
+			},
+		},
+		{
+			name:       "by tag",
+			protection: resource.Protection{Tags: map[string]string{"Protected": "true", "Env": "prod*"}},
+			expectedProtected: map[resource.DestroyableResource]string{
+				key: "tag Protected=true",
+				vpc: "tag Env=prod-eu",
+			},
+		},
+		{
+			name:       "dependencies of protected resource",
+			protection: resource.Protection{Types: []string{"aws_instance"}},
+			expectedProtected: map[resource.DestroyableResource]string{
+				instance: "type aws_instance",
+				subnet:   "dependency of aws_instance.test",
+				vpc:      "dependency of aws_subnet.test",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualProtected := tc.protection.ProtectedResources(resources)
+
+			assert.Equal(t, tc.expectedProtected, actualProtected)
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package resource
+
+import (
+	"time"
+)
+
+// DestroyReport is the outcome of destroying a list of resources (see DestroyResources).
+type DestroyReport struct {
+	// Results contains the outcome per resource, in the order the resources have been given.
+	Results []*DestroyResult
+	// Duration is the time it took to destroy all resources.
+	Duration time.Duration
+	// Cancelled is true if the context has been cancelled while destroying resources.
+	Cancelled bool
+
+	resultsByResource map[DestroyableResource]*DestroyResult
+}
+
+// DestroyResult is the outcome of destroying a single resource.
+type DestroyResult struct {
+	Resource DestroyableResource
+	// Destroyed is true if the resource has been successfully destroyed.
+	Destroyed bool
+	// Err is the error of the last failed attempt to destroy the resource.
+	Err error
+	// Attempts is the number of times destroying the resource has been tried.
+	Attempts int
+	// Duration is the time spent in all attempts to destroy the resource.
+	Duration time.Duration
+	// Protected is true if the resource hasn't been tried to destroy, as it is protected (see Protection).
+	Protected bool
+	// ProtectedBy is the reason why the resource is protected (e.g., "type aws_kms_key").
+	ProtectedBy string
+}
+
+// newDestroyReport creates a report without any destroy attempts for the given resources.
+func newDestroyReport(resources []DestroyableResource) *DestroyReport {
+	report := &DestroyReport{
+		resultsByResource: map[DestroyableResource]*DestroyResult{},
+	}
+
+	for _, r := range resources {
+		result := &DestroyResult{Resource: r}
+
+		report.Results = append(report.Results, result)
+		report.resultsByResource[r] = result
+	}
+
+	return report
+}
+
+// Destroyed returns the results of all successfully destroyed resources.
+func (r *DestroyReport) Destroyed() []*DestroyResult {
+	var result []*DestroyResult
+
+	for _, res := range r.Results {
+		if res.Destroyed {
+			result = append(result, res)
+		}
+	}
+
+	return result
+}
+
+// Failed returns the results of all resources that couldn't be destroyed (excluding protected ones).
+func (r *DestroyReport) Failed() []*DestroyResult {
+	var result []*DestroyResult
+
+	for _, res := range r.Results {
+		if !res.Destroyed && !res.Protected {
+			result = append(result, res)
+		}
+	}
+
+	return result
+}
+
+// Protected returns the results of all resources that haven't been tried to destroy, as they are protected.
+func (r *DestroyReport) Protected() []*DestroyResult {
+	var result []*DestroyResult
+
+	for _, res := range r.Results {
+		if res.Protected {
+			result = append(result, res)
+		}
+	}
+
+	return result
+}
+
+// NumOfRetries returns the number of all attempts to destroy resources after the first one.
+func (r *DestroyReport) NumOfRetries() int {
+	result := 0
+
+	for _, res := range r.Results {
+		if res.Attempts > 1 {
+			result += res.Attempts - 1
+		}
+	}
+
+	return result
+}
+
+// protect marks the given resources as protected, mapped to the reason why.
+func (r *DestroyReport) protect(protected map[DestroyableResource]string) {
+	for resource, reason := range protected {
+		res, ok := r.resultsByResource[resource]
+		if !ok {
+			continue
+		}
+
+		res.Protected = true
+		res.ProtectedBy = reason
+	}
+}
+
+// cancel marks the report as cancelled and all (not protected) resources that haven't been tried to destroy as failed
+// with the given error (i.e., the error of a cancelled context). Nothing is marked if the error is nil.
+func (r *DestroyReport) cancel(err error) {
+	if err == nil {
+		return
+	}
+
+	r.Cancelled = true
+
+	for _, res := range r.Results {
+		if res.Attempts == 0 && !res.Protected {
+			res.Err = err
+		}
+	}
+}
+
+// record adds the outcome of an attempt to destroy a resource to the report.
+func (r *DestroyReport) record(w workerResult) {
+	res, ok := r.resultsByResource[w.resource]
+	if !ok {
+		return
+	}
+
+	if w.cancelled {
+		return
+	}
+
+	res.Attempts++
+	res.Duration += w.duration
+	res.Destroyed = w.resourceHasBeenDeleted
+	res.Err = w.err
+}
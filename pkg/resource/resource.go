@@ -2,6 +2,8 @@
 package resource
 
 import (
+	"time"
+
 	"github.com/jckuester/awstools-lib/terraform"
 	"github.com/jckuester/awstools-lib/terraform/provider"
 	"github.com/zclconf/go-cty/cty"
@@ -10,6 +12,15 @@ import (
 // Resource represents a Terraform resource that can be destroyed.
 type Resource struct {
 	terraform.Resource
+
+	// address is the absolute address of the resource instance in a Terraform state (e.g., module.app.aws_vpc.test).
+	address string
+	// dependencies are the resources this resource depends on.
+	dependencies []DestroyableResource
+	// hasDependencyInfo is true if it is known which resources this resource depends on.
+	hasDependencyInfo bool
+	// timeout is the amount of time to wait for a destroy of the resource to finish.
+	timeout time.Duration
 }
 
 // New creates a destroyable Terraform resource.
@@ -21,7 +32,7 @@ type Resource struct {
 // For some resources, additionally to the ID a list of attributes needs to be populated to destroy it.
 func New(terraformType, id string, attrs map[string]cty.Value, provider *provider.TerraformProvider) *Resource {
 	return &Resource{
-		terraform.Resource{
+		Resource: terraform.Resource{
 			Type:     terraformType,
 			ID:       id,
 			Provider: provider,
@@ -37,7 +48,7 @@ func New(terraformType, id string, attrs map[string]cty.Value, provider *provide
 // than with New(), which is used when the state is not known.
 func NewWithState(terraformType, id string, provider *provider.TerraformProvider, state *cty.Value) *Resource {
 	return &Resource{
-		terraform.Resource{
+		Resource: terraform.Resource{
 			Type:     terraformType,
 			ID:       id,
 			Provider: provider,
@@ -60,3 +71,41 @@ func (r Resource) ID() string {
 func (r Resource) State() *cty.Value {
 	return r.Resource.State
 }
+
+// SetAddress sets the absolute address of the resource instance in a Terraform state.
+func (r *Resource) SetAddress(address string) {
+	r.address = address
+}
+
+// Address returns the absolute address of the resource instance in a Terraform state
+// (e.g., module.app.aws_vpc.test). The address is empty if the resource isn't part of a state.
+func (r Resource) Address() string {
+	return r.address
+}
+
+// SetDependencies sets the resources this resource depends on. A resource is only destroyed after
+// all resources that depend on it have been destroyed (see DestroyResources).
+func (r *Resource) SetDependencies(dependencies []DestroyableResource) {
+	r.dependencies = dependencies
+	r.hasDependencyInfo = true
+}
+
+// Dependencies returns the resources this resource depends on.
+// The result ok is false if it isn't known which resources this resource depends on.
+func (r Resource) Dependencies() ([]DestroyableResource, bool) {
+	return r.dependencies, r.hasDependencyInfo
+}
+
+// SetTimeout sets the amount of time to wait for a destroy of the resource to finish, which replaces
+// the timeout of the resource's provider (see provider.Launch) for destroying the resource.
+//
+// If not set, only the timeout of the provider applies.
+func (r *Resource) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
+}
+
+// Timeout returns the amount of time to wait for a destroy of the resource to finish
+// (zero, if only the timeout of the provider applies).
+func (r Resource) Timeout() time.Duration {
+	return r.timeout
+}
@@ -0,0 +1,194 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/jckuester/terradozer/internal"
+)
+
+// RetryPolicy configures how resources that failed to be destroyed with a retryable error (see RetryDestroyError)
+// are retried. Each resource is retried on its own with exponential backoff, until it is destroyed or
+// its maximum number of attempts or total duration is exceeded.
+//
+// The zero value retries resources by trial and error instead (see DestroyResources).
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts to destroy a resource (0 means no limit).
+	MaxAttempts int
+	// BackoffBase is the delay before the first retry, which is doubled with each further retry.
+	BackoffBase time.Duration
+	// BackoffCap is the maximum delay between two attempts (0 means no limit).
+	BackoffCap time.Duration
+	// Jitter is the fraction (between 0 and 1) by which each delay is randomly reduced,
+	// so that resources failed at the same time aren't retried all at once.
+	Jitter float64
+	// MaxDuration is the maximum time from the first attempt to destroy a resource
+	// until the last retry is started (0 means no limit).
+	MaxDuration time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used for any setting not given explicitly, once retries with
+// exponential backoff are enabled by giving any other setting.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		BackoffBase: 2 * time.Second,
+		BackoffCap:  30 * time.Second,
+		Jitter:      0.2,
+		MaxDuration: 5 * time.Minute,
+	}
+}
+
+// WithRetryPolicy retries each resource that failed to be destroyed with a retryable error according
+// to the given policy.
+func WithRetryPolicy(p RetryPolicy) DestroyOption {
+	return func(o *destroyOptions) {
+		o.retryPolicy = p
+	}
+}
+
+// IsEmpty returns true if the policy is the zero value.
+func (p RetryPolicy) IsEmpty() bool {
+	return p == RetryPolicy{}
+}
+
+// Validate returns an error if the policy is invalid or would retry a resource forever.
+func (p RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return fmt.Errorf("max attempts must not be negative: %d", p.MaxAttempts)
+	}
+
+	if p.MaxAttempts == 0 && p.MaxDuration <= 0 {
+		return fmt.Errorf("either max attempts or max duration of retries must be limited")
+	}
+
+	if p.BackoffBase < 0 || p.BackoffCap < 0 || p.MaxDuration < 0 {
+		return fmt.Errorf("backoff and max duration of retries must not be negative")
+	}
+
+	if p.BackoffCap > 0 && p.BackoffCap < p.BackoffBase {
+		return fmt.Errorf("backoff cap (%s) must not be less than backoff base (%s)", p.BackoffCap, p.BackoffBase)
+	}
+
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return fmt.Errorf("jitter must be between 0 and 1: %g", p.Jitter)
+	}
+
+	return nil
+}
+
+// Backoff returns the delay before the next attempt to destroy a resource after the given number of attempts.
+func (p RetryPolicy) Backoff(attempts int) time.Duration {
+	result := p.BackoffBase
+
+	for i := 1; i < attempts && result > 0 && result < math.MaxInt64/2; i++ {
+		result *= 2
+	}
+
+	if p.BackoffCap > 0 && result > p.BackoffCap {
+		result = p.BackoffCap
+	}
+
+	if p.Jitter > 0 {
+		//nolint:gosec
+		result -= time.Duration(rand.Float64() * p.Jitter * float64(result))
+	}
+
+	return result
+}
+
+// nextRetry returns the delay before the next attempt to destroy a resource, given the number of attempts so far
+// and the time elapsed since the first attempt. It returns false if the resource shouldn't be retried anymore.
+func (p RetryPolicy) nextRetry(attempts int, elapsed time.Duration) (time.Duration, bool) {
+	if p.MaxAttempts > 0 && attempts >= p.MaxAttempts {
+		return 0, false
+	}
+
+	delay := p.Backoff(attempts)
+
+	if p.MaxDuration > 0 && elapsed+delay > p.MaxDuration {
+		return 0, false
+	}
+
+	return delay, true
+}
+
+// destroyWithBackoff destroys a given list of resources, retrying each resource that failed with
+// a retryable error according to the given policy.
+func destroyWithBackoff(ctx context.Context, resources []DestroyableResource, parallel int, policy RetryPolicy,
+	report *DestroyReport) {
+	// each resource is at most once in the queue at a time
+	jobQueue := make(chan DestroyableResource, len(resources))
+
+	workerResults := make(chan workerResult, len(resources))
+
+	for i := 1; i <= parallel; i++ {
+		go workerDestroy(ctx, jobQueue, workerResults)
+	}
+
+	log.Debug("start destroying resources with backoff")
+
+	for _, r := range resources {
+		jobQueue <- r
+	}
+
+	firstAttempt := map[DestroyableResource]time.Time{}
+
+	var retriesExceeded []RetryDestroyError
+
+	for numOfPendingResources := len(resources); numOfPendingResources > 0; {
+		result := <-workerResults
+
+		report.record(result)
+
+		if _, ok := firstAttempt[result.resource]; !ok {
+			firstAttempt[result.resource] = time.Now().Add(-result.duration)
+		}
+
+		if result.resourceHasBeenDeleted || result.Err == nil || ctx.Err() != nil {
+			numOfPendingResources--
+
+			continue
+		}
+
+		attempts := report.resultsByResource[result.resource].Attempts
+
+		delay, ok := policy.nextRetry(attempts, time.Since(firstAttempt[result.resource]))
+		if !ok {
+			retriesExceeded = append(retriesExceeded, *result.Err)
+			numOfPendingResources--
+
+			continue
+		}
+
+		internal.LogResource(result.resource).WithFields(log.Fields{
+			"attempt": attempts + 1,
+			"delay":   delay,
+		}).Debug(internal.Pad("retrying to delete resource"))
+
+		go func(r DestroyableResource) {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+
+			// the worker doesn't start the destroy if the context has been cancelled meanwhile
+			jobQueue <- r
+		}(result.resource)
+	}
+
+	close(jobQueue)
+
+	if len(retriesExceeded) > 0 {
+		internal.LogTitle(fmt.Sprintf("failed to delete the following resources (retries exceeded): %d",
+			len(retriesExceeded)))
+
+		for _, err := range retriesExceeded {
+			internal.LogResource(err.Resource).WithError(err).Warn(internal.Pad(err.Resource.Type()))
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package resource_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/golang/mock/gomock"
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        resource.RetryPolicy
+		attempts      int
+		expectedDelay time.Duration
+	}{
+		{
+			name:          "first retry",
+			policy:        resource.RetryPolicy{BackoffBase: time.Second},
+			attempts:      1,
+			expectedDelay: time.Second,
+		},
+		{
+			name:          "doubled with each retry",
+			policy:        resource.RetryPolicy{BackoffBase: time.Second},
+			attempts:      4,
+			expectedDelay: 8 * time.Second,
+		},
+		{
+			name:          "capped",
+			policy:        resource.RetryPolicy{BackoffBase: time.Second, BackoffCap: 5 * time.Second},
+			attempts:      4,
+			expectedDelay: 5 * time.Second,
+		},
+		{
+			name:          "no overflow",
+			policy:        resource.RetryPolicy{BackoffBase: time.Second, BackoffCap: time.Minute},
+			attempts:      100,
+			expectedDelay: time.Minute,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedDelay, tc.policy.Backoff(tc.attempts))
+		})
+	}
+}
+
+func TestRetryPolicy_Backoff_Jitter(t *testing.T) {
+	policy := resource.RetryPolicy{BackoffBase: time.Second, Jitter: 0.5}
+
+	for i := 0; i < 100; i++ {
+		delay := policy.Backoff(2)
+
+		assert.True(t, delay > time.Second && delay <= 2*time.Second, "unexpected delay: %s", delay)
+	}
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         resource.RetryPolicy
+		expectedErrMsg string
+	}{
+		{
+			name:   "valid",
+			policy: resource.RetryPolicy{MaxAttempts: 5, BackoffBase: time.Second, BackoffCap: time.Minute, Jitter: 0.2},
+		},
+		{
+			name:   "only max duration limited",
+			policy: resource.RetryPolicy{MaxDuration: time.Minute},
+		},
+		{
+			name:           "unlimited",
+			policy:         resource.RetryPolicy{BackoffBase: time.Second},
+			expectedErrMsg: "either max attempts or max duration of retries must be limited",
+		},
+		{
+			name:           "negative max attempts",
+			policy:         resource.RetryPolicy{MaxAttempts: -1},
+			expectedErrMsg: "max attempts must not be negative: -1",
+		},
+		{
+			name:           "cap less than base",
+			policy:         resource.RetryPolicy{MaxAttempts: 5, BackoffBase: time.Minute, BackoffCap: time.Second},
+			expectedErrMsg: "backoff cap (1s) must not be less than backoff base (1m0s)",
+		},
+		{
+			name:           "jitter out of range",
+			policy:         resource.RetryPolicy{MaxAttempts: 5, Jitter: 1.5},
+			expectedErrMsg: "jitter must be between 0 and 1: 1.5",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.policy.Validate()
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestDestroyResources_RetryPolicy(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	tests := []struct {
+		name                 string
+		policy               resource.RetryPolicy
+		numOfFailedDeletions int
+		expectedDestroyed    bool
+		expectedAttempts     int
+	}{
+		{
+			name:                 "retried without progress of other resources",
+			policy:               resource.RetryPolicy{MaxAttempts: 5, BackoffBase: time.Millisecond},
+			numOfFailedDeletions: 3,
+			expectedDestroyed:    true,
+			expectedAttempts:     4,
+		},
+		{
+			name:                 "max attempts exceeded",
+			policy:               resource.RetryPolicy{MaxAttempts: 3, BackoffBase: time.Millisecond},
+			numOfFailedDeletions: 5,
+			expectedAttempts:     3,
+		},
+		{
+			name:                 "max duration exceeded",
+			policy:               resource.RetryPolicy{BackoffBase: time.Hour, MaxDuration: time.Minute},
+			numOfFailedDeletions: 5,
+			expectedAttempts:     1,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+
+			m := NewMockDestroyableResource(ctrl)
+
+			resFailedDeletions := m.EXPECT().Destroy().
+				Return(resource.NewRetryDestroyError(fmt.Errorf("some error"), m)).
+				MaxTimes(tc.numOfFailedDeletions)
+
+			m.EXPECT().Destroy().Return(nil).After(resFailedDeletions).AnyTimes()
+
+			m.EXPECT().ID().Return("1234").AnyTimes()
+			m.EXPECT().Type().Return("aws_iam_role").AnyTimes()
+
+			actualReport := resource.DestroyResources(context.Background(), []resource.DestroyableResource{m}, 3,
+				resource.WithRetryPolicy(tc.policy))
+
+			require.Len(t, actualReport.Results, 1)
+			assert.Equal(t, tc.expectedDestroyed, actualReport.Results[0].Destroyed)
+			assert.Equal(t, tc.expectedAttempts, actualReport.Results[0].Attempts)
+
+			ctrl.Finish()
+		})
+	}
+}
+
+func TestDestroyResources_RetryPolicy_Cancelled(t *testing.T) {
+	log.SetLevel(log.DebugLevel)
+
+	ctrl := gomock.NewController(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := NewMockDestroyableResource(ctrl)
+
+	// cancel while waiting for the retry
+	m.EXPECT().Destroy().Do(cancel).Return(resource.NewRetryDestroyError(fmt.Errorf("some error"), m)).Times(1)
+
+	m.EXPECT().ID().Return("1234").AnyTimes()
+	m.EXPECT().Type().Return("aws_iam_role").AnyTimes()
+
+	actualReport := resource.DestroyResources(ctx, []resource.DestroyableResource{m}, 1,
+		resource.WithRetryPolicy(resource.RetryPolicy{MaxAttempts: 5, BackoffBase: time.Hour}))
+
+	assert.True(t, actualReport.Cancelled)
+	require.Len(t, actualReport.Failed(), 1)
+	assert.Equal(t, 1, actualReport.Failed()[0].Attempts)
+
+	ctrl.Finish()
+}
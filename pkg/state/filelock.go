@@ -0,0 +1,182 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/states/statemgr"
+)
+
+// fileLocker locks a state file like Terraform's local backend does, i.e., via a lock on the state file
+// and a lock info file (e.g., .terraform.tfstate.lock.info) next to it.
+//
+// The lock is a POSIX lock held on the file descriptor of the state file, which is released as soon as any other
+// file descriptor of the state file is closed by the same process. Therefore, the locked state file must only be
+// read and written via the locked file (see read and write).
+//
+// copied (and modified) from github.com/hashicorp/terraform/states/statemgr/filesystem.go
+type fileLocker struct {
+	path string
+
+	mu     sync.Mutex
+	file   *os.File
+	lockID string
+}
+
+// Lock acquires the lock of the state file.
+func (l *fileLocker) Lock(info *statemgr.LockInfo) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lockID != "" {
+		return "", fmt.Errorf("state %q already locked", l.path)
+	}
+
+	if l.file == nil {
+		f, err := os.OpenFile(l.path, os.O_RDWR, 0)
+		if err != nil {
+			return "", err
+		}
+
+		l.file = f
+	}
+
+	err := lockFile(l.file)
+	if err != nil {
+		lockErr := &statemgr.LockError{Err: err}
+
+		lockErr.Info, _ = l.lockInfo()
+
+		return "", lockErr
+	}
+
+	info.Path = l.path
+	info.Created = time.Now().UTC()
+
+	err = ioutil.WriteFile(l.lockInfoPath(), info.Marshal(), 0600)
+	if err != nil {
+		_ = unlockFile(l.file)
+
+		return "", fmt.Errorf("could not write lock info for %q: %s", l.path, err)
+	}
+
+	l.lockID = info.ID
+
+	return l.lockID, nil
+}
+
+// Unlock releases the lock of the state file acquired with the given lock ID.
+func (l *fileLocker) Unlock(id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lockID == "" {
+		return fmt.Errorf("state is not locked")
+	}
+
+	if id != l.lockID {
+		lockErr := &statemgr.LockError{Err: fmt.Errorf("invalid lock id: %q. current id: %q", id, l.lockID)}
+
+		lockErr.Info, _ = l.lockInfo()
+
+		return lockErr
+	}
+
+	_ = os.Remove(l.lockInfoPath())
+
+	err := unlockFile(l.file)
+
+	_ = l.file.Close()
+	l.file = nil
+	l.lockID = ""
+
+	return err
+}
+
+// read returns the content of the state file, read via the locked file. Returns false if the state file
+// is not locked.
+func (l *fileLocker) read() ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lockID == "" {
+		return nil, false, nil
+	}
+
+	_, err := l.file.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, true, err
+	}
+
+	content, err := ioutil.ReadAll(l.file)
+
+	return content, true, err
+}
+
+// write replaces the content of the state file in place via the locked file. Returns false if the state file
+// is not locked.
+func (l *fileLocker) write(content []byte) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.lockID == "" {
+		return false, nil
+	}
+
+	return true, writeFile(l.file, content)
+}
+
+// writeFile replaces the content of the given file in place, like Terraform's local backend does. Replacing the file
+// instead (e.g., by renaming a new file over it) would leave a lock held on the file behind with the old one.
+func writeFile(f *os.File, content []byte) error {
+	_, err := f.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+
+	err = f.Truncate(0)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(content)
+	if err != nil {
+		return err
+	}
+
+	return f.Sync()
+}
+
+// lockInfo returns the content of the lock info file.
+func (l *fileLocker) lockInfo() (*statemgr.LockInfo, error) {
+	content, err := ioutil.ReadFile(l.lockInfoPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var info statemgr.LockInfo
+
+	err = json.Unmarshal(content, &info)
+	if err != nil {
+		return nil, fmt.Errorf("state file %q locked, but could not unmarshal lock info: %s", l.path, err)
+	}
+
+	return &info, nil
+}
+
+// lockInfoPath returns the path of the lock info file (e.g., .terraform.tfstate.lock.info).
+func (l *fileLocker) lockInfoPath() string {
+	dir, name := filepath.Split(l.path)
+
+	if name[0] == '.' {
+		name = name[1:]
+	}
+
+	return filepath.Join(dir, fmt.Sprintf(".%s.lock.info", name))
+}
@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package state
+
+import (
+	"io"
+	"os"
+	"syscall"
+)
+
+// copied (and modified) from github.com/hashicorp/terraform/states/statemgr/filesystem_lock_unix.go
+
+// lockFile acquires a POSIX lock on the whole file, like Terraform does, for the most consistent
+// behaviour across platforms (and some compatibility over NFS and CIFS).
+func lockFile(f *os.File) error {
+	flock := &syscall.Flock_t{
+		Type:   syscall.F_RDLCK | syscall.F_WRLCK,
+		Whence: int16(io.SeekStart),
+		Start:  0,
+		Len:    0,
+	}
+
+	return syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, flock)
+}
+
+func unlockFile(f *os.File) error {
+	flock := &syscall.Flock_t{
+		Type:   syscall.F_UNLCK,
+		Whence: int16(io.SeekStart),
+		Start:  0,
+		Len:    0,
+	}
+
+	return syscall.FcntlFlock(f.Fd(), syscall.F_SETLK, flock)
+}
@@ -0,0 +1,118 @@
+//go:build windows
+// +build windows
+
+package state
+
+import (
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// copied (and modified) from github.com/hashicorp/terraform/states/statemgr/filesystem_lock_windows.go
+
+//nolint:gochecknoglobals
+var (
+	modkernel32      = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = modkernel32.NewProc("LockFileEx")
+	procCreateEventW = modkernel32.NewProc("CreateEventW")
+)
+
+const (
+	// dwFlags defined for LockFileEx
+	// https://msdn.microsoft.com/en-us/library/windows/desktop/aa365203(v=vs.85).aspx
+	lockfileFailImmediately = 1
+	lockfileExclusiveLock   = 2
+)
+
+// lockFile acquires an exclusive lock on the whole file, like Terraform does.
+func lockFile(f *os.File) error {
+	// even though we're failing immediately, an overlapped event structure is required
+	ol, err := newOverlapped()
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(ol.HEvent)
+
+	return lockFileEx(
+		syscall.Handle(f.Fd()),
+		lockfileExclusiveLock|lockfileFailImmediately,
+		0,              // reserved
+		0,              // bytes low
+		math.MaxUint32, // bytes high
+		ol,
+	)
+}
+
+// unlockFile does nothing, as the lock is released by closing the file.
+func unlockFile(f *os.File) error {
+	return nil
+}
+
+func lockFileEx(h syscall.Handle, flags, reserved, locklow, lockhigh uint32, ol *syscall.Overlapped) error {
+	r1, _, e1 := syscall.Syscall6(
+		procLockFileEx.Addr(),
+		6,
+		uintptr(h),
+		uintptr(flags),
+		uintptr(reserved),
+		uintptr(locklow),
+		uintptr(lockhigh),
+		uintptr(unsafe.Pointer(ol)),
+	)
+	if r1 == 0 {
+		if e1 != 0 {
+			return error(e1)
+		}
+
+		return syscall.EINVAL
+	}
+
+	return nil
+}
+
+// newOverlapped creates a structure used to track asynchronous I/O requests that have been issued.
+func newOverlapped() (*syscall.Overlapped, error) {
+	event, err := createEvent(nil, true, false, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syscall.Overlapped{HEvent: event}, nil
+}
+
+func createEvent(sa *syscall.SecurityAttributes, manualReset bool, initialState bool,
+	name *uint16) (syscall.Handle, error) {
+	var p0 uint32
+	if manualReset {
+		p0 = 1
+	}
+
+	var p1 uint32
+	if initialState {
+		p1 = 1
+	}
+
+	r0, _, e1 := syscall.Syscall6(
+		procCreateEventW.Addr(),
+		4,
+		uintptr(unsafe.Pointer(sa)),
+		uintptr(p0),
+		uintptr(p1),
+		uintptr(unsafe.Pointer(name)),
+		0,
+		0,
+	)
+
+	handle := syscall.Handle(r0)
+	if handle == syscall.InvalidHandle {
+		if e1 != 0 {
+			return handle, error(e1)
+		}
+
+		return handle, syscall.EINVAL
+	}
+
+	return handle, nil
+}
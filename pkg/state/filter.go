@@ -0,0 +1,74 @@
+package state
+
+import (
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/jckuester/terradozer/internal"
+)
+
+// Filter selects resource instances of a state by glob patterns, in which "*" matches any sequence
+// of characters and "?" matches any single character. All other characters (including brackets of
+// instance keys, such as aws_instance.web[0]) match literally.
+//
+// A resource instance is selected if it matches at least one include pattern of each kind of pattern
+// given (address, type, module) and doesn't match any exclude pattern. An empty filter selects everything.
+type Filter struct {
+	// IncludeAddresses and ExcludeAddresses match the absolute address of a resource instance
+	// (e.g., module.app.aws_s3_bucket.logs[0]).
+	IncludeAddresses []string
+	ExcludeAddresses []string
+	// IncludeTypes and ExcludeTypes match the resource type (e.g., aws_instance).
+	IncludeTypes []string
+	ExcludeTypes []string
+	// IncludeModules and ExcludeModules match the path of the module a resource instance belongs to
+	// (e.g., module.app). Resource instances of nested modules match the patterns of their parent modules as well.
+	IncludeModules []string
+	ExcludeModules []string
+}
+
+// Option configures a state.
+type Option func(*State)
+
+// WithFilter restricts the resources returned by a state to the ones selected by the given filter.
+func WithFilter(f Filter) Option {
+	return func(s *State) {
+		s.filter = f
+	}
+}
+
+// Matches returns true if the resource instance with the given address is selected by the filter.
+func (f Filter) Matches(addr addrs.AbsResourceInstance) bool {
+	address := addr.String()
+	rType := addr.Resource.Resource.Type
+	modules := modulePaths(addr.Module)
+
+	if internal.MatchesAnyGlob(f.ExcludeAddresses, address) || internal.MatchesAnyGlob(f.ExcludeTypes, rType) ||
+		internal.MatchesAnyGlob(f.ExcludeModules, modules...) {
+		return false
+	}
+
+	if len(f.IncludeAddresses) > 0 && !internal.MatchesAnyGlob(f.IncludeAddresses, address) {
+		return false
+	}
+
+	if len(f.IncludeTypes) > 0 && !internal.MatchesAnyGlob(f.IncludeTypes, rType) {
+		return false
+	}
+
+	if len(f.IncludeModules) > 0 && !internal.MatchesAnyGlob(f.IncludeModules, modules...) {
+		return false
+	}
+
+	return true
+}
+
+// modulePaths returns the path of the given module and of all its parent modules
+// (e.g., module.a.module.b and module.a). The root module has no path.
+func modulePaths(module addrs.ModuleInstance) []string {
+	var result []string
+
+	for i := len(module); i > 0; i-- {
+		result = append(result, module[:i].String())
+	}
+
+	return result
+}
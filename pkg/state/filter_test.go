@@ -0,0 +1,111 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilter_Matches(t *testing.T) {
+	addresses := []string{
+		"aws_vpc.test",
+		"aws_instance.web[0]",
+		"aws_instance.web[1]",
+		`aws_s3_bucket.logs["eu"]`,
+		"module.preview_env.aws_instance.web",
+		"module.preview_env.module.db.aws_db_instance.main",
+		"module.prod.aws_instance.web",
+	}
+
+	tests := []struct {
+		name              string
+		filter            state.Filter
+		expectedAddresses []string
+	}{
+		{
+			name:              "empty filter",
+			expectedAddresses: addresses,
+		},
+		{
+			name:   "include address with wildcard",
+			filter: state.Filter{IncludeAddresses: []string{"aws_instance.*"}},
+			expectedAddresses: []string{
+				"aws_instance.web[0]",
+				"aws_instance.web[1]",
+			},
+		},
+		{
+			name:              "include address with instance key",
+			filter:            state.Filter{IncludeAddresses: []string{"aws_instance.web[1]", `aws_s3_bucket.logs["eu"]`}},
+			expectedAddresses: []string{"aws_instance.web[1]", `aws_s3_bucket.logs["eu"]`},
+		},
+		{
+			name:   "include type",
+			filter: state.Filter{IncludeTypes: []string{"aws_instance"}},
+			expectedAddresses: []string{
+				"aws_instance.web[0]",
+				"aws_instance.web[1]",
+				"module.preview_env.aws_instance.web",
+				"module.prod.aws_instance.web",
+			},
+		},
+		{
+			name:   "include module with nested modules",
+			filter: state.Filter{IncludeModules: []string{"module.preview_env"}},
+			expectedAddresses: []string{
+				"module.preview_env.aws_instance.web",
+				"module.preview_env.module.db.aws_db_instance.main",
+			},
+		},
+		{
+			name: "include module and type",
+			filter: state.Filter{
+				IncludeModules: []string{"module.preview_env"},
+				IncludeTypes:   []string{"aws_instance"},
+			},
+			expectedAddresses: []string{"module.preview_env.aws_instance.web"},
+		},
+		{
+			name: "exclude takes precedence over include",
+			filter: state.Filter{
+				IncludeTypes:   []string{"aws_*"},
+				ExcludeModules: []string{"module.prod"},
+				ExcludeTypes:   []string{"aws_vpc"},
+			},
+			expectedAddresses: []string{
+				"aws_instance.web[0]",
+				"aws_instance.web[1]",
+				`aws_s3_bucket.logs["eu"]`,
+				"module.preview_env.aws_instance.web",
+				"module.preview_env.module.db.aws_db_instance.main",
+			},
+		},
+		{
+			name:   "exclude address",
+			filter: state.Filter{ExcludeAddresses: []string{"module.*", "aws_instance.web[?]"}},
+			expectedAddresses: []string{
+				"aws_vpc.test",
+				`aws_s3_bucket.logs["eu"]`,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var actualAddresses []string
+
+			for _, address := range addresses {
+				addr, diags := addrs.ParseAbsResourceInstanceStr(address)
+				require.False(t, diags.HasErrors())
+
+				if tc.filter.Matches(addr) {
+					actualAddresses = append(actualAddresses, address)
+				}
+			}
+
+			assert.Equal(t, tc.expectedAddresses, actualAddresses)
+		})
+	}
+}
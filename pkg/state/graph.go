@@ -0,0 +1,144 @@
+package state
+
+import (
+	"sort"
+
+	"github.com/apex/log"
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/dag"
+	"github.com/hashicorp/terraform/states"
+	"github.com/jckuester/terradozer/internal"
+)
+
+// DependencyGraph is a directed acyclic graph of the managed resource instances in a state.
+// Each resource instance has an edge to every resource instance it depends on.
+type DependencyGraph struct {
+	graph dag.AcyclicGraph
+}
+
+// DependencyGraph builds a graph from the dependencies recorded for each resource instance in the state
+// (i.e., the "dependencies" and deprecated "depends_on" attributes of a Terraform state version 4).
+//
+// If the state doesn't record any dependencies at all (e.g., state version 3), the graph is empty.
+// Resource instances that are part of a dependency cycle are left out of the graph.
+func (s *State) DependencyGraph() *DependencyGraph {
+	g := &DependencyGraph{}
+
+	if !hasDependencyInfo(s.state) {
+		log.Debug(internal.Pad("no dependency information found in state"))
+
+		return g
+	}
+
+	resAddrs := lookupAllResourceInstanceAddrs(s.state)
+
+	for _, resAddr := range resAddrs {
+		if resAddr.Resource.Resource.Mode != addrs.ManagedResourceMode {
+			continue
+		}
+
+		g.graph.Add(resAddr.String())
+	}
+
+	for _, resAddr := range resAddrs {
+		if !g.graph.HasVertex(resAddr.String()) {
+			continue
+		}
+
+		for _, depAddr := range dependenciesOf(s.state, resAddr) {
+			if !g.graph.HasVertex(depAddr.String()) || depAddr.String() == resAddr.String() {
+				continue
+			}
+
+			g.graph.Connect(dag.BasicEdge(resAddr.String(), depAddr.String()))
+		}
+	}
+
+	for _, cycle := range g.graph.Cycles() {
+		for _, v := range cycle {
+			log.WithField("address", v).Debug(internal.Pad("ignoring dependencies of resource in cycle"))
+
+			g.graph.Remove(v)
+		}
+	}
+
+	return g
+}
+
+// Dependencies returns the addresses of all resource instances the resource instance
+// with the given address depends on. The result ok is false if there is no dependency information
+// about the given resource instance.
+func (g *DependencyGraph) Dependencies(address string) ([]string, bool) {
+	// note: HasVertex panics on a graph without any vertices
+	if len(g.graph.Vertices()) == 0 || !g.graph.HasVertex(address) {
+		return nil, false
+	}
+
+	result := []string{}
+
+	for _, v := range g.graph.DownEdges(address).List() {
+		result = append(result, v.(string))
+	}
+
+	sort.Strings(result)
+
+	return result, true
+}
+
+// hasDependencyInfo returns true if any resource instance in the state records its dependencies.
+//
+// Terraform omits the dependencies of a resource instance without any, so it is only possible to tell
+// that a resource instance has no dependencies if others in the same state have some.
+func hasDependencyInfo(state *states.State) bool {
+	for _, resAddr := range lookupAllResourceInstanceAddrs(state) {
+		resInstance := state.ResourceInstance(resAddr)
+
+		if !resInstance.HasCurrent() {
+			continue
+		}
+
+		if len(resInstance.Current.Dependencies) > 0 || len(resInstance.Current.DependsOn) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dependenciesOf returns the addresses of all resource instances in the state
+// the resource instance with the given address depends on.
+func dependenciesOf(state *states.State, resAddr addrs.AbsResourceInstance) []addrs.AbsResourceInstance {
+	var result []addrs.AbsResourceInstance
+
+	resInstance := state.ResourceInstance(resAddr)
+
+	if !resInstance.HasCurrent() {
+		return nil
+	}
+
+	for _, dep := range resInstance.Current.Dependencies {
+		result = append(result, instancesOf(state, dep)...)
+	}
+
+	// deprecated depends_on attribute contains references relative to the module of the resource instance
+	for _, ref := range resInstance.Current.DependsOn {
+		switch dep := ref.(type) {
+		case addrs.Resource:
+			result = append(result, instancesOf(state, dep.Absolute(resAddr.Module))...)
+		case addrs.ResourceInstance:
+			result = append(result, dep.Absolute(resAddr.Module))
+		}
+	}
+
+	return result
+}
+
+// instancesOf returns the addresses of all instances of a resource in the state.
+func instancesOf(state *states.State, addr addrs.AbsResource) []addrs.AbsResourceInstance {
+	rs := state.Resource(addr)
+	if rs == nil {
+		return nil
+	}
+
+	return collectResourceInstances(addr.Module, rs)
+}
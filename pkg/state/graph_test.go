@@ -0,0 +1,106 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState_DependencyGraph(t *testing.T) {
+	type dependencies struct {
+		addresses []string
+		ok        bool
+	}
+
+	tests := []struct {
+		name                 string
+		pathToState          string
+		expectedDependencies map[string]dependencies
+	}{
+		{
+			name:        "state version 3 without dependency information",
+			pathToState: "../../test/test-fixtures/tfstates/version3.tfstate",
+			expectedDependencies: map[string]dependencies{
+				"aws_vpc.test": {},
+			},
+		},
+		{
+			name:        "state version 4 with dependencies",
+			pathToState: "../../test/test-fixtures/tfstates/dependencies.tfstate",
+			expectedDependencies: map[string]dependencies{
+				"aws_vpc.test": {
+					addresses: []string{},
+					ok:        true,
+				},
+				"aws_subnet.test[0]": {
+					addresses: []string{"aws_vpc.test"},
+					ok:        true,
+				},
+				"aws_subnet.test[1]": {
+					addresses: []string{"aws_vpc.test"},
+					ok:        true,
+				},
+				"aws_instance.test": {
+					addresses: []string{"aws_subnet.test[0]", "aws_subnet.test[1]"},
+					ok:        true,
+				},
+				"module.app.aws_security_group.test": {
+					addresses: []string{"aws_vpc.test"},
+					ok:        true,
+				},
+				"data.aws_ami.ubuntu": {},
+			},
+		},
+		{
+			name:        "state of Terraform v1.x",
+			pathToState: "../../test/test-fixtures/tfstates/terraform-1.x.tfstate",
+			expectedDependencies: map[string]dependencies{
+				"aws_vpc.test": {
+					addresses: []string{},
+					ok:        true,
+				},
+				"aws_subnet.test[0]": {
+					addresses: []string{"aws_vpc.test"},
+					ok:        true,
+				},
+				"aws_db_instance.test": {
+					addresses: []string{"aws_subnet.test[0]", "aws_subnet.test[1]", "random_password.db"},
+					ok:        true,
+				},
+				"module.app.aws_security_group.test": {
+					addresses: []string{"aws_vpc.test"},
+					ok:        true,
+				},
+			},
+		},
+		{
+			name:        "dependency cycle",
+			pathToState: "../../test/test-fixtures/tfstates/dependency-cycle.tfstate",
+			expectedDependencies: map[string]dependencies{
+				"aws_security_group.a": {},
+				"aws_security_group.b": {},
+				"aws_subnet.test": {
+					addresses: []string{"aws_vpc.test"},
+					ok:        true,
+				},
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := state.New(tc.pathToState)
+			require.NoError(t, err)
+
+			graph := s.DependencyGraph()
+
+			for address, expected := range tc.expectedDependencies {
+				actualAddresses, ok := graph.Dependencies(address)
+
+				assert.Equal(t, expected.ok, ok, address)
+				assert.Equal(t, expected.addresses, actualAddresses, address)
+			}
+		})
+	}
+}
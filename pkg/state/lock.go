@@ -0,0 +1,241 @@
+package state
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/hashicorp/terraform/states/statemgr"
+	"github.com/jckuester/terradozer/internal"
+)
+
+// LockableSource is a source of a state file that can be locked the same way Terraform does,
+// so that no one else (e.g., a terraform apply) can modify the state while it is locked.
+type LockableSource interface {
+	Source
+	statemgr.Locker
+}
+
+// Lock acquires the lock of the state file of the given source, retrying until the lock is acquired,
+// the timeout is exceeded, or the context is cancelled. Sources that cannot be locked are not locked.
+//
+// Returns a function to release the lock.
+func Lock(ctx context.Context, src Source, timeout time.Duration) (func() error, error) {
+	locker, ok := src.(LockableSource)
+	if !ok {
+		log.WithField("state", src.String()).Debug(internal.Pad("state cannot be locked"))
+
+		return func() error { return nil }, nil
+	}
+
+	info := statemgr.NewLockInfo()
+	info.Operation = "terradozer"
+	info.Info = "destroying resources of the state"
+
+	lockCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	id, err := statemgr.LockWithContext(lockCtx, locker, info)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock state (%s): %s", src, err)
+	}
+
+	log.WithField("state", src.String()).Debug(internal.Pad("locked state"))
+
+	return func() error {
+		err := locker.Unlock(id)
+		if err != nil {
+			return fmt.Errorf("failed to unlock state (%s): %s", src, err)
+		}
+
+		log.WithField("state", src.String()).Debug(internal.Pad("unlocked state"))
+
+		return nil
+	}, nil
+}
+
+// Lock locks the state file like Terraform's local backend does, i.e., via a POSIX lock on
+// the state file and a lock info file (e.g., .terraform.tfstate.lock.info) next to it.
+func (s *FileSource) Lock(info *statemgr.LockInfo) (string, error) {
+	if s.locker == nil {
+		s.locker = &fileLocker{path: s.path}
+	}
+
+	return s.locker.Lock(info)
+}
+
+// Unlock releases the lock of the state file.
+func (s *FileSource) Unlock(id string) error {
+	if s.locker == nil {
+		return fmt.Errorf("state is not locked")
+	}
+
+	return s.locker.Unlock(id)
+}
+
+// SetLockTable sets the DynamoDB table to lock the state file with, which is the table configured
+// as dynamodb_table of Terraform's S3 backend. If client is nil, a client is created from
+// the default AWS credential chain for the region the bucket lives in.
+func (s *S3Source) SetLockTable(table string, client dynamodbiface.DynamoDBAPI) {
+	s.LockTable = table
+	s.lockClient = client
+}
+
+// Lock locks the state file like Terraform's S3 backend does, i.e., via an item in the DynamoDB lock table.
+// If no lock table is set, the state file is not locked.
+func (s *S3Source) Lock(info *statemgr.LockInfo) (string, error) {
+	if s.LockTable == "" {
+		log.WithField("state", s.String()).Warn(internal.Pad("state is not locked (no DynamoDB lock table given)"))
+
+		return info.ID, nil
+	}
+
+	client, err := s.lockTableClient()
+	if err != nil {
+		return "", err
+	}
+
+	info.Path = s.lockPath()
+
+	_, err = client.PutItem(&dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(s.lockPath())},
+			"Info":   {S: aws.String(string(info.Marshal()))},
+		},
+		TableName:           aws.String(s.LockTable),
+		ConditionExpression: aws.String("attribute_not_exists(LockID)"),
+	})
+	if err != nil {
+		lockInfo, infoErr := s.lockInfo()
+		if infoErr != nil {
+			return "", fmt.Errorf("%s (failed to get lock info: %s)", err, infoErr)
+		}
+
+		return "", &statemgr.LockError{Err: err, Info: lockInfo}
+	}
+
+	return info.ID, nil
+}
+
+// Unlock releases the lock of the state file, if it is held with the given ID.
+func (s *S3Source) Unlock(id string) error {
+	if s.LockTable == "" {
+		return nil
+	}
+
+	client, err := s.lockTableClient()
+	if err != nil {
+		return err
+	}
+
+	lockInfo, err := s.lockInfo()
+	if err != nil {
+		return fmt.Errorf("failed to get lock info: %s", err)
+	}
+
+	if lockInfo.ID != id {
+		return &statemgr.LockError{
+			Err:  fmt.Errorf("lock ID %q does not match existing lock", id),
+			Info: lockInfo,
+		}
+	}
+
+	_, err = client.DeleteItem(&dynamodb.DeleteItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(s.lockPath())},
+		},
+		TableName: aws.String(s.LockTable),
+	})
+
+	return err
+}
+
+// lockPath is the ID of the item in the lock table that locks the state file.
+func (s *S3Source) lockPath() string {
+	return fmt.Sprintf("%s/%s", s.Bucket, s.Key)
+}
+
+// lockInfo returns the info of the current lock of the state file.
+func (s *S3Source) lockInfo() (*statemgr.LockInfo, error) {
+	client, err := s.lockTableClient()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := client.GetItem(&dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(s.lockPath())},
+		},
+		ProjectionExpression: aws.String("LockID, Info"),
+		TableName:            aws.String(s.LockTable),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var infoData string
+	if v, ok := output.Item["Info"]; ok && v.S != nil {
+		infoData = *v.S
+	}
+
+	lockInfo := &statemgr.LockInfo{}
+
+	err = json.Unmarshal([]byte(infoData), lockInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	return lockInfo, nil
+}
+
+// putDigest stores the MD5 digest of the given state file content in the lock table, which Terraform's
+// S3 backend uses to check the consistency of the state file it reads.
+func (s *S3Source) putDigest(content []byte) error {
+	client, err := s.lockTableClient()
+	if err != nil {
+		return err
+	}
+
+	sum := md5.Sum(content)
+
+	_, err = client.PutItem(&dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"LockID": {S: aws.String(s.lockPath() + "-md5")},
+			"Digest": {S: aws.String(hex.EncodeToString(sum[:]))},
+		},
+		TableName: aws.String(s.LockTable),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update digest of state file in DynamoDB table (%s): %s", s.LockTable, err)
+	}
+
+	return nil
+}
+
+func (s *S3Source) lockTableClient() (dynamodbiface.DynamoDBAPI, error) {
+	if s.lockClient != nil {
+		return s.lockClient, nil
+	}
+
+	sess, err := newAWSSession()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := aws.NewConfig()
+	if s.region != "" {
+		cfg = cfg.WithRegion(s.region)
+	}
+
+	s.lockClient = dynamodb.New(sess, cfg)
+
+	return s.lockClient, nil
+}
@@ -0,0 +1,262 @@
+package state_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDynamoDB is a minimal DynamoDB-compatible server that supports the operations needed
+// to lock state files (PutItem, GetItem, DeleteItem) on a single table with hash key LockID.
+type fakeDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (f *fakeDynamoDB) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var input struct {
+		Item                map[string]*dynamodb.AttributeValue
+		Key                 map[string]*dynamodb.AttributeValue
+		ConditionExpression string
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&input)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.") {
+	case "PutItem":
+		lockID := aws.StringValue(input.Item["LockID"].S)
+
+		if _, exists := f.items[lockID]; exists && input.ConditionExpression == "attribute_not_exists(LockID)" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException",` +
+				`"message":"The conditional request failed"}`))
+
+			return
+		}
+
+		f.items[lockID] = input.Item
+
+		_, _ = w.Write([]byte(`{}`))
+	case "GetItem":
+		output := map[string]interface{}{}
+
+		if item, ok := f.items[aws.StringValue(input.Key["LockID"].S)]; ok {
+			output["Item"] = item
+		}
+
+		_ = json.NewEncoder(w).Encode(output)
+	case "DeleteItem":
+		delete(f.items, aws.StringValue(input.Key["LockID"].S))
+
+		_, _ = w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newFakeDynamoDBClient(t *testing.T, f *fakeDynamoDB) *dynamodb.DynamoDB {
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-east-1"),
+		MaxRetries:  aws.Int(0),
+	})
+	require.NoError(t, err)
+
+	return dynamodb.New(sess)
+}
+
+func TestLock_File(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terradozer")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/version4.tfstate")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "terraform.tfstate")
+	require.NoError(t, ioutil.WriteFile(path, original, 0600))
+
+	lockInfoPath := filepath.Join(dir, ".terraform.tfstate.lock.info")
+
+	unlock, err := state.Lock(context.Background(), state.NewFileSource(path), 0)
+	require.NoError(t, err)
+
+	assert.FileExists(t, lockInfoPath)
+
+	require.NoError(t, unlock())
+
+	assert.NoFileExists(t, lockInfoPath)
+	assert.FileExists(t, path)
+}
+
+func TestLock_File_HeldWhileReadingAndWriting(t *testing.T) {
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/version4.tfstate")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	require.NoError(t, ioutil.WriteFile(path, original, 0600))
+
+	src := state.NewFileSource(path)
+
+	unlock, err := state.Lock(context.Background(), src, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "locked by another process", tryLockInOtherProcess(t, path))
+
+	s, err := state.NewFromSource(src)
+	require.NoError(t, err)
+
+	assert.Equal(t, "locked by another process", tryLockInOtherProcess(t, path))
+
+	// backs up and writes the state file
+	_, err = s.Write()
+	require.NoError(t, err)
+
+	assert.Equal(t, "locked by another process", tryLockInOtherProcess(t, path))
+
+	require.NoError(t, unlock())
+
+	assert.Equal(t, "lock acquired", tryLockInOtherProcess(t, path))
+
+	written, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotEmpty(t, written)
+}
+
+// tryLockInOtherProcess tries to lock the state file under the given path in a process other than the one of
+// the test, as a POSIX lock doesn't exclude the process holding it (see TestLock_File_HelperProcess).
+func tryLockInOtherProcess(t *testing.T, path string) string {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestLock_File_HelperProcess$")
+	cmd.Env = append(os.Environ(), "TERRADOZER_LOCK_HELPER_STATE="+path)
+
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	for _, result := range []string{"lock acquired", "locked by another process"} {
+		if strings.Contains(string(out), result) {
+			return result
+		}
+	}
+
+	require.Fail(t, "unexpected output of helper process", string(out))
+
+	return ""
+}
+
+// TestLock_File_HelperProcess is run as the other process of tryLockInOtherProcess.
+func TestLock_File_HelperProcess(t *testing.T) {
+	path := os.Getenv("TERRADOZER_LOCK_HELPER_STATE")
+	if path == "" {
+		t.Skip("Only run as helper process.")
+	}
+
+	unlock, err := state.Lock(context.Background(), state.NewFileSource(path), 0)
+	if err != nil {
+		fmt.Println("locked by another process")
+		return
+	}
+
+	fmt.Println("lock acquired")
+
+	require.NoError(t, unlock())
+}
+
+func TestLock_S3(t *testing.T) {
+	fakeDB := &fakeDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	lockClient := newFakeDynamoDBClient(t, fakeDB)
+
+	s3Client := newFakeS3Client(t, map[string]map[string]string{})
+
+	newSource := func(lockTable string) *state.S3Source {
+		src, err := state.NewS3Source("s3://my-bucket/path/terraform.tfstate", s3Client)
+		require.NoError(t, err)
+
+		src.SetLockTable(lockTable, lockClient)
+
+		return src
+	}
+
+	unlock, err := state.Lock(context.Background(), newSource("locks"), 0)
+	require.NoError(t, err)
+
+	require.Contains(t, fakeDB.items, "my-bucket/path/terraform.tfstate")
+
+	var actualLockInfo struct {
+		Operation string
+		Path      string
+	}
+
+	require.NoError(t, json.Unmarshal(
+		[]byte(aws.StringValue(fakeDB.items["my-bucket/path/terraform.tfstate"]["Info"].S)), &actualLockInfo))
+	assert.Equal(t, "terradozer", actualLockInfo.Operation)
+	assert.Equal(t, "my-bucket/path/terraform.tfstate", actualLockInfo.Path)
+
+	// state is already locked
+	_, err = state.Lock(context.Background(), newSource("locks"), 10*time.Millisecond)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "ConditionalCheckFailedException")
+	assert.Contains(t, err.Error(), "Operation: terradozer")
+
+	// state is not locked without lock table
+	unlockWithoutTable, err := state.Lock(context.Background(), newSource(""), 0)
+	require.NoError(t, err)
+	require.NoError(t, unlockWithoutTable())
+
+	require.NoError(t, unlock())
+
+	assert.NotContains(t, fakeDB.items, "my-bucket/path/terraform.tfstate")
+}
+
+func TestState_Write_S3_UpdatesDigest(t *testing.T) {
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/dependencies.tfstate")
+	require.NoError(t, err)
+
+	fakeDB := &fakeDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+
+	src, err := state.NewS3Source("s3://my-bucket/terraform.tfstate", newFakeS3Client(t,
+		map[string]map[string]string{"my-bucket/terraform.tfstate": {"": string(original)}}))
+	require.NoError(t, err)
+
+	src.SetLockTable("locks", newFakeDynamoDBClient(t, fakeDB))
+
+	s, err := state.NewFromSource(src)
+	require.NoError(t, err)
+
+	_, err = s.Write()
+	require.NoError(t, err)
+
+	require.Contains(t, fakeDB.items, "my-bucket/terraform.tfstate-md5")
+	assert.Len(t, aws.StringValue(fakeDB.items["my-bucket/terraform.tfstate-md5"]["Digest"].S), 32)
+}
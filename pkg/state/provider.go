@@ -0,0 +1,247 @@
+package state
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/jckuester/terradozer/internal"
+	"github.com/jckuester/terradozer/pkg/plugin"
+	"github.com/jckuester/terradozer/pkg/resource"
+)
+
+// ProviderNames returns a list of all provider names (e.g., "aws", "google") in the state.
+// The result of provider names is deduplicated.
+func (s *State) ProviderNames() []string {
+	var providers []string
+
+	log.WithField("addresses", s.state.ProviderAddrs()).Debug(internal.Pad("providers found in state"))
+
+	for _, pAddr := range s.state.ProviderAddrs() {
+		providers = append(providers, pAddr.ProviderConfig.Type.LegacyString())
+	}
+
+	return removeDuplicates(providers)
+}
+
+// ProviderNamesOf returns a deduplicated list of all provider names (e.g., "aws", "google")
+// found in any of the given states.
+func ProviderNamesOf(states []*State) []string {
+	var providers []string
+
+	for _, s := range states {
+		providers = append(providers, s.ProviderNames()...)
+	}
+
+	return removeDuplicates(providers)
+}
+
+// Providers returns a list of all providers in the state with their source address
+// (e.g., registry.example.com/acme/widgets), but without version. Providers of states written
+// by Terraform v0.12 and earlier are of the default registry and namespace (e.g., registry.terraform.io/hashicorp/aws).
+//
+// As resources of a state are associated with their provider by name (e.g., "aws"), the same type of provider
+// from different sources within one state is not supported.
+func (s *State) Providers() ([]plugin.Provider, error) {
+	providerByName, err := s.providersByName()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []plugin.Provider
+
+	for _, name := range s.ProviderNames() {
+		result = append(result, providerByName[name])
+	}
+
+	return result, nil
+}
+
+// providersByName returns the providers in the state (see Providers) keyed by provider name.
+func (s *State) providersByName() (map[string]plugin.Provider, error) {
+	result := map[string]plugin.Provider{}
+
+	if s.raw != nil {
+		for _, rs := range s.raw.Resources {
+			pAddr, err := parseProviderAddrV4(rs.Provider)
+			if err != nil {
+				return nil, err
+			}
+
+			if pAddr.source == "" {
+				continue
+			}
+
+			p, err := plugin.ParseProvider(pAddr.source)
+			if err != nil {
+				return nil, err
+			}
+
+			// the legacy namespace of providers in states upgraded by Terraform v0.13
+			// (e.g., registry.terraform.io/-/aws)
+			if p.Namespace == "-" {
+				p.Namespace = plugin.DefaultNamespace
+			}
+
+			if other, ok := result[pAddr.typeName]; ok && other != p {
+				return nil, fmt.Errorf("providers of the same type from different sources are not supported "+
+					"within one state: %s, %s", other.Address(), p.Address())
+			}
+
+			result[pAddr.typeName] = p
+		}
+	}
+
+	for _, name := range s.ProviderNames() {
+		if _, ok := result[name]; !ok {
+			result[name] = plugin.NewProvider(name, "")
+		}
+	}
+
+	return result, nil
+}
+
+// ProviderOf returns the provider (see Providers) of the given provider configuration
+// (see ProviderConfigs) of the state.
+func (s *State) ProviderOf(providerConfig string) (plugin.Provider, error) {
+	_, name, err := ParseProviderConfig(providerConfig)
+	if err != nil {
+		return plugin.Provider{}, err
+	}
+
+	providerByName, err := s.providersByName()
+	if err != nil {
+		return plugin.Provider{}, err
+	}
+
+	p, ok := providerByName[name]
+	if !ok {
+		return plugin.Provider{}, fmt.Errorf("provider not found in state: %s", providerConfig)
+	}
+
+	return p, nil
+}
+
+// ProvidersOf returns a list of all providers (see Providers) found in any of the given states,
+// deduplicated by source address. The same type of provider from different sources can be used
+// by different states (e.g., registry.terraform.io/hashicorp/aws and registry.opentofu.org/hashicorp/aws).
+func ProvidersOf(states []*State) ([]plugin.Provider, error) {
+	var result []plugin.Provider
+
+	found := map[string]bool{}
+
+	for _, s := range states {
+		providers, err := s.Providers()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get providers of state (%s): %s", s.Location(), err)
+		}
+
+		for _, p := range providers {
+			if found[p.Address()] {
+				continue
+			}
+
+			found[p.Address()] = true
+			result = append(result, p)
+		}
+	}
+
+	return result, nil
+}
+
+// ProviderConfigs returns a deduplicated list of the addresses of all provider configurations in the state,
+// e.g., "aws" for the default AWS provider, "aws.us_east_1" for an aliased one, or "module.app.provider.aws"
+// for one of a module.
+func (s *State) ProviderConfigs() []string {
+	var providerConfigs []string
+
+	for _, pAddr := range s.state.ProviderAddrs() {
+		providerConfigs = append(providerConfigs, providerConfigString(pAddr))
+	}
+
+	return removeDuplicates(providerConfigs)
+}
+
+// ProviderConfigOf returns the address of the provider configuration (see ProviderConfigs) managing
+// the given resource, which must have been returned by Resources.
+func (s *State) ProviderConfigOf(r resource.DestroyableResource) (string, bool) {
+	resAddr, ok := s.resourceAddrs[r]
+	if !ok {
+		return "", false
+	}
+
+	rs := s.state.Resource(resAddr.ContainingResource())
+	if rs == nil {
+		return "", false
+	}
+
+	return providerConfigString(rs.ProviderConfig), true
+}
+
+// ProviderConfigsOf returns a deduplicated list of the addresses of all provider configurations
+// found in any of the given states.
+func ProviderConfigsOf(states []*State) []string {
+	var providerConfigs []string
+
+	for _, s := range states {
+		providerConfigs = append(providerConfigs, s.ProviderConfigs()...)
+	}
+
+	return removeDuplicates(providerConfigs)
+}
+
+// ParseProviderConfig parses the address of a provider configuration, which is either
+// in the form returned by ProviderConfigs or as written in a state file (e.g., provider.aws.us_east_1).
+//
+// Returns the address in the form returned by ProviderConfigs and the name of the provider (e.g., "aws").
+func ParseProviderConfig(address string) (string, string, error) {
+	pAddr, err := parseProviderConfig(address)
+	if err != nil {
+		return "", "", err
+	}
+
+	return providerConfigString(pAddr), pAddr.ProviderConfig.Type.LegacyString(), nil
+}
+
+// InheritedProviderConfig returns the address of the provider configuration that the given provider
+// configuration of a module inherits from, i.e., the same provider configuration of the parent module.
+// The result ok is false for provider configurations of the root module and aliased ones,
+// which are never inherited.
+func InheritedProviderConfig(address string) (string, bool) {
+	pAddr, err := parseProviderConfig(address)
+	if err != nil {
+		return "", false
+	}
+
+	parent, ok := pAddr.Inherited()
+	if !ok {
+		return "", false
+	}
+
+	return providerConfigString(parent), true
+}
+
+func parseProviderConfig(address string) (addrs.AbsProviderConfig, error) {
+	if !strings.HasPrefix(address, "provider.") && !strings.HasPrefix(address, "module.") {
+		address = "provider." + address
+	}
+
+	pAddr, diags := addrs.ParseAbsProviderConfigStr(address)
+	if diags.HasErrors() {
+		return addrs.AbsProviderConfig{}, fmt.Errorf("invalid provider configuration address (%s): %s",
+			address, diags.Err())
+	}
+
+	return pAddr, nil
+}
+
+// providerConfigString returns the address of a provider configuration in compact form for the root module
+// (e.g., aws.us_east_1) and in absolute form for other modules (e.g., module.app.provider.aws).
+func providerConfigString(pAddr addrs.AbsProviderConfig) string {
+	if pAddr.Module.IsRoot() {
+		return pAddr.ProviderConfig.StringCompact()
+	}
+
+	return pAddr.String()
+}
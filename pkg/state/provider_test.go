@@ -0,0 +1,326 @@
+package state_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/plugin"
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState_Providers(t *testing.T) {
+	tests := []struct {
+		name              string
+		pathToState       string
+		expectedProviders []plugin.Provider
+	}{
+		{
+			name:              "state version 4",
+			pathToState:       "../../test/test-fixtures/tfstates/version4.tfstate",
+			expectedProviders: []plugin.Provider{plugin.NewProvider("aws", "")},
+		},
+		{
+			name:        "empty state",
+			pathToState: "../../test/test-fixtures/tfstates/empty.tfstate",
+		},
+		{
+			name:        "state of Terraform v1.x",
+			pathToState: "../../test/test-fixtures/tfstates/terraform-1.x.tfstate",
+			expectedProviders: []plugin.Provider{
+				plugin.NewProvider("aws", ""),
+				plugin.NewProvider("random", ""),
+			},
+		},
+		{
+			name:        "state of OpenTofu",
+			pathToState: "../../test/test-fixtures/tfstates/opentofu.tfstate",
+			expectedProviders: []plugin.Provider{
+				{Hostname: "registry.opentofu.org", Namespace: "hashicorp", Type: "aws"},
+			},
+		},
+		{
+			name:              "legacy namespace",
+			pathToState:       "../../test/test-fixtures/tfstates/legacy-provider-namespace.tfstate",
+			expectedProviders: []plugin.Provider{plugin.NewProvider("aws", "")},
+		},
+		{
+			name:        "third-party provider",
+			pathToState: "../../test/test-fixtures/tfstates/fake-provider-third-party.tfstate",
+			expectedProviders: []plugin.Provider{
+				plugin.NewProvider("aws", ""),
+				{Hostname: "registry.example.com", Namespace: "acme", Type: "widgets"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := state.New(tc.pathToState)
+			require.NoError(t, err)
+
+			actualProviders, err := s.Providers()
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedProviders, actualProviders)
+		})
+	}
+}
+
+func TestProvidersOf(t *testing.T) {
+	tests := []struct {
+		name              string
+		pathsToStates     []string
+		expectedProviders []plugin.Provider
+		expectedErrMsg    string
+	}{
+		{
+			name: "same provider in multiple states",
+			pathsToStates: []string{
+				"../../test/test-fixtures/tfstates/version4.tfstate",
+				"../../test/test-fixtures/tfstates/fake-provider-third-party.tfstate",
+			},
+			expectedProviders: []plugin.Provider{
+				plugin.NewProvider("aws", ""),
+				{Hostname: "registry.example.com", Namespace: "acme", Type: "widgets"},
+			},
+		},
+		{
+			name: "same type of provider from different sources",
+			pathsToStates: []string{
+				"../../test/test-fixtures/tfstates/version4.tfstate",
+				"../../test/test-fixtures/tfstates/opentofu.tfstate",
+			},
+			expectedProviders: []plugin.Provider{
+				plugin.NewProvider("aws", ""),
+				{Hostname: "registry.opentofu.org", Namespace: "hashicorp", Type: "aws"},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var states []*state.State
+
+			for _, path := range tc.pathsToStates {
+				s, err := state.New(path)
+				require.NoError(t, err)
+
+				states = append(states, s)
+			}
+
+			actualProviders, err := state.ProvidersOf(states)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedProviders, actualProviders)
+		})
+	}
+}
+
+func TestState_ProviderOf(t *testing.T) {
+	tests := []struct {
+		name             string
+		providerConfig   string
+		expectedProvider plugin.Provider
+		expectedErrMsg   string
+	}{
+		{
+			name:             "provider of default registry",
+			providerConfig:   "aws",
+			expectedProvider: plugin.NewProvider("aws", ""),
+		},
+		{
+			name:             "third-party provider",
+			providerConfig:   "widgets",
+			expectedProvider: plugin.Provider{Hostname: "registry.example.com", Namespace: "acme", Type: "widgets"},
+		},
+		{
+			name:           "provider not in state",
+			providerConfig: "google",
+			expectedErrMsg: "provider not found in state: google",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := state.New("../../test/test-fixtures/tfstates/fake-provider-third-party.tfstate")
+			require.NoError(t, err)
+
+			actualProvider, err := s.ProviderOf(tc.providerConfig)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedProvider, actualProvider)
+		})
+	}
+}
+
+func TestState_ProviderConfigs(t *testing.T) {
+	tests := []struct {
+		name                    string
+		pathToState             string
+		expectedProviderConfigs []string
+	}{
+		{
+			name:                    "state version 3",
+			pathToState:             "../../test/test-fixtures/tfstates/version3.tfstate",
+			expectedProviderConfigs: []string{"aws"},
+		},
+		{
+			name:        "empty state",
+			pathToState: "../../test/test-fixtures/tfstates/empty.tfstate",
+		},
+		{
+			name:                    "multiple providers",
+			pathToState:             "../../test/test-fixtures/tfstates/multiple-providers.tfstate",
+			expectedProviderConfigs: []string{"aws", "random"},
+		},
+		{
+			name:                    "provider of module",
+			pathToState:             "../../test/test-fixtures/tfstates/duplicate-provider.tfstate",
+			expectedProviderConfigs: []string{"aws", "module.vpc.provider.aws"},
+		},
+		{
+			name:        "aliased providers",
+			pathToState: "../../test/test-fixtures/tfstates/aliased-providers.tfstate",
+			expectedProviderConfigs: []string{
+				"aws", "aws.us_east_1", "module.app.provider.aws.eu"},
+		},
+		{
+			name:                    "state of Terraform v0.13",
+			pathToState:             "../../test/test-fixtures/tfstates/terraform-0.13.tfstate",
+			expectedProviderConfigs: []string{"aws"},
+		},
+		{
+			name:        "state of Terraform v1.x",
+			pathToState: "../../test/test-fixtures/tfstates/terraform-1.x.tfstate",
+			expectedProviderConfigs: []string{
+				"aws", "aws.us_east_1", "module.app.provider.aws.eu", "random"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := state.New(tc.pathToState)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.expectedProviderConfigs, s.ProviderConfigs())
+		})
+	}
+}
+
+func TestProviderConfigsOf(t *testing.T) {
+	var states []*state.State
+
+	for _, path := range []string{
+		"../../test/test-fixtures/tfstates/version4.tfstate",
+		"../../test/test-fixtures/tfstates/aliased-providers.tfstate",
+	} {
+		s, err := state.New(path)
+		require.NoError(t, err)
+
+		states = append(states, s)
+	}
+
+	assert.ElementsMatch(t, []string{"aws", "aws.us_east_1", "module.app.provider.aws.eu"},
+		state.ProviderConfigsOf(states))
+}
+
+func TestParseProviderConfig(t *testing.T) {
+	tests := []struct {
+		name                   string
+		address                string
+		expectedProviderConfig string
+		expectedProviderName   string
+		expectedErr            bool
+	}{
+		{
+			name:                   "default provider",
+			address:                "aws",
+			expectedProviderConfig: "aws",
+			expectedProviderName:   "aws",
+		},
+		{
+			name:                   "aliased provider",
+			address:                "aws.us_east_1",
+			expectedProviderConfig: "aws.us_east_1",
+			expectedProviderName:   "aws",
+		},
+		{
+			name:                   "address as written in state",
+			address:                "provider.aws.us_east_1",
+			expectedProviderConfig: "aws.us_east_1",
+			expectedProviderName:   "aws",
+		},
+		{
+			name:                   "provider of module",
+			address:                "module.app.provider.aws.eu",
+			expectedProviderConfig: "module.app.provider.aws.eu",
+			expectedProviderName:   "aws",
+		},
+		{
+			name:        "invalid address",
+			address:     "aws.us-east-1.foo",
+			expectedErr: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualProviderConfig, actualProviderName, err := state.ParseProviderConfig(tc.address)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedProviderConfig, actualProviderConfig)
+			assert.Equal(t, tc.expectedProviderName, actualProviderName)
+		})
+	}
+}
+
+func TestInheritedProviderConfig(t *testing.T) {
+	tests := []struct {
+		name                   string
+		address                string
+		expectedProviderConfig string
+		expectedOk             bool
+	}{
+		{
+			name:                   "provider of module",
+			address:                "module.vpc.provider.aws",
+			expectedProviderConfig: "aws",
+			expectedOk:             true,
+		},
+		{
+			name:                   "provider of nested module",
+			address:                "module.app.module.vpc.provider.aws",
+			expectedProviderConfig: "module.app.provider.aws",
+			expectedOk:             true,
+		},
+		{
+			name:    "provider of root module",
+			address: "aws",
+		},
+		{
+			name:    "aliased provider of module",
+			address: "module.app.provider.aws.eu",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualProviderConfig, ok := state.InheritedProviderConfig(tc.address)
+
+			assert.Equal(t, tc.expectedOk, ok)
+			assert.Equal(t, tc.expectedProviderConfig, actualProviderConfig)
+		})
+	}
+}
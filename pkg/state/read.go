@@ -0,0 +1,186 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/states/statefile"
+)
+
+// stateV4 is a state file in format version 4, as written by Terraform v0.12 and later, as well as OpenTofu.
+// Instances of resources are kept as they are, so that attributes unknown to Terraform v0.12.31
+// (e.g., sensitive_attributes) are not lost when the state is written back (see Write).
+type stateV4 struct {
+	Version          uint64          `json:"version"`
+	TerraformVersion string          `json:"terraform_version"`
+	Serial           uint64          `json:"serial"`
+	Lineage          string          `json:"lineage"`
+	Outputs          json.RawMessage `json:"outputs"`
+	Resources        []resourceV4    `json:"resources"`
+	CheckResults     json.RawMessage `json:"check_results,omitempty"`
+}
+
+type resourceV4 struct {
+	Module    string            `json:"module,omitempty"`
+	Mode      string            `json:"mode"`
+	Type      string            `json:"type"`
+	Name      string            `json:"name"`
+	Each      string            `json:"each,omitempty"`
+	Provider  string            `json:"provider"`
+	Instances []json.RawMessage `json:"instances"`
+}
+
+// instanceKeyV4 are the fields that identify an object of a resource instance in a state.
+type instanceKeyV4 struct {
+	IndexKey interface{} `json:"index_key,omitempty"`
+	Deposed  string      `json:"deposed,omitempty"`
+}
+
+// readStateFile reads a state file written by any version of Terraform (or OpenTofu).
+//
+// The statefile package of Terraform v0.12.31 can't parse the provider addresses of states written by Terraform v0.13
+// and later, which include the source of the provider (e.g., provider["registry.terraform.io/hashicorp/aws"]).
+// These are converted into the legacy form (e.g., provider.aws) before the state is read by the statefile package;
+// the state as written is returned as well (otherwise nil), so that it can be written back in the same form.
+func readStateFile(content []byte) (*statefile.File, *stateV4, error) {
+	var sniff struct {
+		Version uint64 `json:"version"`
+	}
+
+	// let the statefile package report malformed JSON and states of other versions
+	if json.Unmarshal(content, &sniff) != nil || sniff.Version != 4 {
+		stateFile, err := statefile.Read(bytes.NewReader(content))
+
+		return stateFile, nil, err
+	}
+
+	var raw stateV4
+
+	err := json.Unmarshal(content, &raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	legacy := raw
+	legacy.Resources = make([]resourceV4, len(raw.Resources))
+
+	// the same type of provider from different sources can't be told apart in the legacy form
+	sourceByType := map[string]string{}
+	hasSources := false
+
+	for i, rs := range raw.Resources {
+		legacy.Resources[i] = rs
+
+		pAddr, err := parseProviderAddrV4(rs.Provider)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resource %s.%s: %s", rs.Type, rs.Name, err)
+		}
+
+		if pAddr.source == "" {
+			continue
+		}
+
+		hasSources = true
+
+		if source, ok := sourceByType[pAddr.typeName]; ok && source != pAddr.source {
+			return nil, nil, fmt.Errorf("providers of the same type from different sources are not supported: %s, %s",
+				source, pAddr.source)
+		}
+
+		sourceByType[pAddr.typeName] = pAddr.source
+		legacy.Resources[i].Provider = pAddr.legacyString()
+	}
+
+	if !hasSources {
+		stateFile, err := statefile.Read(bytes.NewReader(content))
+
+		return stateFile, nil, err
+	}
+
+	legacyContent, err := json.Marshal(legacy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stateFile, err := statefile.Read(bytes.NewReader(legacyContent))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return stateFile, &raw, nil
+}
+
+// providerAddrV4 is the address of a provider configuration as written in a state file.
+type providerAddrV4 struct {
+	// module is the path of the module (e.g., module.app), empty for the root module.
+	module string
+	// source is the source address of the provider (e.g., registry.terraform.io/hashicorp/aws),
+	// empty if the address is in the legacy form.
+	source   string
+	typeName string
+	alias    string
+}
+
+// parseProviderAddrV4 parses the address of a provider configuration either in the form of Terraform v0.13 and later
+// (e.g., module.app.provider["registry.terraform.io/hashicorp/aws"].eu) or in the legacy form
+// (e.g., module.app.provider.aws.eu).
+func parseProviderAddrV4(address string) (providerAddrV4, error) {
+	i := strings.Index(address, `provider["`)
+	if i < 0 {
+		return providerAddrV4{}, nil
+	}
+
+	var result providerAddrV4
+
+	if i > 0 {
+		if !strings.HasSuffix(address[:i], ".") {
+			return providerAddrV4{}, fmt.Errorf("invalid provider address: %s", address)
+		}
+
+		result.module = strings.TrimSuffix(address[:i], ".")
+	}
+
+	rest := address[i+len(`provider["`):]
+
+	end := strings.Index(rest, `"]`)
+	if end < 0 {
+		return providerAddrV4{}, fmt.Errorf("invalid provider address: %s", address)
+	}
+
+	result.source = rest[:end]
+
+	parts := strings.Split(result.source, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return providerAddrV4{}, fmt.Errorf("invalid provider source address: %s", result.source)
+	}
+
+	result.typeName = parts[2]
+
+	rest = rest[end+len(`"]`):]
+	if rest != "" {
+		if !strings.HasPrefix(rest, ".") || len(rest) == 1 {
+			return providerAddrV4{}, fmt.Errorf("invalid provider address: %s", address)
+		}
+
+		result.alias = rest[1:]
+	}
+
+	return result, nil
+}
+
+// legacyString returns the address in the legacy form of Terraform v0.12 (e.g., module.app.provider.aws.eu).
+func (a providerAddrV4) legacyString() string {
+	result := "provider." + a.typeName
+
+	if a.alias != "" {
+		result += "." + a.alias
+	}
+
+	if a.module != "" {
+		result = a.module + "." + result
+	}
+
+	return result
+}
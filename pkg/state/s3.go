@@ -0,0 +1,295 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/jckuester/terradozer/internal"
+)
+
+const (
+	s3Scheme = "s3://"
+	// defaultS3Region is used to look up the region of a bucket if no region is configured.
+	defaultS3Region = "us-east-1"
+)
+
+// S3Source is a Terraform state file stored as an object in an AWS S3 bucket.
+type S3Source struct {
+	Bucket string
+	Key    string
+	// VersionID is optional; if set, this specific version of a versioned object is read.
+	VersionID string
+	// LockTable is the DynamoDB table used to lock the state file (see SetLockTable).
+	LockTable string
+
+	client     s3iface.S3API
+	lockClient dynamodbiface.DynamoDBAPI
+	// region of the bucket; empty if unknown
+	region string
+	// serverSideEncryption and sseKMSKeyID are the encryption settings of the object as read (see Open),
+	// which are kept when the object is written back.
+	serverSideEncryption string
+	sseKMSKeyID          string
+}
+
+// NewS3Source creates a source from an S3 URL, i.e., s3://bucket/path/to/terraform.tfstate.
+// A specific version of the object can be selected via the versionId query parameter,
+// i.e., s3://bucket/path/to/terraform.tfstate?versionId=<id>.
+//
+// If client is nil, a client is created from the default AWS credential chain
+// (e.g., AWS_PROFILE) for the region the bucket lives in.
+func NewS3Source(location string, client s3iface.S3API) (*S3Source, error) {
+	u, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("S3 URL must contain a bucket and key (s3://bucket/key): %s", location)
+	}
+
+	var region string
+
+	if client == nil {
+		client, region, err = newS3Client(u.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &S3Source{
+		Bucket:    u.Host,
+		Key:       key,
+		VersionID: u.Query().Get("versionId"),
+		client:    client,
+		region:    region,
+	}, nil
+}
+
+// DiscoverS3Sources returns the sources of all Terraform state files (i.e., objects with key suffix .tfstate)
+// stored in a bucket under the prefix of the given S3 URL, i.e., s3://bucket/prefix/.
+//
+// If client is nil, a client is created from the default AWS credential chain
+// (e.g., AWS_PROFILE) for the region the bucket lives in.
+func DiscoverS3Sources(location string, client s3iface.S3API) ([]Source, error) {
+	u, err := parseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("S3 URL must contain a bucket (s3://bucket/prefix): %s", location)
+	}
+
+	var region string
+
+	if client == nil {
+		client, region, err = newS3Client(u.Host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var result []Source
+
+	err = client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(u.Host),
+		Prefix: aws.String(strings.TrimPrefix(u.Path, "/")),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			key := aws.StringValue(object.Key)
+
+			if !strings.HasSuffix(key, stateFileSuffix) {
+				continue
+			}
+
+			result = append(result, &S3Source{
+				Bucket: u.Host,
+				Key:    key,
+				client: client,
+				region: region,
+			})
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects in S3 (%s): %s", location, err)
+	}
+
+	return result, nil
+}
+
+// IsS3VersionLocation returns true if the given location is an S3 URL of a specific version of an object
+// (i.e., with the versionId query parameter), which can be read but not written to (see S3Source.Write).
+func IsS3VersionLocation(location string) bool {
+	u, err := parseS3URL(location)
+	if err != nil {
+		return false
+	}
+
+	return u.Query().Get("versionId") != ""
+}
+
+func parseS3URL(location string) (*url.URL, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse S3 URL: %s", err)
+	}
+
+	if u.Scheme != "s3" {
+		return nil, fmt.Errorf("not an S3 URL (expected s3://bucket/key): %s", location)
+	}
+
+	return u, nil
+}
+
+// newS3Client creates an S3 client for the region of the given bucket. Returns the client and the region.
+func newS3Client(bucket string) (s3iface.S3API, string, error) {
+	sess, err := newAWSSession()
+	if err != nil {
+		return nil, "", err
+	}
+
+	regionHint := aws.StringValue(sess.Config.Region)
+	if regionHint == "" {
+		regionHint = defaultS3Region
+	}
+
+	region, err := s3manager.GetBucketRegion(aws.BackgroundContext(), sess, bucket, regionHint)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get region of S3 bucket (%s): %s", bucket, err)
+	}
+
+	log.WithFields(log.Fields{
+		"bucket": bucket,
+		"region": region,
+	}).Debug(internal.Pad("looked up region of S3 bucket"))
+
+	return s3.New(sess, aws.NewConfig().WithRegion(region)), region, nil
+}
+
+// newAWSSession creates a session from the default AWS credential chain (e.g., AWS_PROFILE).
+func newAWSSession() (*session.Session, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %s", err)
+	}
+
+	return sess, nil
+}
+
+// Open fetches the state file object from S3.
+func (s *S3Source) Open() (io.ReadCloser, error) {
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.Key),
+	}
+
+	if s.VersionID != "" {
+		input.VersionId = aws.String(s.VersionID)
+	}
+
+	output, err := s.client.GetObject(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get object from S3 (%s): %s", s, err)
+	}
+
+	s.serverSideEncryption = aws.StringValue(output.ServerSideEncryption)
+	s.sseKMSKeyID = aws.StringValue(output.SSEKMSKeyId)
+
+	return output.Body, nil
+}
+
+// String returns the S3 URL of the state file.
+func (s *S3Source) String() string {
+	result := fmt.Sprintf("%s%s/%s", s3Scheme, s.Bucket, s.Key)
+
+	if s.VersionID != "" {
+		result = fmt.Sprintf("%s?versionId=%s", result, url.QueryEscape(s.VersionID))
+	}
+
+	return result
+}
+
+// Write replaces the content of the state file object in S3. Writing to a specific version of
+// an object is not possible, as S3 only allows to add new versions.
+func (s *S3Source) Write(content []byte) error {
+	if s.VersionID != "" {
+		return fmt.Errorf("cannot write to a specific version of a state file in S3 (%s)", s)
+	}
+
+	err := s.put(s.Key, content)
+	if err != nil {
+		return err
+	}
+
+	if s.LockTable != "" {
+		return s.putDigest(content)
+	}
+
+	return nil
+}
+
+// Backup copies the state file object to an object with a timestamped backup suffix in the same bucket.
+func (s *S3Source) Backup() (string, error) {
+	f, err := s.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read object from S3 (%s): %s", s, err)
+	}
+
+	backupKey := s.Key + backupSuffix()
+
+	err = s.put(backupKey, content)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%s/%s", s3Scheme, s.Bucket, backupKey), nil
+}
+
+// put writes an object with the given key to the bucket of the state file, encrypted like
+// the state file object (if the bucket or backend configures server-side encryption).
+func (s *S3Source) put(key string, content []byte) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(content),
+	}
+
+	if s.serverSideEncryption != "" {
+		input.ServerSideEncryption = aws.String(s.serverSideEncryption)
+	}
+
+	if s.sseKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.sseKMSKeyID)
+	}
+
+	_, err := s.client.PutObject(input)
+	if err != nil {
+		return fmt.Errorf("failed to put object to S3 (%s%s/%s): %s", s3Scheme, s.Bucket, key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+package state_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal S3-compatible server that serves objects via path-style requests.
+// Objects are stored by "bucket/key" and version ID; the empty version ID is the latest version.
+// Objects can be written via PUT requests (without versioning).
+//
+// The server-side encryption headers of objects are kept in encryption by "bucket/key" (if not nil).
+type fakeS3 struct {
+	objects    map[string]map[string]string
+	encryption map[string]http.Header
+}
+
+//nolint:gochecknoglobals
+var (
+	// encryptionHeaders are the headers of an object's server-side encryption with KMS.
+	encryptionHeaders = []string{"X-Amz-Server-Side-Encryption", "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"}
+)
+
+func (f fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("list-type") == "2" {
+		f.listObjects(w, r)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		f.putObject(w, r)
+		return
+	}
+
+	versions, ok := f.objects[strings.TrimPrefix(r.URL.Path, "/")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+
+		return
+	}
+
+	content, ok := versions[r.URL.Query().Get("versionId")]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`<Error><Code>NoSuchVersion</Code><Message>The specified version does not exist.</Message></Error>`))
+
+		return
+	}
+
+	for _, name := range encryptionHeaders {
+		if value := f.encryption[strings.TrimPrefix(r.URL.Path, "/")].Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+
+	_, _ = w.Write([]byte(content))
+}
+
+// listObjects responds with all keys in a bucket matching the requested prefix (ListObjectsV2).
+func (f fakeS3) listObjects(w http.ResponseWriter, r *http.Request) {
+	bucket := strings.Trim(r.URL.Path, "/")
+	prefix := r.URL.Query().Get("prefix")
+
+	var keys []string
+
+	for object := range f.objects {
+		key := strings.TrimPrefix(object, bucket+"/")
+		if key != object && strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	result := fmt.Sprintf("<ListBucketResult><Name>%s</Name><Prefix>%s</Prefix><KeyCount>%d</KeyCount>"+
+		"<IsTruncated>false</IsTruncated>", bucket, prefix, len(keys))
+
+	for _, key := range keys {
+		result += fmt.Sprintf("<Contents><Key>%s</Key></Contents>", key)
+	}
+
+	_, _ = w.Write([]byte(result + "</ListBucketResult>"))
+}
+
+// putObject stores the request body as the latest version of an object.
+func (f fakeS3) putObject(w http.ResponseWriter, r *http.Request) {
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	object := strings.TrimPrefix(r.URL.Path, "/")
+
+	if _, ok := f.objects[object]; !ok {
+		f.objects[object] = map[string]string{}
+	}
+
+	f.objects[object][""] = string(content)
+
+	if f.encryption != nil {
+		f.encryption[object] = http.Header{}
+
+		for _, name := range encryptionHeaders {
+			if value := r.Header.Get(name); value != "" {
+				f.encryption[object].Set(name, value)
+			}
+		}
+	}
+}
+
+func newFakeS3Client(t *testing.T, objects map[string]map[string]string) *s3.S3 {
+	return newFakeS3ClientOf(t, fakeS3{objects: objects})
+}
+
+// newFakeS3ClientOf returns a client of the given fake S3 server.
+func newFakeS3ClientOf(t *testing.T, fake fakeS3) *s3.S3 {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	sess, err := session.NewSession(&aws.Config{
+		Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+		Endpoint:         aws.String(server.URL),
+		Region:           aws.String("us-east-1"),
+		S3ForcePathStyle: aws.Bool(true),
+	})
+	require.NoError(t, err)
+
+	return s3.New(sess)
+}
+
+func TestNewS3Source(t *testing.T) {
+	tests := []struct {
+		name              string
+		location          string
+		expectedBucket    string
+		expectedKey       string
+		expectedVersionID string
+		expectedErrMsg    string
+	}{
+		{
+			name:           "bucket and key",
+			location:       "s3://my-bucket/path/to/terraform.tfstate",
+			expectedBucket: "my-bucket",
+			expectedKey:    "path/to/terraform.tfstate",
+		},
+		{
+			name:              "versioned object",
+			location:          "s3://my-bucket/terraform.tfstate?versionId=abc123",
+			expectedBucket:    "my-bucket",
+			expectedKey:       "terraform.tfstate",
+			expectedVersionID: "abc123",
+		},
+		{
+			name:           "missing key",
+			location:       "s3://my-bucket/",
+			expectedErrMsg: "S3 URL must contain a bucket and key (s3://bucket/key): s3://my-bucket/",
+		},
+		{
+			name:           "not an S3 URL",
+			location:       "https://my-bucket/terraform.tfstate",
+			expectedErrMsg: "not an S3 URL (expected s3://bucket/key): https://my-bucket/terraform.tfstate",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualSource, err := state.NewS3Source(tc.location, newFakeS3Client(t, nil))
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedBucket, actualSource.Bucket)
+			assert.Equal(t, tc.expectedKey, actualSource.Key)
+			assert.Equal(t, tc.expectedVersionID, actualSource.VersionID)
+			assert.Equal(t, tc.location, actualSource.String())
+		})
+	}
+}
+
+func TestIsS3VersionLocation(t *testing.T) {
+	assert.True(t, state.IsS3VersionLocation("s3://my-bucket/terraform.tfstate?versionId=abc123"))
+	assert.False(t, state.IsS3VersionLocation("s3://my-bucket/terraform.tfstate"))
+	assert.False(t, state.IsS3VersionLocation("path/to/terraform.tfstate"))
+}
+
+func TestNewFromSource_S3(t *testing.T) {
+	version3, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/version3.tfstate")
+	require.NoError(t, err)
+
+	version4, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/version4.tfstate")
+	require.NoError(t, err)
+
+	client := newFakeS3Client(t, map[string]map[string]string{
+		"my-bucket/path/terraform.tfstate": {
+			"":     string(version4),
+			"old":  string(version3),
+			"junk": "{",
+		},
+	})
+
+	tests := []struct {
+		name           string
+		location       string
+		expectedErrMsg string
+	}{
+		{
+			name:     "latest version",
+			location: "s3://my-bucket/path/terraform.tfstate",
+		},
+		{
+			name:     "specific version",
+			location: "s3://my-bucket/path/terraform.tfstate?versionId=old",
+		},
+		{
+			name:           "malformed state",
+			location:       "s3://my-bucket/path/terraform.tfstate?versionId=junk",
+			expectedErrMsg: "failed reading s3://my-bucket/path/terraform.tfstate?versionId=junk as a statefile",
+		},
+		{
+			name:           "object does not exist",
+			location:       "s3://my-bucket/not/exist/terraform.tfstate",
+			expectedErrMsg: "failed to get object from S3 (s3://my-bucket/not/exist/terraform.tfstate): NoSuchKey",
+		},
+		{
+			name:           "version does not exist",
+			location:       "s3://my-bucket/path/terraform.tfstate?versionId=foo",
+			expectedErrMsg: "NoSuchVersion",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := state.NewS3Source(tc.location, client)
+			require.NoError(t, err)
+
+			actualState, err := state.NewFromSource(src)
+
+			if tc.expectedErrMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErrMsg)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, []string{"aws"}, actualState.ProviderNames())
+			}
+		})
+	}
+}
+
+func TestDiscoverS3Sources(t *testing.T) {
+	client := newFakeS3Client(t, map[string]map[string]string{
+		"my-bucket/dev/vpc/terraform.tfstate":        {"": "{}"},
+		"my-bucket/dev/app/terraform.tfstate":        {"": "{}"},
+		"my-bucket/dev/app/terraform.tfstate.backup": {"": "{}"},
+		"my-bucket/prod/vpc/terraform.tfstate":       {"": "{}"},
+		"other-bucket/dev/vpc/terraform.tfstate":     {"": "{}"},
+	})
+
+	tests := []struct {
+		name              string
+		location          string
+		expectedLocations []string
+		expectedErrMsg    string
+	}{
+		{
+			name:     "whole bucket",
+			location: "s3://my-bucket",
+			expectedLocations: []string{
+				"s3://my-bucket/dev/app/terraform.tfstate",
+				"s3://my-bucket/dev/vpc/terraform.tfstate",
+				"s3://my-bucket/prod/vpc/terraform.tfstate",
+			},
+		},
+		{
+			name:     "prefix",
+			location: "s3://my-bucket/dev/",
+			expectedLocations: []string{
+				"s3://my-bucket/dev/app/terraform.tfstate",
+				"s3://my-bucket/dev/vpc/terraform.tfstate",
+			},
+		},
+		{
+			name:     "no state files under prefix",
+			location: "s3://my-bucket/staging/",
+		},
+		{
+			name:           "missing bucket",
+			location:       "s3:///dev",
+			expectedErrMsg: "S3 URL must contain a bucket (s3://bucket/prefix): s3:///dev",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualSources, err := state.DiscoverS3Sources(tc.location, client)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var actualLocations []string
+			for _, src := range actualSources {
+				actualLocations = append(actualLocations, src.String())
+			}
+
+			assert.Equal(t, tc.expectedLocations, actualLocations)
+		})
+	}
+}
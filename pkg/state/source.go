@@ -0,0 +1,186 @@
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/jckuester/terradozer/internal"
+)
+
+// stateFileSuffix is the file extension of Terraform state files.
+const stateFileSuffix = ".tfstate"
+
+// Source is a location from which the content of a Terraform state file can be read.
+type Source interface {
+	// Open returns a reader for the raw content of the state file.
+	Open() (io.ReadCloser, error)
+	// String returns the location of the state file (e.g., a path or URL).
+	String() string
+}
+
+// WritableSource is a source to which the content of a Terraform state file can be written back.
+type WritableSource interface {
+	Source
+	// Write replaces the content of the state file.
+	Write(content []byte) error
+	// Backup stores a copy of the current content of the state file next to it
+	// and returns the location of the copy.
+	Backup() (string, error)
+}
+
+// backupSuffix returns the suffix for a backup of a state file made now,
+// which is the same as the one of backups made by Terraform (e.g., .1589372103.backup).
+func backupSuffix() string {
+	return fmt.Sprintf(".%d.backup", time.Now().UTC().Unix())
+}
+
+// NewSource returns the source for a given location of a Terraform state file.
+//
+// Locations starting with "s3://" are read from an AWS S3 bucket; any other location
+// is treated as a path on the local filesystem.
+func NewSource(location string) (Source, error) {
+	if strings.HasPrefix(location, s3Scheme) {
+		return NewS3Source(location, nil)
+	}
+
+	return NewFileSource(location), nil
+}
+
+// DiscoverSources returns the sources of all Terraform state files (*.tfstate) found
+// recursively under the given location, which is either a directory on the local filesystem
+// or an S3 URL with a key prefix (i.e., s3://bucket/prefix/).
+func DiscoverSources(location string) ([]Source, error) {
+	if strings.HasPrefix(location, s3Scheme) {
+		return DiscoverS3Sources(location, nil)
+	}
+
+	return DiscoverFileSources(location)
+}
+
+// DiscoverFileSources returns the sources of all Terraform state files (*.tfstate) found
+// recursively under the given directory.
+//
+// Directories created by Terraform (.terraform) and Terragrunt (.terragrunt-cache) are skipped, as
+// they contain copies of modules and backend configurations rather than states.
+func DiscoverFileSources(dir string) ([]Source, error) {
+	var result []Source
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			if info.Name() == ".terraform" || info.Name() == ".terragrunt-cache" {
+				log.WithField("dir", path).Debug(internal.Pad("skipping directory"))
+
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if strings.HasSuffix(path, stateFileSuffix) {
+			result = append(result, NewFileSource(path))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// FileSource is a Terraform state file on the local filesystem.
+type FileSource struct {
+	path   string
+	locker *fileLocker
+}
+
+// NewFileSource creates a source for a state file stored under the given path.
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Open opens the state file for reading.
+func (s *FileSource) Open() (io.ReadCloser, error) {
+	content, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(content)), nil
+}
+
+// read returns the content of the state file. A locked state file is read via the locked file, as closing any
+// other file descriptor of it would release the lock (see fileLocker).
+func (s *FileSource) read() ([]byte, error) {
+	if s.locker != nil {
+		content, locked, err := s.locker.read()
+		if locked {
+			return content, err
+		}
+	}
+
+	return ioutil.ReadFile(s.path)
+}
+
+// String returns the path to the state file.
+func (s *FileSource) String() string {
+	return s.path
+}
+
+// Write replaces the content of the state file in place, like Terraform's local backend does.
+// A locked state file is written via the locked file, so that the lock is kept.
+func (s *FileSource) Write(content []byte) error {
+	if s.locker != nil {
+		locked, err := s.locker.write(content)
+		if locked {
+			if err != nil {
+				return fmt.Errorf("failed to write state file (%s): %s", s.path, err)
+			}
+
+			return nil
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open state file (%s): %s", s.path, err)
+	}
+
+	defer f.Close()
+
+	err = writeFile(f, content)
+	if err != nil {
+		return fmt.Errorf("failed to write state file (%s): %s", s.path, err)
+	}
+
+	return nil
+}
+
+// Backup copies the state file to a file with a timestamped backup suffix in the same directory.
+func (s *FileSource) Backup() (string, error) {
+	content, err := s.read()
+	if err != nil {
+		return "", fmt.Errorf("failed to read state file for backup: %s", err)
+	}
+
+	backupPath := s.path + backupSuffix()
+
+	err = ioutil.WriteFile(backupPath, content, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to write backup of state file: %s", err)
+	}
+
+	return backupPath, nil
+}
@@ -0,0 +1,80 @@
+package state_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverFileSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "terradozer")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	files := []string{
+		"dev/app/terraform.tfstate",
+		"dev/app/terraform.tfstate.backup",
+		"dev/app/.terraform/terraform.tfstate",
+		"dev/vpc/terraform.tfstate",
+		"dev/vpc/.terragrunt-cache/abc/terraform.tfstate",
+		"dev/vpc/main.tf",
+	}
+
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte("{}"), 0600))
+	}
+
+	tests := []struct {
+		name              string
+		dir               string
+		expectedLocations []string
+		expectedErrMsg    string
+	}{
+		{
+			name: "nested directories",
+			dir:  dir,
+			expectedLocations: []string{
+				filepath.Join(dir, "dev/app/terraform.tfstate"),
+				filepath.Join(dir, "dev/vpc/terraform.tfstate"),
+			},
+		},
+		{
+			name:              "single state file",
+			dir:               filepath.Join(dir, "dev/vpc/terraform.tfstate"),
+			expectedLocations: []string{filepath.Join(dir, "dev/vpc/terraform.tfstate")},
+		},
+		{
+			name:           "directory does not exist",
+			dir:            "not/exist",
+			expectedErrMsg: "lstat not/exist: no such file or directory",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actualSources, err := state.DiscoverFileSources(tc.dir)
+
+			if tc.expectedErrMsg != "" {
+				assert.EqualError(t, err, tc.expectedErrMsg)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var actualLocations []string
+			for _, src := range actualSources {
+				actualLocations = append(actualLocations, src.String())
+			}
+
+			assert.Equal(t, tc.expectedLocations, actualLocations)
+		})
+	}
+}
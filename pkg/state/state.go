@@ -4,65 +4,112 @@ package state
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"io/ioutil"
 	"sort"
 
 	"github.com/apex/log"
 	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/providers"
 	"github.com/hashicorp/terraform/states"
 	"github.com/hashicorp/terraform/states/statefile"
 	"github.com/jckuester/awstools-lib/terraform"
 	"github.com/jckuester/awstools-lib/terraform/provider"
 	"github.com/jckuester/terradozer/internal"
+	"github.com/jckuester/terradozer/pkg/plugin"
 	"github.com/jckuester/terradozer/pkg/resource"
 	"github.com/zclconf/go-cty/cty"
 )
 
 // State represents a Terraform state.
 type State struct {
+	file  *statefile.File
 	state *states.State
+	// raw is the state as written by Terraform v0.13 and later (nil for states of earlier versions),
+	// as the statefile package of Terraform v0.12.31 would write it back in its own form (see readStateFile).
+	raw *stateV4
+	// source is where the state has been read from.
+	source Source
+	// filter selects the resources returned by Resources.
+	filter Filter
+	// resourceAddrs are the addresses of the resources returned by Resources.
+	resourceAddrs map[resource.DestroyableResource]addrs.AbsResourceInstance
+	// upgraders upgrade resource states written by older versions of a provider (see SetUpgraders).
+	upgraders map[*provider.TerraformProvider]StateUpgrader
 }
 
-// New creates a state from a given path to a Terraform state file.
-func New(path string) (*State, error) {
-	stateFile, err := getStateFromPath(path)
+// StateUpgrader upgrades the states of resources written by older versions of a provider
+// to the schema of the provider (see plugin.Upgrader).
+type StateUpgrader interface {
+	UpgradeResourceState(providers.UpgradeResourceStateRequest) providers.UpgradeResourceStateResponse
+}
+
+// New creates a state from a given location of a Terraform state file,
+// which is either a path on the local filesystem or an S3 URL (i.e., s3://bucket/key).
+func New(location string, opts ...Option) (*State, error) {
+	src, err := NewSource(location)
 	if err != nil {
 		return nil, err
 	}
 
-	return &State{stateFile.State}, nil
+	return NewFromSource(src, opts...)
 }
 
-// copied from github.com/hashicorp/terraform/command/show.go
-func getStateFromPath(path string) (*statefile.File, error) {
-	f, err := os.Open(path)
+// NewFromSource creates a state by reading the Terraform state file from the given source.
+func NewFromSource(src Source, opts ...Option) (*State, error) {
+	stateFile, raw, err := getStateFromSource(src)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
 
-	var stateFile *statefile.File
+	s := &State{
+		file:          stateFile,
+		raw:           raw,
+		state:         stateFile.State,
+		source:        src,
+		resourceAddrs: map[resource.DestroyableResource]addrs.AbsResourceInstance{},
+	}
 
-	stateFile, err = statefile.Read(f)
-	if err != nil {
-		return nil, fmt.Errorf("failed reading %s as a statefile: %s", path, err)
+	for _, opt := range opts {
+		opt(s)
 	}
 
-	return stateFile, nil
+	return s, nil
 }
 
-// ProviderNames returns a list of all provider names (e.g., "aws", "google") in the state.
-// The result of provider names is deduplicated.
-func (s *State) ProviderNames() []string {
-	var providers []string
+// copied (and modified) from github.com/hashicorp/terraform/command/show.go
+func getStateFromSource(src Source) (*statefile.File, *stateV4, error) {
+	f, err := src.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
 
-	log.WithField("addresses", s.state.ProviderAddrs()).Debug(internal.Pad("providers found in state"))
+	content, err := ioutil.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for _, pAddr := range s.state.ProviderAddrs() {
-		providers = append(providers, pAddr.ProviderConfig.StringCompact())
+	stateFile, raw, err := readStateFile(content)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed reading %s as a statefile: %s", src, err)
 	}
 
-	return removeDuplicates(providers)
+	return stateFile, raw, nil
+}
+
+// Location returns where the state has been read from (e.g., a path or S3 URL).
+func (s *State) Location() string {
+	return s.source.String()
+}
+
+// Lineage returns the lineage of the state, which is unique per state and kept across changes.
+func (s *State) Lineage() string {
+	return s.file.Lineage
+}
+
+// Serial returns the serial of the state, which is incremented with every change.
+func (s *State) Serial() uint64 {
+	return s.file.Serial
 }
 
 func removeDuplicates(elements []string) []string {
@@ -85,11 +132,20 @@ func removeDuplicates(elements []string) []string {
 
 // Resources returns a list of resources in the state that are managed by one of the given providers.
 //
+// The given providers are either keyed by the address of a provider configuration (see ProviderConfigs)
+// or by provider name (e.g., "aws"), which is used for all configurations of the provider without
+// a provider of their own.
+//
 // Data sources are not returned as these are managed outside the scope of the state and
-// therefore shouldn't be destroyed.
+// therefore shouldn't be destroyed. If the state has a filter (see WithFilter), only selected
+// resources are returned.
+//
+// Each returned resource knows the other returned resources it depends on (see DependencyGraph).
 func (s *State) Resources(providers map[string]*provider.TerraformProvider) ([]terraform.UpdatableResource, error) {
 	var resources []terraform.UpdatableResource
 
+	resourcesByAddr := map[string]*resource.Resource{}
+
 	for _, resAddr := range lookupAllResourceInstanceAddrs(s.state) {
 		log.WithField("absolute_address", resAddr.String()).
 			Debug(internal.Pad("looked up resource instance address"))
@@ -110,27 +166,122 @@ func (s *State) Resources(providers map[string]*provider.TerraformProvider) ([]t
 			continue
 		}
 
-		providerName := resAddr.Resource.Resource.DefaultProviderConfig().StringCompact()
+		if !s.filter.Matches(resAddr) {
+			log.WithFields(log.Fields{
+				"address": resAddr.String(),
+				"id":      resID}).Debug(internal.Pad("ignoring resource not selected by filter"))
 
-		p, ok := providers[providerName]
+			continue
+		}
+
+		p, ok := lookupProvider(providers, s.state.Resource(resAddr.ContainingResource()).ProviderConfig)
 		if !ok {
-			log.WithField("name", providerName).Debug(internal.Pad("Terraform provider not found in providers list"))
+			log.WithField("address", resAddr.String()).Debug(internal.Pad("Terraform provider not found in providers list"))
 
 			continue
 		}
 
-		resObject, err := getResourceState(resInstance, resAddr.Resource.Resource.Type, p)
+		resObject, err := getResourceState(resInstance, resAddr.Resource.Resource.Type, p, s.upgraders[p])
 		if err != nil {
 			return nil, fmt.Errorf("failed to decode resource into object (addr=%s): %s", resAddr.String(), err)
 		}
 
 		r := resource.NewWithState(resAddr.Resource.Resource.Type, resID, p, &resObject)
+		r.SetAddress(resAddr.String())
+
 		resources = append(resources, r)
+		resourcesByAddr[resAddr.String()] = r
+		s.resourceAddrs[r] = resAddr
 	}
 
+	linkDependencies(resourcesByAddr, s.DependencyGraph())
+
 	return resources, nil
 }
 
+// ResourcesOfProviders returns the resources in the state of the given providers, which are matched by source
+// address (see Providers). As for Resources, data sources and resources not selected by the filter of the state
+// are not returned.
+//
+// The returned resources have no provider and state, i.e., they can be reported but not destroyed
+// (e.g., the resources of providers that can't be launched).
+func (s *State) ResourcesOfProviders(providers []plugin.Provider) ([]*resource.Resource, error) {
+	providerByName, err := s.providersByName()
+	if err != nil {
+		return nil, err
+	}
+
+	isProvider := map[string]bool{}
+	for _, p := range providers {
+		isProvider[p.Address()] = true
+	}
+
+	var resources []*resource.Resource
+
+	for _, resAddr := range lookupAllResourceInstanceAddrs(s.state) {
+		if resAddr.ContainingResource().Resource.Mode != addrs.ManagedResourceMode || !s.filter.Matches(resAddr) {
+			continue
+		}
+
+		pAddr := s.state.Resource(resAddr.ContainingResource()).ProviderConfig
+		if !isProvider[providerByName[pAddr.ProviderConfig.Type.LegacyString()].Address()] {
+			continue
+		}
+
+		resID, err := getResourceID(s.state.ResourceInstance(resAddr))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get id for resource (addr=%s): %s", resAddr.String(), err)
+		}
+
+		r := resource.New(resAddr.Resource.Resource.Type, resID, nil, nil)
+		r.SetAddress(resAddr.String())
+
+		resources = append(resources, r)
+	}
+
+	return resources, nil
+}
+
+// SetUpgraders sets the upgraders of resource states by provider. If the schema version of a resource
+// in the state differs from the one of its provider, the resource state is upgraded via the upgrader of
+// its provider (if any) before it is decoded (see Resources).
+func (s *State) SetUpgraders(upgraders map[*provider.TerraformProvider]StateUpgrader) {
+	s.upgraders = upgraders
+}
+
+// lookupProvider returns the provider for the given provider configuration, falling back
+// to the one for all configurations of a provider (i.e., keyed by the name of the provider).
+func lookupProvider(providers map[string]*provider.TerraformProvider,
+	pAddr addrs.AbsProviderConfig) (*provider.TerraformProvider, bool) {
+	if p, ok := providers[providerConfigString(pAddr)]; ok {
+		return p, true
+	}
+
+	p, ok := providers[pAddr.ProviderConfig.Type.LegacyString()]
+
+	return p, ok
+}
+
+// linkDependencies sets for each resource the resources it depends on according to the given graph.
+func linkDependencies(resourcesByAddr map[string]*resource.Resource, graph *DependencyGraph) {
+	for addr, r := range resourcesByAddr {
+		depAddrs, ok := graph.Dependencies(addr)
+		if !ok {
+			continue
+		}
+
+		dependencies := []resource.DestroyableResource{}
+
+		for _, depAddr := range depAddrs {
+			if dep, ok := resourcesByAddr[depAddr]; ok {
+				dependencies = append(dependencies, dep)
+			}
+		}
+
+		r.SetDependencies(dependencies)
+	}
+}
+
 // resourceID represents the ID attribute of a Terraform resource.
 type resourceID struct {
 	ID string `json:"id"`
@@ -168,8 +319,11 @@ func getResourceID(resInstance *states.ResourceInstance) (string, error) {
 
 // getResourceState unmarshals the JSON representation of a resource found in the state file into
 // an internal Terraform state object representation.
+//
+// If the resource has been written with an older schema version than the one of the provider,
+// its state is upgraded with the given upgrader (if any) first.
 func getResourceState(resInstance *states.ResourceInstance, rType string,
-	provider *provider.TerraformProvider) (cty.Value, error) {
+	provider *provider.TerraformProvider, upgrader StateUpgrader) (cty.Value, error) {
 	if !resInstance.HasCurrent() {
 		return cty.NilVal, fmt.Errorf("resource instance has no current object")
 	}
@@ -179,7 +333,34 @@ func getResourceState(resInstance *states.ResourceInstance, rType string,
 		return cty.NilVal, err
 	}
 
-	resInstanceObj, err := resInstance.Current.Decode(resourceSchema.Block.ImpliedType())
+	obj := resInstance.Current
+
+	if obj.SchemaVersion > uint64(resourceSchema.Version) {
+		return cty.NilVal, fmt.Errorf("resource has been written by a newer version of the provider "+
+			"(schema version %d, supported %d)", obj.SchemaVersion, resourceSchema.Version)
+	}
+
+	if obj.SchemaVersion < uint64(resourceSchema.Version) && upgrader != nil {
+		log.WithFields(log.Fields{
+			"type":           rType,
+			"schema_version": obj.SchemaVersion,
+		}).Debug(internal.Pad("upgrading resource state"))
+
+		resp := upgrader.UpgradeResourceState(providers.UpgradeResourceStateRequest{
+			TypeName:        rType,
+			Version:         int64(obj.SchemaVersion),
+			RawStateJSON:    obj.AttrsJSON,
+			RawStateFlatmap: obj.AttrsFlat,
+		})
+		if resp.Diagnostics.HasErrors() {
+			return cty.NilVal, fmt.Errorf("failed to upgrade resource state (schema version %d to %d): %s",
+				obj.SchemaVersion, resourceSchema.Version, resp.Diagnostics.Err())
+		}
+
+		return resp.UpgradedState, nil
+	}
+
+	resInstanceObj, err := obj.Decode(resourceSchema.Block.ImpliedType())
 	if err != nil {
 		return cty.NilVal, err
 	}
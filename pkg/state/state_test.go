@@ -8,6 +8,7 @@ import (
 	"github.com/jckuester/awstools-lib/terraform/provider"
 	"github.com/jckuester/awstools-lib/test"
 	testUtil "github.com/jckuester/awstools-lib/test"
+	"github.com/jckuester/terradozer/pkg/plugin"
 	"github.com/jckuester/terradozer/pkg/resource"
 	"github.com/jckuester/terradozer/pkg/state"
 	"github.com/stretchr/testify/assert"
@@ -29,6 +30,18 @@ func TestNewState(t *testing.T) {
 			name:        "state version 4",
 			pathToState: "../../test/test-fixtures/tfstates/version4.tfstate",
 		},
+		{
+			name:        "state of Terraform v0.13",
+			pathToState: "../../test/test-fixtures/tfstates/terraform-0.13.tfstate",
+		},
+		{
+			name:        "state of Terraform v1.x",
+			pathToState: "../../test/test-fixtures/tfstates/terraform-1.x.tfstate",
+		},
+		{
+			name:        "state of OpenTofu",
+			pathToState: "../../test/test-fixtures/tfstates/opentofu.tfstate",
+		},
 		{
 			name:           "broken state file with malformed JSON",
 			pathToState:    "../../test/test-fixtures/tfstates/malformed.tfstate",
@@ -55,6 +68,14 @@ func TestNewState(t *testing.T) {
 	}
 }
 
+func TestState_LineageAndSerial(t *testing.T) {
+	s, err := state.New("../../test/test-fixtures/tfstates/version4.tfstate")
+	require.NoError(t, err)
+
+	assert.Equal(t, "e5931376-a89f-3e94-a4e0-b3431bf3e524", s.Lineage())
+	assert.Equal(t, uint64(106), s.Serial())
+}
+
 func TestState_ProviderNames(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -85,6 +106,21 @@ func TestState_ProviderNames(t *testing.T) {
 			pathToState:           "../../test/test-fixtures/tfstates/duplicate-provider.tfstate",
 			expectedProviderNames: []string{"aws"},
 		},
+		{
+			name:                  "aliased providers",
+			pathToState:           "../../test/test-fixtures/tfstates/aliased-providers.tfstate",
+			expectedProviderNames: []string{"aws"},
+		},
+		{
+			name:                  "state of Terraform v1.x",
+			pathToState:           "../../test/test-fixtures/tfstates/terraform-1.x.tfstate",
+			expectedProviderNames: []string{"aws", "random"},
+		},
+		{
+			name:                  "state of OpenTofu",
+			pathToState:           "../../test/test-fixtures/tfstates/opentofu.tfstate",
+			expectedProviderNames: []string{"aws"},
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -98,6 +134,23 @@ func TestState_ProviderNames(t *testing.T) {
 	}
 }
 
+func TestProviderNamesOf(t *testing.T) {
+	var states []*state.State
+
+	for _, path := range []string{
+		"../../test/test-fixtures/tfstates/version4.tfstate",
+		"../../test/test-fixtures/tfstates/empty.tfstate",
+		"../../test/test-fixtures/tfstates/multiple-providers.tfstate",
+	} {
+		s, err := state.New(path)
+		require.NoError(t, err)
+
+		states = append(states, s)
+	}
+
+	assert.Equal(t, []string{"aws", "random"}, state.ProviderNamesOf(states))
+}
+
 func TestState_Resources(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration test.")
@@ -138,6 +191,18 @@ func TestState_Resources(t *testing.T) {
 					awsProvider, nil),
 			},
 		},
+		{
+			name:        "aliased provider",
+			pathToState: "../../test/test-fixtures/tfstates/aliased-providers.tfstate",
+			providers: map[string]*provider.TerraformProvider{
+				"aws.us_east_1": awsProvider,
+			},
+			expectedResources: []terraform.UpdatableResource{
+				resource.NewWithState("aws_vpc",
+					"vpc-0b1c2d3e4f5a6b7c8",
+					awsProvider, nil),
+			},
+		},
 		{
 			name:        "data source",
 			pathToState: "../../test/test-fixtures/tfstates/datasource.tfstate",
@@ -176,3 +241,55 @@ func TestState_Resources(t *testing.T) {
 		})
 	}
 }
+
+func TestState_ResourcesOfProviders(t *testing.T) {
+	tests := []struct {
+		name              string
+		providers         []plugin.Provider
+		expectedAddresses []string
+		expectedIDs       []string
+	}{
+		{
+			name:              "third-party provider",
+			providers:         []plugin.Provider{{Hostname: "registry.example.com", Namespace: "acme", Type: "widgets"}},
+			expectedAddresses: []string{"widgets_thing.test"},
+			expectedIDs:       []string{"thing-1"},
+		},
+		{
+			name: "multiple providers",
+			providers: []plugin.Provider{
+				plugin.NewProvider("aws", ""),
+				{Hostname: "registry.example.com", Namespace: "acme", Type: "widgets"},
+			},
+			expectedAddresses: []string{"aws_vpc.test", "widgets_thing.test"},
+			expectedIDs:       []string{"vpc-1", "thing-1"},
+		},
+		{
+			name:      "provider not in state",
+			providers: []plugin.Provider{plugin.NewProvider("google", "")},
+		},
+		{
+			name:      "same type of provider from different source",
+			providers: []plugin.Provider{{Hostname: "registry.opentofu.org", Namespace: "hashicorp", Type: "aws"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := state.New("../../test/test-fixtures/tfstates/fake-provider-third-party.tfstate")
+			require.NoError(t, err)
+
+			actualResources, err := s.ResourcesOfProviders(tc.providers)
+			require.NoError(t, err)
+
+			var actualAddresses, actualIDs []string
+
+			for _, r := range actualResources {
+				actualAddresses = append(actualAddresses, r.Address())
+				actualIDs = append(actualIDs, r.ID())
+			}
+
+			assert.Equal(t, tc.expectedAddresses, actualAddresses)
+			assert.Equal(t, tc.expectedIDs, actualIDs)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform/addrs"
+)
+
+// resourceTimeouts represents the timeouts block of a Terraform resource.
+type resourceTimeouts struct {
+	Timeouts *struct {
+		Delete *string `json:"delete"`
+	} `json:"timeouts"`
+}
+
+// DeleteTimeouts returns the delete timeouts of all managed resource instances in the state with
+// a timeouts block (e.g., timeouts { delete = "40m" }), keyed by the address of the resource instance.
+func (s *State) DeleteTimeouts() (map[string]time.Duration, error) {
+	result := map[string]time.Duration{}
+
+	for _, resAddr := range lookupAllResourceInstanceAddrs(s.state) {
+		if resAddr.ContainingResource().Resource.Mode != addrs.ManagedResourceMode {
+			continue
+		}
+
+		resInstance := s.state.ResourceInstance(resAddr)
+		if !resInstance.HasCurrent() || resInstance.Current.AttrsJSON == nil {
+			continue
+		}
+
+		var attrs resourceTimeouts
+
+		err := json.Unmarshal(resInstance.Current.AttrsJSON, &attrs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal timeouts of resource (addr=%s): %s", resAddr.String(), err)
+		}
+
+		if attrs.Timeouts == nil || attrs.Timeouts.Delete == nil || *attrs.Timeouts.Delete == "" {
+			continue
+		}
+
+		timeout, err := time.ParseDuration(*attrs.Timeouts.Delete)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse delete timeout of resource (addr=%s): %s", resAddr.String(), err)
+		}
+
+		result[resAddr.String()] = timeout
+	}
+
+	return result, nil
+}
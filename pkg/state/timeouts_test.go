@@ -0,0 +1,47 @@
+package state_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestState_DeleteTimeouts(t *testing.T) {
+	tests := []struct {
+		name             string
+		pathToState      string
+		expectedTimeouts map[string]time.Duration
+	}{
+		{
+			name:        "timeouts blocks",
+			pathToState: "../../test/test-fixtures/tfstates/timeouts.tfstate",
+			expectedTimeouts: map[string]time.Duration{
+				"aws_db_instance.test": 40 * time.Minute,
+			},
+		},
+		{
+			name:             "no timeouts blocks",
+			pathToState:      "../../test/test-fixtures/tfstates/version4.tfstate",
+			expectedTimeouts: map[string]time.Duration{},
+		},
+		{
+			name:             "state version 3",
+			pathToState:      "../../test/test-fixtures/tfstates/version3.tfstate",
+			expectedTimeouts: map[string]time.Duration{},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := state.New(tc.pathToState)
+			require.NoError(t, err)
+
+			actualTimeouts, err := s.DeleteTimeouts()
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedTimeouts, actualTimeouts)
+		})
+	}
+}
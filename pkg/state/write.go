@@ -0,0 +1,175 @@
+package state
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states/statefile"
+	"github.com/hashicorp/terraform/tfdiags"
+	"github.com/jckuester/terradozer/internal"
+	"github.com/jckuester/terradozer/pkg/resource"
+)
+
+// RemoveResources removes the given resources (e.g., the successfully destroyed ones) from the state.
+// Only resources returned by Resources of this state are removed; others are ignored.
+//
+// Returns the number of removed resource instances.
+func (s *State) RemoveResources(resources []resource.DestroyableResource) int {
+	numOfRemovedResources := 0
+
+	for _, r := range resources {
+		resAddr, ok := s.resourceAddrs[r]
+		if !ok {
+			continue
+		}
+
+		s.removeResourceInstance(resAddr)
+
+		log.WithField("address", resAddr.String()).Debug(internal.Pad("removed resource from state"))
+
+		delete(s.resourceAddrs, r)
+
+		numOfRemovedResources++
+	}
+
+	return numOfRemovedResources
+}
+
+// removeResourceInstance removes the current object of a resource instance from the state. The resource instance
+// (and its module) is removed altogether if nothing else is left; deposed objects are kept, as they are
+// not destroyed.
+func (s *State) removeResourceInstance(resAddr addrs.AbsResourceInstance) {
+	rs := s.state.Resource(resAddr.ContainingResource())
+	if rs == nil {
+		return
+	}
+
+	s.state.SyncWrapper().SetResourceInstanceCurrent(resAddr, nil, rs.ProviderConfig)
+}
+
+// Write writes the state back to where it has been read from, with its serial incremented.
+// Beforehand, a backup of the original state file is made.
+//
+// Returns the location of the backup.
+func (s *State) Write() (string, error) {
+	src, ok := s.source.(WritableSource)
+	if !ok {
+		return "", fmt.Errorf("writing state to %s is not supported", s.source)
+	}
+
+	s.file.Serial++
+
+	content, err := s.encode()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode state: %s", err)
+	}
+
+	backup, err := src.Backup()
+	if err != nil {
+		return "", err
+	}
+
+	err = src.Write(content)
+	if err != nil {
+		return "", err
+	}
+
+	return backup, nil
+}
+
+// encode encodes the state in the form it has been read in. States written by Terraform v0.13 and later
+// are encoded as read (see readStateFile), without the objects of resource instances that have been removed.
+func (s *State) encode() ([]byte, error) {
+	if s.raw == nil {
+		var buf bytes.Buffer
+
+		err := statefile.Write(s.file, &buf)
+
+		return buf.Bytes(), err
+	}
+
+	result := *s.raw
+	result.Serial = s.file.Serial
+	result.Resources = []resourceV4{}
+
+	for _, rs := range s.raw.Resources {
+		var instances []json.RawMessage
+
+		for _, is := range rs.Instances {
+			removed, err := s.isRemoved(rs, is)
+			if err != nil {
+				return nil, err
+			}
+
+			if !removed {
+				instances = append(instances, is)
+			}
+		}
+
+		if len(instances) == 0 {
+			continue
+		}
+
+		rs.Instances = instances
+		result.Resources = append(result.Resources, rs)
+	}
+
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(content, '\n'), nil
+}
+
+// isRemoved returns true if the given object of a resource instance in the state as read has been removed
+// from the state (see RemoveResources). Deposed objects are never removed.
+func (s *State) isRemoved(rs resourceV4, rawInstance json.RawMessage) (bool, error) {
+	var is instanceKeyV4
+
+	err := json.Unmarshal(rawInstance, &is)
+	if err != nil {
+		return false, err
+	}
+
+	if is.Deposed != "" {
+		return false, nil
+	}
+
+	moduleAddr := addrs.RootModuleInstance
+
+	if rs.Module != "" {
+		var diags tfdiags.Diagnostics
+
+		moduleAddr, diags = addrs.ParseModuleInstanceStr(rs.Module)
+		if diags.HasErrors() {
+			return false, diags.Err()
+		}
+	}
+
+	rAddr := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: rs.Type,
+		Name: rs.Name,
+	}
+
+	if rs.Mode == "data" {
+		rAddr.Mode = addrs.DataResourceMode
+	}
+
+	key := addrs.NoKey
+
+	switch k := is.IndexKey.(type) {
+	case float64:
+		key = addrs.IntKey(int(k))
+	case string:
+		key = addrs.StringKey(k)
+	}
+
+	resInstance := s.state.ResourceInstance(rAddr.Instance(key).Absolute(moduleAddr))
+
+	return resInstance == nil || !resInstance.HasCurrent(), nil
+}
@@ -0,0 +1,254 @@
+package state_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jckuester/awstools-lib/terraform/provider"
+	"github.com/jckuester/awstools-lib/test"
+	testUtil "github.com/jckuester/awstools-lib/test"
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stateFileContent is the part of a Terraform state file (version 4) relevant for the tests.
+type stateFileContent struct {
+	Serial    int    `json:"serial"`
+	Lineage   string `json:"lineage"`
+	Resources []struct {
+		Mode      string            `json:"mode"`
+		Type      string            `json:"type"`
+		Instances []json.RawMessage `json:"instances"`
+	} `json:"resources"`
+}
+
+func readStateFileContent(t *testing.T, content []byte) stateFileContent {
+	var result stateFileContent
+
+	require.NoError(t, json.Unmarshal(content, &result))
+
+	return result
+}
+
+func TestState_Write(t *testing.T) {
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/dependencies.tfstate")
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "terradozer")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "terraform.tfstate")
+	require.NoError(t, ioutil.WriteFile(path, original, 0600))
+
+	s, err := state.New(path)
+	require.NoError(t, err)
+
+	actualBackup, err := s.Write()
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(actualBackup, path+"."))
+	assert.True(t, strings.HasSuffix(actualBackup, ".backup"))
+
+	actualBackupContent, err := ioutil.ReadFile(actualBackup)
+	require.NoError(t, err)
+	assert.Equal(t, original, actualBackupContent)
+
+	actualContent, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	expectedState := readStateFileContent(t, original)
+	actualState := readStateFileContent(t, actualContent)
+
+	assert.Equal(t, expectedState.Serial+1, actualState.Serial)
+	assert.Equal(t, expectedState.Lineage, actualState.Lineage)
+	assert.Equal(t, len(expectedState.Resources), len(actualState.Resources))
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode())
+}
+
+func TestState_Write_Terraform1x(t *testing.T) {
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/terraform-1.x.tfstate")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "terraform.tfstate")
+	require.NoError(t, ioutil.WriteFile(path, original, 0600))
+
+	s, err := state.New(path)
+	require.NoError(t, err)
+
+	_, err = s.Write()
+	require.NoError(t, err)
+
+	actualContent, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	var expectedState, actualState map[string]interface{}
+
+	require.NoError(t, json.Unmarshal(original, &expectedState))
+	require.NoError(t, json.Unmarshal(actualContent, &actualState))
+
+	// everything else (e.g., provider addresses, sensitive_attributes, and check_results) is written as read
+	expectedState["serial"] = expectedState["serial"].(float64) + 1
+
+	assert.Equal(t, expectedState, actualState)
+}
+
+func TestState_Write_S3(t *testing.T) {
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/dependencies.tfstate")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		location       string
+		expectedErrMsg string
+	}{
+		{
+			name:     "latest version",
+			location: "s3://my-bucket/path/terraform.tfstate",
+		},
+		{
+			name:           "specific version",
+			location:       "s3://my-bucket/path/terraform.tfstate?versionId=old",
+			expectedErrMsg: "cannot write to a specific version of a state file in S3",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			objects := map[string]map[string]string{
+				"my-bucket/path/terraform.tfstate": {
+					"":    string(original),
+					"old": string(original),
+				},
+			}
+
+			src, err := state.NewS3Source(tc.location, newFakeS3Client(t, objects))
+			require.NoError(t, err)
+
+			s, err := state.NewFromSource(src)
+			require.NoError(t, err)
+
+			actualBackup, err := s.Write()
+
+			if tc.expectedErrMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErrMsg)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			backupKey := strings.TrimPrefix(actualBackup, "s3://")
+			require.Contains(t, objects, backupKey)
+			assert.Equal(t, string(original), objects[backupKey][""])
+
+			actualState := readStateFileContent(t, []byte(objects["my-bucket/path/terraform.tfstate"][""]))
+			assert.Equal(t, readStateFileContent(t, original).Serial+1, actualState.Serial)
+		})
+	}
+}
+
+func TestState_Write_S3_KeepsEncryption(t *testing.T) {
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/dependencies.tfstate")
+	require.NoError(t, err)
+
+	fake := fakeS3{
+		objects: map[string]map[string]string{
+			"my-bucket/path/terraform.tfstate": {"": string(original)},
+		},
+		encryption: map[string]http.Header{
+			"my-bucket/path/terraform.tfstate": {
+				"X-Amz-Server-Side-Encryption":                {"aws:kms"},
+				"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id": {"my-key"},
+			},
+		},
+	}
+
+	src, err := state.NewS3Source("s3://my-bucket/path/terraform.tfstate", newFakeS3ClientOf(t, fake))
+	require.NoError(t, err)
+
+	s, err := state.NewFromSource(src)
+	require.NoError(t, err)
+
+	actualBackup, err := s.Write()
+	require.NoError(t, err)
+
+	for _, object := range []string{"my-bucket/path/terraform.tfstate", strings.TrimPrefix(actualBackup, "s3://")} {
+		assert.Equal(t, "aws:kms", fake.encryption[object].Get("X-Amz-Server-Side-Encryption"), object)
+		assert.Equal(t, "my-key", fake.encryption[object].Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"), object)
+	}
+}
+
+func TestState_RemoveResources(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test.")
+	}
+
+	env := test.Init(t)
+
+	err := testUtil.SetMultiEnvs(map[string]string{
+		"AWS_PROFILE": env.AWSProfile1,
+		"AWS_REGION":  env.AWSRegion1,
+	})
+	require.NoError(t, err)
+
+	defer testUtil.UnsetAWSEnvs()
+
+	awsProvider, err := provider.Init("aws", ".terradozer", 10*time.Second)
+	require.NoError(t, err)
+
+	original, err := ioutil.ReadFile("../../test/test-fixtures/tfstates/dependencies.tfstate")
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "terradozer")
+	require.NoError(t, err)
+
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "terraform.tfstate")
+	require.NoError(t, ioutil.WriteFile(path, original, 0600))
+
+	s, err := state.New(path)
+	require.NoError(t, err)
+
+	resources, err := s.Resources(map[string]*provider.TerraformProvider{"aws": awsProvider})
+	require.NoError(t, err)
+
+	var resourcesToRemove []resource.DestroyableResource
+
+	for _, r := range resources {
+		if r.Type() == "aws_instance" {
+			resourcesToRemove = append(resourcesToRemove, r.(resource.DestroyableResource))
+		}
+	}
+
+	require.Len(t, resourcesToRemove, 1)
+
+	// resources not in the state are ignored
+	other := resource.New("aws_instance", "i-other", nil, awsProvider)
+
+	actualNumOfRemovedResources := s.RemoveResources(append(resourcesToRemove, other))
+	assert.Equal(t, 1, actualNumOfRemovedResources)
+
+	_, err = s.Write()
+	require.NoError(t, err)
+
+	actualContent, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	for _, r := range readStateFileContent(t, actualContent).Resources {
+		assert.NotEqual(t, "aws_instance", r.Type)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/terradozer/pkg/plan"
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/state"
+)
+
+const (
+	commandPlan  = "plan"
+	commandApply = "apply"
+)
+
+// parseCommand returns the command (plan or apply) given as first argument and the remaining arguments.
+// If no command is given, the command is empty and all arguments are returned.
+func parseCommand(args []string) (string, []string) {
+	if len(args) > 0 && (args[0] == commandPlan || args[0] == commandApply) {
+		return args[0], args[1:]
+	}
+
+	return "", args
+}
+
+// absLocation returns the absolute path of a location on the local filesystem, so that a plan
+// can be applied from any directory. S3 URLs are returned as is.
+func absLocation(location string) (string, error) {
+	if strings.HasPrefix(location, "s3://") {
+		return location, nil
+	}
+
+	return filepath.Abs(location)
+}
+
+// newPlan creates a plan to destroy the given resources with refreshed state (i.e., that still exist),
+// grouped by the states they have been found in. Protected resources are not part of the plan.
+func newPlan(tfstates []*state.State, resourcesByState map[*state.State][]terraform.UpdatableResource,
+	existing []terraform.UpdatableResource, protected map[resource.DestroyableResource]string) (*plan.Plan, error) {
+	result := plan.New()
+
+	for _, tfstate := range tfstates {
+		s := plan.State{
+			Location:  tfstate.Location(),
+			Lineage:   tfstate.Lineage(),
+			Serial:    tfstate.Serial(),
+			Resources: []plan.Resource{},
+		}
+
+		for _, r := range filterExisting(resourcesByState[tfstate], existing) {
+			if _, ok := protected[r.(resource.DestroyableResource)]; ok {
+				continue
+			}
+
+			planned, err := plan.NewResource(r, providerConfigOf(tfstate)(r))
+			if err != nil {
+				return nil, err
+			}
+
+			s.Resources = append(s.Resources, planned)
+		}
+
+		result.States = append(result.States, s)
+	}
+
+	return result, nil
+}
+
+// writePlan writes a plan to destroy the given resources with refreshed state (see newPlan) to a file
+// at the given path.
+func writePlan(path string, found *stateResources, existing []terraform.UpdatableResource,
+	protected map[resource.DestroyableResource]string) error {
+	p, err := newPlan(found.states, found.byState, existing, protected)
+	if err != nil {
+		return err
+	}
+
+	return p.Write(path)
+}
+
+// planOfState returns the part of the given plan for a state, after checking that the state hasn't changed
+// since the plan has been created.
+func planOfState(p *plan.Plan, tfstate *state.State) (plan.State, error) {
+	s, ok := p.State(tfstate.Location())
+	if !ok {
+		return plan.State{}, fmt.Errorf("state is not part of the plan: %s", tfstate.Location())
+	}
+
+	err := s.CheckUnchanged(tfstate.Lineage(), tfstate.Serial())
+	if err != nil {
+		return plan.State{}, err
+	}
+
+	return s, nil
+}
+
+// selectPlannedResources returns the given resources with refreshed state (i.e., that still exist)
+// that are part of the plan of their state (see planOfState). An error is returned if the state of
+// any of them has changed since the plan has been created.
+func selectPlannedResources(plannedStates map[*state.State]plan.State, tfstates []*state.State,
+	resourcesByState map[*state.State][]terraform.UpdatableResource,
+	existing []terraform.UpdatableResource) ([]terraform.UpdatableResource, error) {
+	var result []terraform.UpdatableResource
+
+	for _, tfstate := range tfstates {
+		s, ok := plannedStates[tfstate]
+		if !ok {
+			return nil, fmt.Errorf("state is not part of the plan: %s", tfstate.Location())
+		}
+
+		selected, err := s.Select(filterExisting(resourcesByState[tfstate], existing), providerConfigOf(tfstate))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, selected...)
+	}
+
+	return result, nil
+}
+
+// providerConfigOf returns a function that returns the address of the provider configuration
+// managing a resource of the given state.
+func providerConfigOf(tfstate *state.State) plan.ProviderOf {
+	return func(r terraform.UpdatableResource) string {
+		provider, _ := tfstate.ProviderConfigOf(r.(resource.DestroyableResource))
+
+		return provider
+	}
+}
+
+// filterExisting returns the given resources that are part of the existing ones.
+func filterExisting(resources, existing []terraform.UpdatableResource) []terraform.UpdatableResource {
+	stillExists := map[terraform.UpdatableResource]bool{}
+	for _, r := range existing {
+		stillExists[r] = true
+	}
+
+	var result []terraform.UpdatableResource
+
+	for _, r := range resources {
+		if stillExists[r] {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,622 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/hashicorp/terraform/configs/configschema"
+	"github.com/hashicorp/terraform/plugin/discovery"
+	"github.com/jckuester/awstools-lib/terraform/provider"
+	"github.com/jckuester/terradozer/internal"
+	"github.com/jckuester/terradozer/pkg/plugin"
+	"github.com/jckuester/terradozer/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/state"
+	"github.com/zclconf/go-cty/cty"
+)
+
+//nolint:gochecknoglobals
+var (
+	// providerVersions are the default versions of providers of the default namespace, which can be launched
+	// in any other version (see resolveProviderVersions).
+	providerVersions = map[string]string{
+		"aws": "3.42.0",
+	}
+	// providerEnv are the environment variables that configure the attributes of the same name of providers
+	// of the default namespace; all other attributes are left to the defaults of the provider.
+	providerEnv = map[string]map[string]string{
+		"aws": {
+			"access_key":              "AWS_ACCESS_KEY_ID",
+			"profile":                 "AWS_PROFILE",
+			"region":                  "AWS_REGION",
+			"secret_key":              "AWS_SECRET_ACCESS_KEY",
+			"shared_credentials_file": "AWS_SHARED_CREDENTIALS_FILE",
+			"token":                   "AWS_SESSION_TOKEN",
+		},
+	}
+)
+
+// setupProviders launches the providers of the given states (see initProviders) in the versions to launch them in
+// (see resolveProviderVersions). Resources of providers without version can't be destroyed and are returned
+// as ignored.
+func setupProviders(tfstates []*state.State, settings map[string]internal.ProviderSettings,
+	o options) (map[*state.State]map[string]*provider.TerraformProvider,
+	map[*provider.TerraformProvider]*plugin.Upgrader, []*resource.Resource, error) {
+	providersOfStates, err := state.ProvidersOf(tfstates)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read Terraform state file: %s", err)
+	}
+
+	versionedProviders, unversionedProviders, err := resolveProviderVersions(providersOfStates,
+		o.dependencyLockFile, o.providerVersions)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to resolve versions of Terraform providers: %s", err)
+	}
+
+	ignoredResources, err := resourcesOfProviders(tfstates, unversionedProviders)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to read Terraform state file: %s", err)
+	}
+
+	if len(ignoredResources) > 0 {
+		logIgnoredResources(ignoredResources)
+	}
+
+	plugins, err := findPlugins(versionedProviders, plugin.Source{
+		Dirs:       o.pluginDirs,
+		MirrorURL:  o.pluginMirror,
+		InstallDir: "~/.terradozer",
+	})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to find Terraform provider plugins: %s", err)
+	}
+
+	providers, upgraders, err := initProviders(tfstates, settings, plugins, o.timeoutDuration)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to initialize Terraform providers: %s", err)
+	}
+
+	return providers, upgraders, ignoredResources, nil
+}
+
+// resolveProviderVersions returns the given providers (see state.ProvidersOf) in the version to launch them in.
+//
+// Versions given via flags or the config file take precedence over the ones selected in the given dependency lock
+// file of Terraform (if any), which take precedence over the default versions of providers of the default
+// namespace (on any registry, e.g., also registry.opentofu.org/hashicorp/aws). Versions are given either by
+// provider name (e.g., aws), which applies to a provider of any source, or by source address
+// (e.g., registry.example.com/acme/widgets), which takes precedence. All versions are exact versions.
+//
+// Providers without any version are returned separately (as unversioned), as they can't be launched.
+func resolveProviderVersions(providers []plugin.Provider, lockFile string,
+	versions map[string]string) ([]plugin.Provider, []plugin.Provider, error) {
+	versionsByName := map[string]string{}
+	versionsByAddress := map[string]string{}
+
+	for key, version := range versions {
+		version = strings.TrimPrefix(version, "v")
+
+		if !strings.Contains(key, "/") {
+			versionsByName[key] = version
+			continue
+		}
+
+		p, err := plugin.ParseProvider(key)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		versionsByAddress[p.Address()] = version
+	}
+
+	lockedVersions := map[string]string{}
+
+	if lockFile != "" {
+		lockedProviders, err := plugin.ReadLockFile(lockFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, p := range lockedProviders {
+			lockedVersions[p.Address()] = p.Version
+		}
+	}
+
+	var result, unversioned []plugin.Provider
+
+	for _, p := range providers {
+		p.Version = lookupProviderVersion(p, versionsByAddress, versionsByName, lockedVersions)
+
+		if p.Version == "" {
+			log.WithField("provider", p.Address()).Warn(internal.Pad("no version of provider given"))
+
+			unversioned = append(unversioned, p)
+
+			continue
+		}
+
+		_, err := discovery.VersionStr(p.Version).Parse()
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid version of provider (%s): %s", p.Address(), p.Version)
+		}
+
+		result = append(result, p)
+	}
+
+	return result, unversioned, nil
+}
+
+// lookupProviderVersion returns the version of the given provider by precedence (see resolveProviderVersions),
+// or an empty string if there is none.
+func lookupProviderVersion(p plugin.Provider, versionsByAddress, versionsByName,
+	lockedVersions map[string]string) string {
+	if version, ok := versionsByAddress[p.Address()]; ok {
+		return version
+	}
+
+	if version, ok := versionsByName[p.Type]; ok {
+		return version
+	}
+
+	if version, ok := lockedVersions[p.Address()]; ok {
+		return version
+	}
+
+	if p.Namespace == plugin.DefaultNamespace {
+		return providerVersions[p.Type]
+	}
+
+	return ""
+}
+
+// resourcesOfProviders returns the resources of the given providers in any of the given states.
+func resourcesOfProviders(tfstates []*state.State, providers []plugin.Provider) ([]*resource.Resource, error) {
+	if len(providers) == 0 {
+		return nil, nil
+	}
+
+	var result []*resource.Resource
+
+	for _, tfstate := range tfstates {
+		resources, err := tfstate.ResourcesOfProviders(providers)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, resources...)
+	}
+
+	return result, nil
+}
+
+// providerPlugin is the plugin binary of a provider, found in the install directory to launch the provider from.
+type providerPlugin struct {
+	provider   plugin.Provider
+	installDir string
+}
+
+// findPlugins looks up the plugin binaries of the given providers (see resolveProviderVersions) in the given source.
+// Returns the plugin of each provider by source address (see initProviders).
+func findPlugins(providers []plugin.Provider, source plugin.Source) (map[string]providerPlugin, error) {
+	dirs, err := source.Find(providers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]providerPlugin{}
+	for p, dir := range dirs {
+		result[p.Address()] = providerPlugin{provider: p, installDir: dir}
+	}
+
+	return result, nil
+}
+
+// initProviders initializes a provider for each provider configuration of the given states (see
+// state.ProviderConfigs), configured with the region and profile of the given settings (see readProviderSettings).
+// Provider configurations of modules without settings inherit the ones of their parent module; all others without
+// settings are configured via the environment. Configurations of the same provider with the same settings share
+// a provider, also across states.
+//
+// The plugin of each provider is launched from the given plugins by source address (see findPlugins), so that
+// states can use the same type of provider from different sources. Returns the providers of each state keyed
+// by address of a provider configuration, and an upgrader of resource states for each provider
+// (see state.SetUpgraders).
+func initProviders(tfstates []*state.State, settings map[string]internal.ProviderSettings,
+	plugins map[string]providerPlugin, timeout time.Duration) (map[*state.State]map[string]*provider.TerraformProvider,
+	map[*provider.TerraformProvider]*plugin.Upgrader, error) {
+	result := map[*state.State]map[string]*provider.TerraformProvider{}
+	providersBySettings := map[string]*provider.TerraformProvider{}
+	upgraders := map[*provider.TerraformProvider]*plugin.Upgrader{}
+	warned := map[string]bool{}
+
+	for _, tfstate := range tfstates {
+		providers := map[string]*provider.TerraformProvider{}
+
+		for _, address := range tfstate.ProviderConfigs() {
+			key, name, err := state.ParseProviderConfig(address)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			source, err := tfstate.ProviderOf(key)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to get provider of state (%s): %s", tfstate.Location(), err)
+			}
+
+			s, ok := lookupProviderSettings(settings, key)
+			if !ok && key != name && !warned[key] {
+				log.WithField("provider", key).Warn(internal.Pad("no region or profile given for provider " +
+					"configuration (using the environment)"))
+
+				warned[key] = true
+			}
+
+			instanceKey := fmt.Sprintf("%s/%s/%s", source.Address(), s.Region, s.Profile)
+
+			p, ok := providersBySettings[instanceKey]
+			if !ok {
+				err := withProviderEnv(s, func() error {
+					var path string
+					var err error
+					p, path, err = launchProvider(name, plugins[source.Address()], timeout)
+
+					if p != nil {
+						upgraders[p] = plugin.NewUpgrader(path)
+					}
+
+					return err
+				})
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to initialize provider (%s): %s", key, err)
+				}
+
+				providersBySettings[instanceKey] = p
+			}
+
+			// provider is not (yet) supported
+			if p == nil {
+				continue
+			}
+
+			log.WithFields(log.Fields{
+				"provider": key,
+				"source":   source.Address(),
+				"region":   s.Region,
+				"profile":  s.Profile,
+			}).Debug(internal.Pad("initialized provider"))
+
+			providers[key] = p
+		}
+
+		result[tfstate] = providers
+	}
+
+	return result, upgraders, nil
+}
+
+// launchProvider launches and configures the provider with the given name from the given plugin.
+// Returns nil if there is no plugin for the provider (see resolveProviderVersions), otherwise also the path of
+// the plugin binary.
+//
+// Unlike provider.Init of awstools-lib, which only supports a fixed version of the AWS provider, the configuration
+// is derived from the schema of the provider (see providerConfig), so that any provider in any version can
+// be launched.
+func launchProvider(name string, pp providerPlugin, timeout time.Duration) (*provider.TerraformProvider, string,
+	error) {
+	if pp.provider.Version == "" {
+		log.WithField("name", name).Debug(internal.Pad("ignoring resources of (yet) unsupported provider"))
+		return nil, "", nil
+	}
+
+	// finds the plugin in the install directory, where it has been looked up before
+	// (only providers of the default registry and namespace are downloaded otherwise)
+	meta, err := provider.Install(name, pp.provider.Version, pp.installDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to install provider (%s): %s", pp.provider, err)
+	}
+
+	p, err := provider.Launch(meta.Path, timeout)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to launch provider (%s): %s", meta.Path, err)
+	}
+
+	err = p.Configure(providerConfig(pp.provider, p.GetSchema().Provider.Block))
+	if err != nil {
+		_ = p.Close()
+		return nil, "", fmt.Errorf("failed to configure provider (%s): %s", pp.provider, err)
+	}
+
+	log.WithFields(log.Fields{
+		"provider": pp.provider.Address(),
+		"version":  meta.Version,
+	}).Debug(internal.Pad("configured provider"))
+
+	return p, meta.Path, nil
+}
+
+// providerConfig returns the configuration of the given provider for the given schema of the provider
+// configuration. Attributes of providers of the default namespace are set from the environment (see providerEnv);
+// all others are left unknown, i.e., to the defaults of the provider.
+func providerConfig(p plugin.Provider, schema *configschema.Block) cty.Value {
+	if schema == nil {
+		return cty.EmptyObjectVal
+	}
+
+	var env map[string]string
+	if p.Namespace == plugin.DefaultNamespace {
+		env = providerEnv[p.Type]
+	}
+
+	config := map[string]cty.Value{}
+
+	for attr := range schema.Attributes {
+		if name, ok := env[attr]; ok {
+			config[attr] = cty.StringVal(os.Getenv(name))
+			continue
+		}
+
+		config[attr] = cty.UnknownVal(cty.DynamicPseudoType)
+	}
+
+	for block := range schema.BlockTypes {
+		config[block] = cty.UnknownVal(cty.DynamicPseudoType)
+	}
+
+	return cty.ObjectVal(config)
+}
+
+// lookupAWSAccounts returns the AWS accounts (and regions) that the given AWS providers
+// (see initProviders) are configured for, by looking up the caller identity with the same settings.
+func lookupAWSAccounts(providers map[*state.State]map[string]*provider.TerraformProvider,
+	settings map[string]internal.ProviderSettings) ([]internal.AWSAccount, error) {
+	var providerConfigs []string
+
+	found := map[string]bool{}
+
+	for _, providersOfState := range providers {
+		for address := range providersOfState {
+			if !found[address] {
+				found[address] = true
+				providerConfigs = append(providerConfigs, address)
+			}
+		}
+	}
+
+	sort.Strings(providerConfigs)
+
+	var result []internal.AWSAccount
+
+	accountsBySettings := map[internal.ProviderSettings]int{}
+
+	for _, address := range providerConfigs {
+		_, name, err := state.ParseProviderConfig(address)
+		if err != nil {
+			return nil, err
+		}
+
+		if name != "aws" {
+			continue
+		}
+
+		s, _ := lookupProviderSettings(settings, address)
+
+		if i, ok := accountsBySettings[s]; ok {
+			result[i].Providers = append(result[i].Providers, address)
+
+			continue
+		}
+
+		var account internal.AWSAccount
+
+		err = withProviderEnv(s, func() error {
+			sess, err := session.NewSessionWithOptions(session.Options{
+				SharedConfigState: session.SharedConfigEnable,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to create AWS session: %s", err)
+			}
+
+			account.Region = aws.StringValue(sess.Config.Region)
+
+			stsConfig := aws.NewConfig()
+			if account.Region == "" {
+				// STS is a global service
+				stsConfig = stsConfig.WithRegion("us-east-1")
+			}
+
+			account.ID, err = internal.LookupAWSAccountID(sts.New(sess, stsConfig))
+
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up AWS account of provider (%s): %s", address, err)
+		}
+
+		account.Providers = []string{address}
+
+		accountsBySettings[s] = len(result)
+		result = append(result, account)
+	}
+
+	return result, nil
+}
+
+// checkAccounts returns the AWS accounts that the given providers are configured for (see lookupAWSAccounts)
+// and checks them against the account guard.
+//
+// Looking up accounts calls AWS STS, which is only done if needed by the account guard or to show the accounts
+// in the confirmation prompt (e.g., not for offline runs in force mode). If the accounts are only shown,
+// a failed lookup is logged as a warning.
+func checkAccounts(providers map[*state.State]map[string]*provider.TerraformProvider,
+	settings map[string]internal.ProviderSettings, o options) ([]internal.AWSAccount, error) {
+	if o.accountGuard.IsEmpty() && (o.force || o.dryRun) {
+		return nil, nil
+	}
+
+	accounts, err := lookupAWSAccounts(providers, settings)
+	if err != nil {
+		if !o.accountGuard.IsEmpty() || o.confirmMode == internal.ConfirmAccountID {
+			return nil, err
+		}
+
+		log.WithError(err).Warn(internal.Pad("unable to show AWS accounts"))
+	}
+
+	err = o.accountGuard.Check(accounts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range accounts {
+		log.WithFields(log.Fields{
+			"account":   a.ID,
+			"region":    a.Region,
+			"providers": strings.Join(a.Providers, ","),
+		}).Info(internal.Pad("using AWS account"))
+	}
+
+	return accounts, nil
+}
+
+// lookupProviderSettings returns the settings of the given provider configuration or the ones
+// it inherits from a parent module.
+func lookupProviderSettings(settings map[string]internal.ProviderSettings,
+	providerConfig string) (internal.ProviderSettings, bool) {
+	for {
+		if s, ok := settings[providerConfig]; ok {
+			return s, true
+		}
+
+		parent, ok := state.InheritedProviderConfig(providerConfig)
+		if !ok {
+			return internal.ProviderSettings{}, false
+		}
+
+		providerConfig = parent
+	}
+}
+
+// withProviderEnv calls f with the environment variables set that configure the region and profile
+// of a provider (see provider.Init); the previous environment is restored afterwards. Static credentials
+// from the environment are not passed on for a given profile, as they would take precedence.
+func withProviderEnv(s internal.ProviderSettings, f func() error) error {
+	env := map[string]*string{}
+
+	if s.Region != "" {
+		env["AWS_REGION"] = &s.Region
+	}
+
+	if s.Profile != "" {
+		env["AWS_PROFILE"] = &s.Profile
+		env["AWS_ACCESS_KEY_ID"] = nil
+		env["AWS_SECRET_ACCESS_KEY"] = nil
+		env["AWS_SESSION_TOKEN"] = nil
+	}
+
+	for name, value := range env {
+		previous, ok := os.LookupEnv(name)
+
+		if value == nil {
+			_ = os.Unsetenv(name)
+		} else {
+			_ = os.Setenv(name, *value)
+		}
+
+		defer func(name string) {
+			if ok {
+				_ = os.Setenv(name, previous)
+			} else {
+				_ = os.Unsetenv(name)
+			}
+		}(name)
+	}
+
+	return f()
+}
+
+// closeProviders closes all given providers, each shared one only once, and the given upgraders.
+func closeProviders(providers map[*state.State]map[string]*provider.TerraformProvider,
+	upgraders map[*provider.TerraformProvider]*plugin.Upgrader) {
+	closed := map[*provider.TerraformProvider]bool{}
+
+	for _, providersOfState := range providers {
+		for _, p := range providersOfState {
+			if closed[p] {
+				continue
+			}
+
+			_ = p.Close()
+			closed[p] = true
+		}
+	}
+
+	for _, u := range upgraders {
+		_ = u.Close()
+	}
+}
+
+// stateUpgraders returns the given upgraders as upgraders of resource states (see state.SetUpgraders).
+func stateUpgraders(
+	upgraders map[*provider.TerraformProvider]*plugin.Upgrader) map[*provider.TerraformProvider]state.StateUpgrader {
+	result := map[*provider.TerraformProvider]state.StateUpgrader{}
+	for p, u := range upgraders {
+		result[p] = u
+	}
+
+	return result
+}
+
+// readProviderSettings reads the settings of provider configurations from the given file (if any)
+// and overrides them with the regions and profiles given via flags.
+//
+// Returns the settings keyed by address of a provider configuration in the form of state.ProviderConfigs.
+func readProviderSettings(path string, regions, profiles map[string]string) (map[string]internal.ProviderSettings,
+	error) {
+	result := map[string]internal.ProviderSettings{}
+
+	if path != "" {
+		settings, err := internal.ReadProviderSettings(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for address, s := range settings {
+			key, _, err := state.ParseProviderConfig(address)
+			if err != nil {
+				return nil, err
+			}
+
+			result[key] = s
+		}
+	}
+
+	for address, region := range regions {
+		key, _, err := state.ParseProviderConfig(address)
+		if err != nil {
+			return nil, err
+		}
+
+		s := result[key]
+		s.Region = region
+		result[key] = s
+	}
+
+	for address, profile := range profiles {
+		key, _, err := state.ParseProviderConfig(address)
+		if err != nil {
+			return nil, err
+		}
+
+		s := result[key]
+		s.Profile = profile
+		result[key] = s
+	}
+
+	return result, nil
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -25,19 +26,96 @@ const (
 Terraform destroy using only the state - no *.tf files needed.
 
 USAGE:
-  $ terradozer [flags] <path/to/terraform.tfstate>
+  $ terradozer [flags] <path/to/terraform.tfstate>...
+  $ terradozer [flags] s3://<bucket>/<path/to/terraform.tfstate>
+  $ terradozer -recursive [flags] <path/to/dir/ | s3://<bucket>/<prefix/>>
+  $ terradozer -config terradozer.hcl [flags]
+  $ terradozer plan -out <plan.json> [flags] <path/to/terraform.tfstate>...
+  $ terradozer apply [flags] <plan.json>
 
 FLAGS:
+  -allowed-account-ids IDs
+    	Only destroy resources if all AWS providers are configured for one of the given account IDs
+  -config file
+    	Read settings from a config file (e.g., terradozer.hcl); flags given on the command line take precedence
+  -confirm string
+    	What to type to confirm deletion: yes, account-id (of the AWS account), state (location of the state file), or count (number of resources to be deleted) (default "yes")
   -debug
     	Enable debug logging
+  -dependency-lock-file file
+    	Launch providers in the versions selected in a Terraform dependency lock file (.terraform.lock.hcl)
   -dry-run
     	Show what would be destroyed
+  -exclude pattern
+    	Do not destroy resources whose address matches a glob pattern
+  -exclude-module pattern
+    	Do not destroy resources of modules (incl. nested ones) whose path matches a glob pattern
+  -exclude-type pattern
+    	Do not destroy resources whose type matches a glob pattern
+  -forbidden-account-ids IDs
+    	Never destroy resources if any AWS provider is configured for one of the given account IDs
   -force
     	Destroy without asking for confirmation
+  -include pattern
+    	Only destroy resources whose address matches a glob pattern (e.g., 'module.app.aws_instance.*')
+  -include-module pattern
+    	Only destroy resources of modules (incl. nested ones) whose path matches a glob pattern (e.g., 'module.app')
+  -include-type pattern
+    	Only destroy resources whose type matches a glob pattern (e.g., 'aws_instance')
+  -interactive
+    	Select the resources to delete from the list of resources found (requires a terminal)
+  -lock
+    	Lock the state file (like Terraform does) while destroying its resources (default true)
+  -lock-table table
+    	Name of the DynamoDB table to lock state files in S3 with (i.e., dynamodb_table of the S3 backend)
+  -lock-timeout duration
+    	Amount of time to retry acquiring the lock of a state file
+  -log-format format
+    	Log format (text or json); json writes one JSON object per event to stderr (default "text")
+  -max-attempts int
+    	Maximum number of attempts to destroy a resource that failed with a retryable error (0 means no limit); enables retries with backoff (default then: 5)
+  -max-retry-duration duration
+    	Maximum amount of time to retry destroying a resource (0 means no limit); enables retries with backoff (default then: 5m)
+  -out file
+    	Write a plan of the resources that would be deleted to a file (plan command only)
+  -output format
+    	Output format of the results (text or json); json writes a report of all resources to stdout (default "text")
   -parallel int
     	Limit the number of concurrent destroy operations (default 10)
+  -plugin-dir dir
+    	Look up provider plugins in a dir (with plugin binaries or a Terraform filesystem mirror) instead of downloading them from the registry
+  -plugin-mirror url
+    	Download provider plugins from a Terraform network mirror at url instead of the registry
+  -protect pattern
+    	Never destroy resources whose address matches a glob pattern
+  -protect-id pattern
+    	Never destroy resources whose ID matches a glob pattern
+  -protect-tag key=pattern
+    	Never destroy resources with a tag whose value matches a glob pattern, given as key=pattern
+  -protect-type pattern
+    	Never destroy resources whose type matches a glob pattern (e.g., 'aws_kms_key')
+  -provider-profile provider=profile
+    	AWS profile of a provider configuration as provider=profile (e.g., 'aws.prod=prod')
+  -provider-region provider=region
+    	Region of a provider configuration as provider=region (e.g., 'aws.us_east_1=us-east-1')
+  -provider-version provider=version
+    	Exact version of a provider by name or source address as provider=version (e.g., 'aws=3.74.0' or 'registry.example.com/acme/widgets=0.1.0'); overrides the dependency lock file
+  -providers file
+    	JSON file mapping provider configurations (e.g., 'aws.us_east_1') to their region and profile
+  -recursive
+    	Destroy resources of all state files (*.tfstate) found under a given directory or S3 prefix
+  -retry-backoff duration
+    	Delay before retrying to destroy a resource the first time, which is doubled with each further retry; enables retries with backoff (default then: 2s)
+  -retry-backoff-cap duration
+    	Maximum delay between two attempts to destroy a resource (0 means no limit); enables retries with backoff (default then: 30s)
+  -retry-jitter float
+    	Fraction (between 0 and 1) by which each retry delay is randomly reduced; enables retries with backoff (default then: 0.2)
   -timeout string
     	Amount of time to wait for a destroy of a resource to finish (default "30s")
+  -type-timeout type=duration
+    	Amount of time to wait for a destroy of resources of a type to finish, given as type=duration (e.g., 'aws_db_instance=40m'); overrides -timeout and the timeouts block of a resource in the state
+  -update-state
+    	Remove destroyed resources from the state file and write it back (keeps a timestamped backup of the original)
   -version
     	Show application version
 `
@@ -49,11 +127,14 @@ func TestAcc_ConfirmDeletion(t *testing.T) {
 	}
 
 	tests := []struct {
-		name                    string
-		userInput               string
+		name      string
+		userInput string
+		// notTerminal is true if the user input is piped to stdin instead of typed into a terminal
+		notTerminal             bool
 		expectResourceIsDeleted bool
 		expectedLogs            []string
 		unexpectedLogs          []string
+		expectedErrCode         int
 	}{
 		{
 			name:                    "confirmed with YES",
@@ -62,7 +143,8 @@ func TestAcc_ConfirmDeletion(t *testing.T) {
 			expectedLogs: []string{
 				"SHOWING RESOURCES THAT WOULD BE DELETED (DRY RUN)",
 				"TOTAL NUMBER OF RESOURCES THAT WOULD BE DELETED: 1",
-				"Are you sure you want to delete these resources (cannot be undone)? Only YES will be accepted.",
+				"Are you sure you want to delete these resources in AWS account",
+				"(cannot be undone)? Only YES will be accepted.",
 				"STARTING TO DELETE RESOURCES",
 				"TOTAL NUMBER OF DELETED RESOURCES: 1",
 			},
@@ -73,12 +155,29 @@ func TestAcc_ConfirmDeletion(t *testing.T) {
 			expectedLogs: []string{
 				"SHOWING RESOURCES THAT WOULD BE DELETED (DRY RUN)",
 				"TOTAL NUMBER OF RESOURCES THAT WOULD BE DELETED: 1",
-				"Are you sure you want to delete these resources (cannot be undone)? Only YES will be accepted.",
+				"(cannot be undone)? Only YES will be accepted.",
+			},
+			unexpectedLogs: []string{
+				"STARTING TO DELETE RESOURCES",
+				"TOTAL NUMBER OF DELETED RESOURCES:",
+			},
+		},
+		{
+			name:        "confirmation piped to stdin is rejected",
+			userInput:   "YES\n",
+			notTerminal: true,
+			expectedLogs: []string{
+				"SHOWING RESOURCES THAT WOULD BE DELETED (DRY RUN)",
+				"TOTAL NUMBER OF RESOURCES THAT WOULD BE DELETED: 1",
+				"refusing to read confirmation from /dev/stdin, which is not a terminal " +
+					"(use -force to delete without confirmation)",
 			},
 			unexpectedLogs: []string{
+				"Are you sure you want to delete these resources",
 				"STARTING TO DELETE RESOURCES",
 				"TOTAL NUMBER OF DELETED RESOURCES:",
 			},
+			expectedErrCode: 1,
 		},
 	}
 	for _, tc := range tests {
@@ -107,8 +206,19 @@ func TestAcc_ConfirmDeletion(t *testing.T) {
 
 			defer os.Remove(tfstateFile)
 
-			logBuffer, err := runBinary(t, tc.userInput, tfstateFile)
-			require.NoError(t, err)
+			var logBuffer *bytes.Buffer
+
+			if tc.notTerminal {
+				logBuffer, err = runBinary(t, tc.userInput, tfstateFile)
+			} else {
+				logBuffer, err = runBinaryInTerminal(t, tc.userInput, tfstateFile)
+			}
+
+			if tc.expectedErrCode > 0 {
+				require.EqualError(t, err, "exit status 1")
+			} else {
+				require.NoError(t, err)
+			}
 
 			if tc.expectResourceIsDeleted {
 				AssertVpcDeleted(t, actualVpcID, env)
@@ -158,7 +268,7 @@ func TestAcc_AllResourcesAlreadyDeleted(t *testing.T) {
 	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
 	defer os.Remove(tfstateFile)
 
-	_, err = runBinary(t, "YES\n", tfstateFile)
+	_, err = runBinaryInTerminal(t, "YES\n", tfstateFile)
 	require.NoError(t, err)
 
 	AssertVpcDeleted(t, actualVpcID, env)
@@ -290,7 +400,7 @@ func TestAcc_DryRun(t *testing.T) {
 				args = []string{tc.flag, tfstateFile}
 			}
 
-			logBuffer, err := runBinary(t, "YES\n", args...)
+			logBuffer, err := runBinaryInTerminal(t, "YES\n", args...)
 
 			require.NoError(t, err)
 
@@ -315,6 +425,125 @@ func TestAcc_DryRun(t *testing.T) {
 	}
 }
 
+func TestAcc_ProtectedResource(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping acceptance testUtil.")
+	}
+
+	env := testUtil.Init(t)
+
+	err := testUtil.SetMultiEnvs(map[string]string{
+		"AWS_PROFILE": env.AWSProfile1,
+		"AWS_REGION":  env.AWSRegion1,
+	})
+	require.NoError(t, err)
+
+	terraformDir := "./test-fixtures/single-resource/aws-vpc"
+
+	terraformOptions := testUtil.GetTerraformOptions(TfStateBucket, terraformDir, env)
+
+	defer terraform.Destroy(t, terraformOptions)
+
+	terraform.InitAndApply(t, terraformOptions)
+
+	actualVpcID := terraform.Output(t, terraformOptions, "vpc_id")
+	aws.GetVpcById(t, actualVpcID, env.AWSRegion1)
+
+	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
+	defer os.Remove(tfstateFile)
+
+	logBuffer, err := runBinary(t, "", "-protect-type", "aws_vpc", tfstateFile)
+	require.NoError(t, err)
+
+	AssertVpcExists(t, actualVpcID, env)
+
+	actualLogs := logBuffer.String()
+
+	assert.Contains(t, actualLogs, "PROTECTED RESOURCES THAT WILL NOT BE DELETED: 1")
+	assert.Contains(t, actualLogs, "ALL RESOURCES ARE PROTECTED")
+	assert.NotContains(t, actualLogs, "STARTING TO DELETE RESOURCES")
+
+	fmt.Println(actualLogs)
+}
+
+func TestAcc_OutputJSON(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping acceptance testUtil.")
+	}
+
+	env := testUtil.Init(t)
+
+	err := testUtil.SetMultiEnvs(map[string]string{
+		"AWS_PROFILE": env.AWSProfile1,
+		"AWS_REGION":  env.AWSRegion1,
+	})
+	require.NoError(t, err)
+
+	terraformDir := "./test-fixtures/single-resource/aws-vpc"
+
+	terraformOptions := testUtil.GetTerraformOptions(TfStateBucket, terraformDir, env)
+
+	defer terraform.Destroy(t, terraformOptions)
+
+	terraform.InitAndApply(t, terraformOptions)
+
+	actualVpcID := terraform.Output(t, terraformOptions, "vpc_id")
+	aws.GetVpcById(t, actualVpcID, env.AWSRegion1)
+
+	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
+	defer os.Remove(tfstateFile)
+
+	stdout, stderr, err := runBinaryWithSeparateOutput(t, "", "-output", "json", "-force", tfstateFile)
+	require.NoError(t, err)
+
+	AssertVpcDeleted(t, actualVpcID, env)
+
+	var actualReport struct {
+		DryRun    bool `json:"dry_run"`
+		Destroyed []struct {
+			Type     string `json:"type"`
+			ID       string `json:"id"`
+			Attempts int    `json:"attempts"`
+		} `json:"destroyed"`
+		Summary struct {
+			Found     int `json:"found"`
+			Destroyed int `json:"destroyed"`
+			Failed    int `json:"failed"`
+		} `json:"summary"`
+	}
+
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &actualReport))
+
+	assert.False(t, actualReport.DryRun)
+	assert.Equal(t, 1, actualReport.Summary.Found)
+	assert.Equal(t, 1, actualReport.Summary.Destroyed)
+	assert.Equal(t, 0, actualReport.Summary.Failed)
+	require.Len(t, actualReport.Destroyed, 1)
+	assert.Equal(t, "aws_vpc", actualReport.Destroyed[0].Type)
+	assert.Equal(t, actualVpcID, actualReport.Destroyed[0].ID)
+	assert.GreaterOrEqual(t, actualReport.Destroyed[0].Attempts, 1)
+
+	assert.Contains(t, stderr.String(), "TOTAL NUMBER OF DELETED RESOURCES: 1")
+
+	fmt.Println(stderr.String())
+}
+
+func TestAcc_UnknownOutputFormat(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping acceptance testUtil.")
+	}
+
+	logBuffer, err := runBinary(t, "", "-output", "yaml", "terraform.tfstate")
+	require.Error(t, err)
+
+	actualLogs := logBuffer.String()
+
+	assert.Contains(t, actualLogs, fmt.Sprintf(`Error: unknown output format: yaml (expected text or json)
+%s`, usageMessage))
+
+	fmt.Println(actualLogs)
+}
+
 func TestAcc_Force(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping acceptance testUtil.")
@@ -390,7 +619,7 @@ func TestAcc_Force(t *testing.T) {
 			defer os.Remove(tfstateFile)
 
 			args := append(tc.flags, tfstateFile)
-			logBuffer, err := runBinary(t, "yes\n", args...)
+			logBuffer, err := runBinaryInTerminal(t, "yes\n", args...)
 
 			if tc.expectedErrCode > 0 {
 				require.EqualError(t, err, "exit status 1")
@@ -452,7 +681,7 @@ func TestAcc_DeleteDependentResources(t *testing.T) {
 	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
 	defer os.Remove(tfstateFile)
 
-	_, err = runBinary(t, "YES\n", tfstateFile)
+	_, err = runBinaryInTerminal(t, "YES\n", tfstateFile)
 	require.NoError(t, err)
 
 	AssertVpcDeleted(t, actualVpcID, env)
@@ -487,7 +716,7 @@ func TestAcc_SkipUnsupportedProvider(t *testing.T) {
 	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
 	defer os.Remove(tfstateFile)
 
-	_, err = runBinary(t, "YES\n", tfstateFile)
+	_, err = runBinaryInTerminal(t, "YES\n", tfstateFile)
 	require.NoError(t, err)
 
 	AssertVpcDeleted(t, actualVpcID, env)
@@ -536,7 +765,7 @@ func TestAcc_DeleteTimeout(t *testing.T) {
 	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
 	defer os.Remove(tfstateFile)
 
-	logBuffer, err := runBinary(t, "YES\n", "-timeout", "2s", tfstateFile)
+	logBuffer, err := runBinaryInTerminal(t, "YES\n", "-timeout", "2s", tfstateFile)
 	require.NoError(t, err)
 
 	actualLogs := logBuffer.String()
@@ -574,7 +803,7 @@ func TestAcc_DeleteNonEmptyAwsS3Bucket(t *testing.T) {
 	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
 	defer os.Remove(tfstateFile)
 
-	_, err = runBinary(t, "YES\n", tfstateFile)
+	_, err = runBinaryInTerminal(t, "YES\n", tfstateFile)
 	require.NoError(t, err)
 
 	time.Sleep(5 * time.Second)
@@ -609,7 +838,7 @@ func TestAcc_DeleteAwsIamRoleWithAttachedPolicy(t *testing.T) {
 	tfstateFile, err := WriteRemoteStateToLocalFile(t, env, terraformOptions)
 	defer os.Remove(tfstateFile)
 
-	_, err = runBinary(t, "YES\n", tfstateFile)
+	_, err = runBinaryInTerminal(t, "YES\n", tfstateFile)
 	require.NoError(t, err)
 
 	AssertIamRoleDeleted(t, actualIamRole, env)
@@ -632,3 +861,49 @@ func runBinary(t *testing.T, userInput string, args ...string) (*bytes.Buffer, e
 
 	return logBuffer, err
 }
+
+// runBinaryInTerminal is like runBinary, but the user input is typed into a (pseudo-)terminal,
+// as the confirmation to delete resources is only read from a terminal.
+func runBinaryInTerminal(t *testing.T, userInput string, args ...string) (*bytes.Buffer, error) {
+	defer gexec.CleanupBuildArtifacts()
+
+	compiledPath, err := gexec.Build(packagePath)
+	require.NoError(t, err)
+
+	logBuffer := &bytes.Buffer{}
+
+	ptmx, tty := openTerminal(t)
+
+	// buffered by the terminal until read
+	_, err = ptmx.Write([]byte(userInput))
+	require.NoError(t, err)
+
+	p := exec.Command(compiledPath, args...)
+	p.Stdin = tty
+	p.Stdout = logBuffer
+	p.Stderr = logBuffer
+
+	err = p.Run()
+
+	return logBuffer, err
+}
+
+// runBinaryWithSeparateOutput is like runBinary, but doesn't mix the output to stdout and stderr.
+func runBinaryWithSeparateOutput(t *testing.T, userInput string, args ...string) (*bytes.Buffer, *bytes.Buffer, error) {
+	defer gexec.CleanupBuildArtifacts()
+
+	compiledPath, err := gexec.Build(packagePath)
+	require.NoError(t, err)
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+
+	p := exec.Command(compiledPath, args...)
+	p.Stdin = strings.NewReader(userInput)
+	p.Stdout = stdout
+	p.Stderr = stderr
+
+	err = p.Run()
+
+	return stdout, stderr, err
+}
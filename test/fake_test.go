@@ -0,0 +1,633 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jckuester/terradozer/test/fakeprovider"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	fakeProviderState = "./test-fixtures/tfstates/fake-provider.tfstate"
+	// fakeProviderStateV0 has an aws_vpc written with schema version 0, where cidr_block was named cidr.
+	fakeProviderStateV0 = "./test-fixtures/tfstates/fake-provider-schema-v0.tfstate"
+	// fakeProviderState1x has the resources of fakeProviderState as written by Terraform v1.x.
+	fakeProviderState1x = "./test-fixtures/tfstates/fake-provider-terraform-1.x.tfstate"
+	// fakeProviderStateThirdParty has an aws_vpc and a widgets_thing of the provider
+	// registry.example.com/acme/widgets.
+	fakeProviderStateThirdParty = "./test-fixtures/tfstates/fake-provider-third-party.tfstate"
+	// fakeProviderStateOpenTofu has an aws_vpc of the provider registry.opentofu.org/hashicorp/aws.
+	fakeProviderStateOpenTofu = "./test-fixtures/tfstates/fake-provider-opentofu.tfstate"
+)
+
+// fakeProviderConfig returns the config of a fake provider with the resources in fakeProviderState,
+// where the given instances behave as configured.
+func fakeProviderConfig(instances map[string]fakeprovider.InstanceConfig) fakeprovider.Config {
+	resourceTypes := map[string]struct {
+		id         string
+		attributes []string
+	}{
+		"aws_instance": {"i-1", []string{"subnet_id"}},
+		"aws_subnet":   {"subnet-1", []string{"vpc_id"}},
+		"aws_vpc":      {"vpc-1", []string{"cidr_block"}},
+	}
+
+	config := fakeprovider.Config{
+		Resources: map[string]fakeprovider.ResourceConfig{},
+	}
+
+	for terraformType, rt := range resourceTypes {
+		rc := fakeprovider.ResourceConfig{
+			Attributes: rt.attributes,
+			Instances:  map[string]fakeprovider.InstanceConfig{},
+		}
+
+		if instance, ok := instances[rt.id]; ok {
+			rc.Instances[rt.id] = instance
+		}
+
+		config.Resources[terraformType] = rc
+	}
+
+	return config
+}
+
+// setFakeAWSEnv configures the environment such that no real AWS credentials are picked up.
+func setFakeAWSEnv(t *testing.T) {
+	for name, value := range map[string]string{
+		"AWS_ACCESS_KEY_ID":         "fake",
+		"AWS_SECRET_ACCESS_KEY":     "fake",
+		"AWS_REGION":                "us-east-1",
+		"AWS_EC2_METADATA_DISABLED": "true",
+	} {
+		t.Setenv(name, value)
+	}
+}
+
+func TestFakeProvider_Destroy(t *testing.T) {
+	tests := []struct {
+		name              string
+		flags             []string
+		instances         map[string]fakeprovider.InstanceConfig
+		expectedDestroyed []string
+		expectedLogs      []string
+	}{
+		{
+			name:              "all resources deleted",
+			flags:             []string{"-force"},
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 3",
+			},
+		},
+		{
+			name:  "dry run",
+			flags: []string{"-dry-run"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF RESOURCES THAT WOULD BE DELETED: 3",
+			},
+		},
+		{
+			name:  "resource already gone",
+			flags: []string{"-force"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"subnet-1": {Gone: true},
+			},
+			expectedDestroyed: []string{"i-1", "vpc-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 2",
+			},
+		},
+		{
+			name:  "dependent resources deleted by retrying",
+			flags: []string{"-force", "-parallel", "1", "-retry-backoff", "10ms"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"vpc-1":    {DependedOnBy: []string{"subnet-1"}},
+				"subnet-1": {DependedOnBy: []string{"i-1"}},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 3",
+			},
+		},
+		{
+			name:  "retryable error",
+			flags: []string{"-force", "-retry-backoff", "10ms"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"vpc-1": {DestroyErrors: []string{"RequestLimitExceeded: slow down", "RequestLimitExceeded: slow down"}},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 3",
+			},
+		},
+		{
+			name:  "retryable error without retry flags",
+			flags: []string{"-force"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"vpc-1": {DestroyErrors: []string{"RequestLimitExceeded: slow down"}},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 3",
+			},
+		},
+		{
+			name:  "permanent error",
+			flags: []string{"-force", "-retry-backoff", "10ms"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"vpc-1": {DestroyErrors: []string{"UnauthorizedOperation: not allowed"}},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 2",
+				"UnauthorizedOperation: not allowed",
+			},
+		},
+		{
+			name:  "destroy timed out",
+			flags: []string{"-force", "-timeout", "1s", "-max-attempts", "1"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"vpc-1": {DestroyLatency: "10s"},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 2",
+				"destroy timed out (1s)",
+			},
+		},
+		{
+			// the provider is launched with the default timeout, which doesn't limit the destroy of vpc-1
+			name:  "type timeout longer than default timeout",
+			flags: []string{"-force", "-timeout", "1s", "-type-timeout", "aws_vpc=10s", "-max-attempts", "1"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"vpc-1": {DestroyLatency: "3s"},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 3",
+			},
+		},
+		{
+			// retries wait for the timed-out destroy instead of destroying vpc-1 a second time
+			name:  "destroy timed out but finished while retrying",
+			flags: []string{"-force", "-timeout", "1s", "-retry-backoff", "10ms", "-max-attempts", "5"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				"vpc-1": {DestroyLatency: "3s"},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+			expectedLogs: []string{
+				"TOTAL NUMBER OF DELETED RESOURCES: 3",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setFakeAWSEnv(t)
+
+			installDir := fakeprovider.Setup(t, fakeProviderConfig(tc.instances))
+
+			logBuffer, err := runBinary(t, "", append(tc.flags, fakeProviderState)...)
+
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.expectedDestroyed, fakeprovider.Destroyed(t, installDir))
+
+			actualLogs := logBuffer.String()
+
+			for _, expectedLogEntry := range tc.expectedLogs {
+				assert.Contains(t, actualLogs, expectedLogEntry)
+			}
+
+			fmt.Println(actualLogs)
+		})
+	}
+}
+
+func TestFakeProvider_PluginDir(t *testing.T) {
+	setFakeAWSEnv(t)
+	fakeprovider.SetHome(t)
+
+	pluginDir := t.TempDir()
+	fakeprovider.Install(t, pluginDir, fakeProviderConfig(nil))
+
+	logBuffer, err := runBinary(t, "", "-force", "-plugin-dir", pluginDir, fakeProviderState)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"i-1", "subnet-1", "vpc-1"}, fakeprovider.Destroyed(t, pluginDir))
+	assert.Contains(t, logBuffer.String(), "TOTAL NUMBER OF DELETED RESOURCES: 3")
+	// no account is looked up via AWS STS without an account guard
+	assert.NotContains(t, logBuffer.String(), "AWS account")
+
+	fmt.Println(logBuffer.String())
+}
+
+func TestFakeProvider_AccountLookupFails(t *testing.T) {
+	setFakeAWSEnv(t)
+
+	installDir := fakeprovider.Setup(t, fakeProviderConfig(nil))
+
+	// the accounts for the confirmation prompt can't be looked up with fake credentials,
+	// which is no reason to abort without an account guard
+	logBuffer, err := runBinary(t, "", fakeProviderState)
+	require.EqualError(t, err, "exit status 1")
+
+	assert.Empty(t, fakeprovider.Destroyed(t, installDir))
+	assert.Contains(t, logBuffer.String(), "unable to show AWS accounts")
+	assert.Contains(t, logBuffer.String(), "TOTAL NUMBER OF RESOURCES THAT WOULD BE DELETED: 3")
+	assert.Contains(t, logBuffer.String(), "which is not a terminal (use -force to delete without confirmation)")
+
+	fmt.Println(logBuffer.String())
+}
+
+func TestFakeProvider_ConfirmDeletion(t *testing.T) {
+	tests := []struct {
+		name              string
+		userInput         string
+		expectedDestroyed []string
+		unexpectedLogs    []string
+	}{
+		{
+			name:              "confirmed with YES",
+			userInput:         "YES\n",
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+		},
+		{
+			name:      "confirmed with yes",
+			userInput: "yes\n",
+			unexpectedLogs: []string{
+				"STARTING TO DELETE RESOURCES",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setFakeAWSEnv(t)
+
+			installDir := fakeprovider.Setup(t, fakeProviderConfig(nil))
+
+			logBuffer, err := runBinaryInTerminal(t, tc.userInput, fakeProviderState)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.expectedDestroyed, fakeprovider.Destroyed(t, installDir))
+
+			actualLogs := logBuffer.String()
+
+			assert.Contains(t, actualLogs, "(cannot be undone)? Only YES will be accepted.")
+
+			for _, unexpectedLogEntry := range tc.unexpectedLogs {
+				assert.NotContains(t, actualLogs, unexpectedLogEntry)
+			}
+
+			fmt.Println(actualLogs)
+		})
+	}
+}
+
+func TestFakeProvider_PlanAndApply(t *testing.T) {
+	setFakeAWSEnv(t)
+
+	installDir := fakeprovider.Setup(t, fakeProviderConfig(nil))
+
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+
+	logBuffer, err := runBinary(t, "", "plan", "-out", planFile, fakeProviderState)
+	require.NoError(t, err)
+
+	assert.Empty(t, fakeprovider.Destroyed(t, installDir))
+
+	fmt.Println(logBuffer.String())
+
+	logBuffer, err = runBinary(t, "", "apply", "-force", planFile)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"i-1", "subnet-1", "vpc-1"}, fakeprovider.Destroyed(t, installDir))
+	assert.Contains(t, logBuffer.String(), "TOTAL NUMBER OF DELETED RESOURCES: 3")
+
+	fmt.Println(logBuffer.String())
+}
+
+func TestFakeProvider_PluginDir_MissingPlugin(t *testing.T) {
+	setFakeAWSEnv(t)
+	fakeprovider.SetHome(t)
+
+	emptyDir := t.TempDir()
+
+	logBuffer, err := runBinary(t, "", "-force", "-plugin-dir", emptyDir, fakeProviderState)
+	require.EqualError(t, err, "exit status 1")
+
+	assert.Contains(t, logBuffer.String(), fmt.Sprintf("Error:️ failed to find Terraform provider plugins: "+
+		"provider plugins not found in %s, ~/.terradozer: registry.terraform.io/hashicorp/aws v%s "+
+		"(terraform-provider-aws_v%s for %s_%s)", emptyDir, fakeprovider.Version, fakeprovider.Version,
+		runtime.GOOS, runtime.GOARCH))
+	assert.NotContains(t, logBuffer.String(), "STARTING TO DELETE RESOURCES")
+
+	fmt.Println(logBuffer.String())
+}
+
+func TestFakeProvider_UpgradeResourceState(t *testing.T) {
+	tests := []struct {
+		name     string
+		flags    []string
+		lockFile string
+	}{
+		{
+			name:  "version given via flag",
+			flags: []string{"-provider-version", "aws=3.74.0"},
+		},
+		{
+			name: "version selected in dependency lock file",
+			lockFile: `
+provider "registry.terraform.io/hashicorp/aws" {
+  version = "3.74.0"
+}
+`,
+		},
+		{
+			name:  "version given via flag overrides dependency lock file",
+			flags: []string{"-provider-version", "aws=3.74.0"},
+			lockFile: `
+provider "registry.terraform.io/hashicorp/aws" {
+  version = "3.42.0"
+}
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setFakeAWSEnv(t)
+			fakeprovider.SetHome(t)
+
+			config := fakeProviderConfig(nil)
+
+			vpc := config.Resources["aws_vpc"]
+			vpc.SchemaVersion = 1
+			vpc.RenamedAttributes = map[string]string{"cidr": "cidr_block"}
+			config.Resources["aws_vpc"] = vpc
+
+			// only the pinned version is available
+			pluginDir := t.TempDir()
+			fakeprovider.InstallVersion(t, pluginDir, "3.74.0", config)
+
+			flags := append([]string{"-force", "-plugin-dir", pluginDir}, tc.flags...)
+
+			if tc.lockFile != "" {
+				lockFile := filepath.Join(t.TempDir(), ".terraform.lock.hcl")
+
+				err := ioutil.WriteFile(lockFile, []byte(tc.lockFile), 0600)
+				require.NoError(t, err)
+
+				flags = append(flags, "-dependency-lock-file", lockFile)
+			}
+
+			logBuffer, err := runBinary(t, "", append(flags, fakeProviderStateV0)...)
+			require.NoError(t, err)
+
+			assert.Equal(t, []string{"vpc-1"}, fakeprovider.Destroyed(t, pluginDir))
+			assert.Contains(t, logBuffer.String(), "TOTAL NUMBER OF DELETED RESOURCES: 1")
+
+			fmt.Println(logBuffer.String())
+		})
+	}
+}
+
+func TestFakeProvider_UpdateState_S3Version(t *testing.T) {
+	setFakeAWSEnv(t)
+
+	installDir := fakeprovider.Setup(t, fakeProviderConfig(nil))
+
+	// rejected before the state is read, so no resource is destroyed
+	logBuffer, err := runBinary(t, "", "-force", "-update-state",
+		"s3://my-bucket/terraform.tfstate?versionId=abc123")
+	require.EqualError(t, err, "exit status 1")
+
+	assert.Empty(t, fakeprovider.Destroyed(t, installDir))
+	assert.Contains(t, logBuffer.String(), "-update-state flag cannot be used with a specific version of "+
+		"a state file in S3: s3://my-bucket/terraform.tfstate?versionId=abc123")
+	assert.NotContains(t, logBuffer.String(), "READING STATE")
+
+	fmt.Println(logBuffer.String())
+}
+
+func TestFakeProvider_Terraform1xState(t *testing.T) {
+	tests := []struct {
+		name              string
+		flags             []string
+		instances         map[string]fakeprovider.InstanceConfig
+		expectedDestroyed []string
+		// expectedTypes are the types of resources left in the state
+		expectedTypes []string
+	}{
+		{
+			name:              "all resources deleted",
+			flags:             []string{"-force", "-update-state"},
+			expectedDestroyed: []string{"i-1", "subnet-1", "vpc-1"},
+		},
+		{
+			name:          "dry run",
+			flags:         []string{"-dry-run"},
+			expectedTypes: []string{"aws_instance", "aws_subnet", "aws_vpc"},
+		},
+		{
+			name:  "permanent error",
+			flags: []string{"-force", "-update-state", "-retry-backoff", "10ms"},
+			instances: map[string]fakeprovider.InstanceConfig{
+				// failed in order of dependencies, then once more by trial and error
+				"vpc-1": {DestroyErrors: []string{
+					"UnauthorizedOperation: not allowed",
+					"UnauthorizedOperation: not allowed",
+				}},
+			},
+			expectedDestroyed: []string{"i-1", "subnet-1"},
+			expectedTypes:     []string{"aws_vpc"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setFakeAWSEnv(t)
+
+			installDir := fakeprovider.Setup(t, fakeProviderConfig(tc.instances))
+
+			original, err := ioutil.ReadFile(fakeProviderState1x)
+			require.NoError(t, err)
+
+			path := filepath.Join(t.TempDir(), "terraform.tfstate")
+
+			err = ioutil.WriteFile(path, original, 0600)
+			require.NoError(t, err)
+
+			logBuffer, err := runBinary(t, "", append(tc.flags, path)...)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, tc.expectedDestroyed, fakeprovider.Destroyed(t, installDir))
+
+			content, err := ioutil.ReadFile(path)
+			require.NoError(t, err)
+
+			var actualState struct {
+				Resources []struct {
+					Type     string `json:"type"`
+					Provider string `json:"provider"`
+				} `json:"resources"`
+				CheckResults []interface{} `json:"check_results"`
+			}
+
+			err = json.Unmarshal(content, &actualState)
+			require.NoError(t, err)
+
+			var actualTypes []string
+
+			for _, r := range actualState.Resources {
+				actualTypes = append(actualTypes, r.Type)
+
+				// not written back in the legacy form of Terraform v0.12
+				assert.Equal(t, `provider["registry.terraform.io/hashicorp/aws"]`, r.Provider)
+			}
+
+			assert.Equal(t, tc.expectedTypes, actualTypes)
+			assert.NotNil(t, actualState.CheckResults)
+
+			fmt.Println(logBuffer.String())
+		})
+	}
+}
+
+func TestFakeProvider_ThirdPartyProvider(t *testing.T) {
+	tests := []struct {
+		name              string
+		flags             []string
+		lockFile          string
+		expectedDestroyed []string
+		expectedLogs      []string
+	}{
+		{
+			name:              "version given via flag by source address",
+			flags:             []string{"-provider-version", "registry.example.com/acme/widgets=0.1.0"},
+			expectedDestroyed: []string{"thing-1", "vpc-1"},
+		},
+		{
+			name:              "version given via flag by provider name",
+			flags:             []string{"-provider-version", "widgets=0.1.0"},
+			expectedDestroyed: []string{"thing-1", "vpc-1"},
+		},
+		{
+			name: "version selected in dependency lock file",
+			lockFile: `
+provider "registry.example.com/acme/widgets" {
+  version = "0.1.0"
+}
+`,
+			expectedDestroyed: []string{"thing-1", "vpc-1"},
+		},
+		{
+			name:              "no version given",
+			expectedDestroyed: []string{"vpc-1"},
+			expectedLogs: []string{
+				"RESOURCES OF PROVIDERS WITHOUT VERSION THAT WILL NOT BE DELETED: 1",
+				"TOTAL NUMBER OF IGNORED RESOURCES OF PROVIDERS WITHOUT VERSION (NOT DELETED): 1",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setFakeAWSEnv(t)
+			fakeprovider.SetHome(t)
+
+			config := fakeProviderConfig(nil)
+			config.Resources["widgets_thing"] = fakeprovider.ResourceConfig{Attributes: []string{"vpc_id"}}
+
+			pluginDir := t.TempDir()
+			fakeprovider.Install(t, pluginDir, config)
+
+			// in the unpacked layout of a filesystem mirror, as the provider is not of the default registry
+			widgetsDir := filepath.Join(pluginDir, "registry.example.com", "acme", "widgets", "0.1.0",
+				runtime.GOOS+"_"+runtime.GOARCH)
+			fakeprovider.InstallPlugin(t, widgetsDir, "widgets", "0.1.0", config)
+
+			flags := append([]string{"-force", "-plugin-dir", pluginDir}, tc.flags...)
+
+			if tc.lockFile != "" {
+				lockFile := filepath.Join(t.TempDir(), ".terraform.lock.hcl")
+
+				err := ioutil.WriteFile(lockFile, []byte(tc.lockFile), 0600)
+				require.NoError(t, err)
+
+				flags = append(flags, "-dependency-lock-file", lockFile)
+			}
+
+			logBuffer, err := runBinary(t, "", append(flags, fakeProviderStateThirdParty)...)
+			require.NoError(t, err)
+
+			// the config of the fake provider installed last applies to both (see fakeprovider.InstallPlugin)
+			assert.Equal(t, tc.expectedDestroyed, fakeprovider.Destroyed(t, widgetsDir))
+			assert.Contains(t, logBuffer.String(),
+				fmt.Sprintf("TOTAL NUMBER OF DELETED RESOURCES: %d", len(tc.expectedDestroyed)))
+
+			for _, expectedLogEntry := range tc.expectedLogs {
+				assert.Contains(t, logBuffer.String(), expectedLogEntry)
+			}
+
+			fmt.Println(logBuffer.String())
+		})
+	}
+}
+
+func TestFakeProvider_SameProviderTypeFromDifferentSources(t *testing.T) {
+	setFakeAWSEnv(t)
+	fakeprovider.SetHome(t)
+
+	pluginDir := t.TempDir()
+	fakeprovider.Install(t, pluginDir, fakeProviderConfig(nil))
+
+	// in the unpacked layout of a filesystem mirror, as the provider is not of the default registry
+	openTofuDir := filepath.Join(pluginDir, "registry.opentofu.org", "hashicorp", "aws", fakeprovider.Version,
+		runtime.GOOS+"_"+runtime.GOARCH)
+	fakeprovider.InstallPlugin(t, openTofuDir, "aws", fakeprovider.Version, fakeProviderConfig(nil))
+
+	logBuffer, err := runBinary(t, "", "-force", "-plugin-dir", pluginDir,
+		fakeProviderState, fakeProviderStateOpenTofu)
+	require.NoError(t, err)
+
+	// the config of the fake provider installed last applies to both (see fakeprovider.InstallPlugin)
+	assert.ElementsMatch(t, []string{"i-1", "subnet-1", "vpc-1", "vpc-2"}, fakeprovider.Destroyed(t, openTofuDir))
+	assert.Contains(t, logBuffer.String(), "TOTAL NUMBER OF DELETED RESOURCES: 4")
+
+	fmt.Println(logBuffer.String())
+}
+
+func TestFakeProvider_ThirdPartyProvider_NoVersion(t *testing.T) {
+	setFakeAWSEnv(t)
+	fakeprovider.SetHome(t)
+
+	config := fakeProviderConfig(nil)
+	config.Resources["widgets_thing"] = fakeprovider.ResourceConfig{Attributes: []string{"vpc_id"}}
+
+	pluginDir := t.TempDir()
+	fakeprovider.Install(t, pluginDir, config)
+
+	// resources of a provider without version are listed before the user is asked for confirmation,
+	// which is not needed to skip them
+	logBuffer, err := runBinaryInTerminal(t, "YES\n", "-plugin-dir", pluginDir, fakeProviderStateThirdParty)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"vpc-1"}, fakeprovider.Destroyed(t, pluginDir))
+	assert.Contains(t, logBuffer.String(), "no version of provider given")
+	assert.Contains(t, logBuffer.String(), "RESOURCES OF PROVIDERS WITHOUT VERSION THAT WILL NOT BE DELETED: 1")
+	assert.Contains(t, logBuffer.String(), "widgets_thing")
+	assert.Contains(t, logBuffer.String(), "TOTAL NUMBER OF DELETED RESOURCES: 1")
+	assert.Contains(t, logBuffer.String(),
+		"TOTAL NUMBER OF IGNORED RESOURCES OF PROVIDERS WITHOUT VERSION (NOT DELETED): 1")
+
+	fmt.Println(logBuffer.String())
+}
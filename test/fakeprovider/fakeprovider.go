@@ -0,0 +1,306 @@
+// Package fakeprovider contains a fake Terraform AWS Provider plugin to test terradozer offline, i.e.,
+// without an AWS account. The plugin speaks the same gRPC protocol as a real provider, but its resource types
+// and the behaviour of each resource instance (e.g., whether it still exists or fails to be destroyed)
+// are configured via a JSON file (see Config).
+package fakeprovider
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// ConfigEnvVar is the environment variable with the path to the config file of the fake provider.
+const ConfigEnvVar = "TERRADOZER_FAKE_PROVIDER_CONFIG"
+
+// Config configures the resource types of the fake provider and the behaviour of their instances.
+type Config struct {
+	// Resources are the resource types of the provider (e.g., aws_vpc).
+	Resources map[string]ResourceConfig `json:"resources"`
+	// DestroyLog is the path to a file to which the ID of each destroyed resource is appended (optional).
+	DestroyLog string `json:"destroy_log,omitempty"`
+}
+
+// ResourceConfig configures the schema of a resource type and the instances of it.
+type ResourceConfig struct {
+	// Attributes are the names of the (string) attributes of the resource type, in addition to id.
+	Attributes []string `json:"attributes,omitempty"`
+	// SchemaVersion is the version of the schema of the resource type. States of older schema versions
+	// are upgraded by renaming attributes (see RenamedAttributes).
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// RenamedAttributes maps the names of attributes in older schema versions to their current names.
+	RenamedAttributes map[string]string `json:"renamed_attributes,omitempty"`
+	// Instances configures the behaviour of resource instances by ID. Instances that are not configured
+	// exist and are destroyed without error.
+	Instances map[string]InstanceConfig `json:"instances,omitempty"`
+}
+
+// InstanceConfig configures the behaviour of a resource instance.
+type InstanceConfig struct {
+	// Gone is true if the instance doesn't exist (anymore), i.e., it is not found when read or imported.
+	Gone bool `json:"gone,omitempty"`
+	// DestroyErrors are the errors returned by the first attempts to destroy the instance (one per attempt).
+	DestroyErrors []string `json:"destroy_errors,omitempty"`
+	// DependedOnBy are the IDs of instances that need to be destroyed (or be gone) first. Until then, destroying
+	// the instance fails with a DependencyViolation error.
+	DependedOnBy []string `json:"depended_on_by,omitempty"`
+	// ReadLatency is the time a read of the instance takes (e.g., "100ms").
+	ReadLatency string `json:"read_latency,omitempty"`
+	// DestroyLatency is the time a destroy of the instance takes (e.g., "10s").
+	DestroyLatency string `json:"destroy_latency,omitempty"`
+}
+
+// ReadConfig reads the config of the fake provider from a JSON file.
+func ReadConfig(path string) (Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var result Config
+
+	err = json.Unmarshal(content, &result)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse fake provider config (%s): %s", path, err)
+	}
+
+	return result, nil
+}
+
+// Write writes the config as JSON to a file.
+func (c Config) Write(path string) error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, content, 0600)
+}
+
+//nolint:gochecknoglobals
+var (
+	// awsProviderConfigAttributes are the attributes of the provider configuration sent by
+	// provider.Init of awstools-lib when configuring the AWS provider.
+	awsProviderConfigAttributes = []string{
+		"access_key",
+		"allowed_account_ids",
+		"assume_role",
+		"default_tags",
+		"endpoints",
+		"forbidden_account_ids",
+		"ignore_tag_prefixes",
+		"ignore_tags",
+		"insecure",
+		"max_retries",
+		"profile",
+		"region",
+		"s3_force_path_style",
+		"secret_key",
+		"shared_credentials_file",
+		"skip_credentials_validation",
+		"skip_get_ec2_platforms",
+		"skip_metadata_api_check",
+		"skip_region_validation",
+		"skip_requesting_account_id",
+		"token",
+	}
+)
+
+// Provider returns the fake provider with the resource types of the given config.
+func Provider(config Config) *schema.Provider {
+	b := &backend{
+		config:    config,
+		attempts:  map[string]int{},
+		destroyed: map[string]bool{},
+	}
+
+	resources := map[string]*schema.Resource{}
+	for terraformType, rc := range config.Resources {
+		resources[terraformType] = b.resource(terraformType, rc)
+	}
+
+	return &schema.Provider{
+		Schema:       stringAttributes(awsProviderConfigAttributes),
+		ResourcesMap: resources,
+	}
+}
+
+// backend keeps track of the instances destroyed by a running fake provider.
+type backend struct {
+	config Config
+
+	mu        sync.Mutex
+	attempts  map[string]int
+	destroyed map[string]bool
+}
+
+func (b *backend) resource(terraformType string, rc ResourceConfig) *schema.Resource {
+	return &schema.Resource{
+		Schema: stringAttributes(rc.Attributes),
+		Create: func(d *schema.ResourceData, _ interface{}) error {
+			return fmt.Errorf("creating resources is not supported by the fake provider: %s", terraformType)
+		},
+		Read: func(d *schema.ResourceData, _ interface{}) error {
+			return b.read(rc, d)
+		},
+		Delete: func(d *schema.ResourceData, _ interface{}) error {
+			return b.delete(rc, d)
+		},
+		Importer: &schema.ResourceImporter{
+			State: schema.ImportStatePassthrough,
+		},
+		SchemaVersion:  rc.SchemaVersion,
+		StateUpgraders: stateUpgraders(rc),
+	}
+}
+
+// stateUpgraders returns an upgrader per older schema version of the resource type, each of which renames
+// the attributes of older schema versions.
+func stateUpgraders(rc ResourceConfig) []schema.StateUpgrader {
+	attributeTypes := map[string]cty.Type{"id": cty.String}
+
+	for _, name := range rc.Attributes {
+		attributeTypes[name] = cty.String
+	}
+
+	for oldName := range rc.RenamedAttributes {
+		attributeTypes[oldName] = cty.String
+	}
+
+	var result []schema.StateUpgrader
+
+	for version := 0; version < rc.SchemaVersion; version++ {
+		result = append(result, schema.StateUpgrader{
+			Version: version,
+			Type:    cty.Object(attributeTypes),
+			Upgrade: func(rawState map[string]interface{}, _ interface{}) (map[string]interface{}, error) {
+				for oldName, newName := range rc.RenamedAttributes {
+					if v, ok := rawState[oldName]; ok {
+						rawState[newName] = v
+						delete(rawState, oldName)
+					}
+				}
+
+				return rawState, nil
+			},
+		})
+	}
+
+	return result
+}
+
+// read removes the instance from the state if it is gone or has been destroyed.
+func (b *backend) read(rc ResourceConfig, d *schema.ResourceData) error {
+	instance := rc.Instances[d.Id()]
+
+	err := sleep(instance.ReadLatency)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.exists(d.Id()) {
+		d.SetId("")
+	}
+
+	return nil
+}
+
+// delete destroys the instance, unless any of its dependents still exists or an error is injected
+// for the current attempt.
+func (b *backend) delete(rc ResourceConfig, d *schema.ResourceData) error {
+	id := d.Id()
+	instance := rc.Instances[id]
+
+	err := sleep(instance.DestroyLatency)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.attempts[id]++
+
+	for _, dependent := range instance.DependedOnBy {
+		if b.exists(dependent) {
+			return fmt.Errorf("DependencyViolation: resource %s has a dependent object: %s", id, dependent)
+		}
+	}
+
+	if attempt := b.attempts[id]; attempt <= len(instance.DestroyErrors) {
+		return fmt.Errorf("%s", instance.DestroyErrors[attempt-1])
+	}
+
+	b.destroyed[id] = true
+
+	return b.logDestroyed(id)
+}
+
+// exists returns true if the instance with the given ID is neither gone nor has been destroyed.
+func (b *backend) exists(id string) bool {
+	if b.destroyed[id] {
+		return false
+	}
+
+	for _, rc := range b.config.Resources {
+		if rc.Instances[id].Gone {
+			return false
+		}
+	}
+
+	return true
+}
+
+// logDestroyed appends the ID of a destroyed instance to the destroy log (if any).
+func (b *backend) logDestroyed(id string) error {
+	if b.config.DestroyLog == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(b.config.DestroyLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open destroy log: %s", err)
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, id)
+
+	return err
+}
+
+func stringAttributes(names []string) map[string]*schema.Schema {
+	result := map[string]*schema.Schema{}
+
+	for _, name := range names {
+		result[name] = &schema.Schema{
+			Type:     schema.TypeString,
+			Optional: true,
+		}
+	}
+
+	return result
+}
+
+func sleep(latency string) error {
+	if latency == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(latency)
+	if err != nil {
+		return fmt.Errorf("failed to parse latency: %s", err)
+	}
+
+	time.Sleep(d)
+
+	return nil
+}
@@ -0,0 +1,26 @@
+// Command terraform-provider-aws is the fake provider plugin (see package fakeprovider),
+// which reads its config from the file given by the environment variable TERRADOZER_FAKE_PROVIDER_CONFIG.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hashicorp/terraform/plugin"
+	"github.com/hashicorp/terraform/terraform"
+	"github.com/jckuester/terradozer/test/fakeprovider"
+)
+
+func main() {
+	config, err := fakeprovider.ReadConfig(os.Getenv(fakeprovider.ConfigEnvVar))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+
+	plugin.Serve(&plugin.ServeOpts{
+		ProviderFunc: func() terraform.ResourceProvider {
+			return fakeprovider.Provider(config)
+		},
+	})
+}
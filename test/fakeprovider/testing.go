@@ -0,0 +1,110 @@
+package fakeprovider
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Version is the default version of the AWS provider that terradozer looks for in the install directory,
+// which the fake provider is installed as (so that it is used instead of downloading the real one).
+const Version = "3.42.0"
+
+const (
+	pluginPackage = "github.com/jckuester/terradozer/test/fakeprovider/terraform-provider-aws"
+	configFile    = "fake-provider.json"
+	destroyLog    = "destroyed.log"
+)
+
+// Install builds the fake provider plugin and installs it into the given directory, with the given config.
+// The IDs of destroyed resources are logged in the same directory (see Destroyed).
+func Install(t *testing.T, installDir string, config Config) {
+	InstallVersion(t, installDir, Version, config)
+}
+
+// InstallVersion is like Install, but installs the fake provider as the given version of the AWS provider.
+func InstallVersion(t *testing.T, installDir, version string, config Config) {
+	InstallPlugin(t, installDir, "aws", version, config)
+}
+
+// InstallPlugin is like Install, but installs the fake provider as the given version of the provider
+// with the given name (e.g., widgets).
+//
+// The config applies to all fake providers installed before by the same test, as they share the environment
+// variable with the path to it (see ConfigEnvVar).
+func InstallPlugin(t *testing.T, installDir, name, version string, config Config) {
+	err := os.MkdirAll(installDir, 0755)
+	require.NoError(t, err)
+
+	cmd := exec.Command("go", "build", "-o",
+		filepath.Join(installDir, "terraform-provider-"+name+"_v"+version), pluginPackage)
+
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	config.DestroyLog = filepath.Join(installDir, destroyLog)
+
+	configPath := filepath.Join(installDir, configFile)
+
+	err = config.Write(configPath)
+	require.NoError(t, err)
+
+	t.Setenv(ConfigEnvVar, configPath)
+}
+
+// Setup installs the fake provider into the directory where the terradozer binary looks for
+// provider plugins (~/.terradozer), with HOME set to a temporary directory (see SetHome).
+// Returns the install directory.
+func Setup(t *testing.T, config Config) string {
+	installDir := filepath.Join(SetHome(t), ".terradozer")
+
+	Install(t, installDir, config)
+
+	return installDir
+}
+
+// SetHome sets HOME to a temporary directory, so that no provider plugins installed previously are found.
+// Returns the home directory.
+//
+// The Go environment (e.g., the build cache) is kept as it is, so that binaries can still be built afterwards.
+func SetHome(t *testing.T) string {
+	home := t.TempDir()
+
+	keepGoEnv(t)
+	t.Setenv("HOME", home)
+
+	return home
+}
+
+// keepGoEnv sets the variables of the Go environment that default to a directory under HOME to their current value.
+func keepGoEnv(t *testing.T) {
+	names := []string{"GOPATH", "GOCACHE", "GOMODCACHE", "GOENV"}
+
+	out, err := exec.Command("go", append([]string{"env"}, names...)...).Output()
+	require.NoError(t, err)
+
+	values := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	require.Len(t, values, len(names))
+
+	for i, name := range names {
+		t.Setenv(name, values[i])
+	}
+}
+
+// Destroyed returns the IDs of the resources destroyed by the fake provider installed into the given directory,
+// in the order they have been destroyed.
+func Destroyed(t *testing.T, installDir string) []string {
+	content, err := ioutil.ReadFile(filepath.Join(installDir, destroyLog))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	require.NoError(t, err)
+
+	return strings.Fields(string(content))
+}
@@ -0,0 +1,51 @@
+//go:build linux
+// +build linux
+
+package test
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// openTerminal opens a pseudo-terminal. Returns the controlling end to write user input to and
+// the terminal end to use as stdin of a process. The test is skipped if pseudo-terminals aren't available.
+func openTerminal(t *testing.T) (*os.File, *os.File) {
+	ptmx, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %s", err)
+	}
+
+	t.Cleanup(func() { ptmx.Close() })
+
+	var unlock int32
+
+	err = ioctl(ptmx, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock))
+	require.NoError(t, err)
+
+	var n uint32
+
+	err = ioctl(ptmx, syscall.TIOCGPTN, unsafe.Pointer(&n))
+	require.NoError(t, err)
+
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { tty.Close() })
+
+	return ptmx, tty
+}
+
+func ioctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
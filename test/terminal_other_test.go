@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package test
+
+import (
+	"os"
+	"testing"
+)
+
+// openTerminal skips the test, as pseudo-terminals are only opened on Linux (see terminal_linux_test.go).
+func openTerminal(t *testing.T) (*os.File, *os.File) {
+	t.Skip("pseudo-terminals are only supported on Linux")
+
+	return nil, nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"time"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/terradozer/pkg/state"
+)
+
+// readDeleteTimeouts returns the delete timeouts found in the timeouts blocks of resources in the given states,
+// keyed by state and address of the resource instance (see state.DeleteTimeouts).
+func readDeleteTimeouts(tfstates []*state.State) (map[*state.State]map[string]time.Duration, error) {
+	result := map[*state.State]map[string]time.Duration{}
+
+	for _, tfstate := range tfstates {
+		timeouts, err := tfstate.DeleteTimeouts()
+		if err != nil {
+			return nil, err
+		}
+
+		result[tfstate] = timeouts
+	}
+
+	return result, nil
+}
+
+// setDestroyTimeouts sets the amount of time to wait for a destroy of each of the given resources to finish.
+// The timeout given for the type of a resource takes precedence over the delete timeout of the resource's
+// timeouts block in the state (keyed by address), which takes precedence over the default timeout.
+func setDestroyTimeouts(resources []terraform.UpdatableResource, deleteTimeouts map[string]time.Duration,
+	typeTimeouts map[string]time.Duration, defaultTimeout time.Duration) {
+	for _, r := range resources {
+		res, ok := r.(interface {
+			Address() string
+			SetTimeout(time.Duration)
+		})
+		if !ok {
+			continue
+		}
+
+		timeout := defaultTimeout
+
+		if t, ok := deleteTimeouts[res.Address()]; ok {
+			timeout = t
+		}
+
+		if t, ok := typeTimeouts[r.Type()]; ok {
+			timeout = t
+		}
+
+		res.SetTimeout(timeout)
+	}
+}